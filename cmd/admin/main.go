@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ChixXx1/expense-tracker/internal/auth"
+	"github.com/ChixXx1/expense-tracker/internal/backup"
+	"github.com/ChixXx1/expense-tracker/internal/database"
+	"github.com/ChixXx1/expense-tracker/internal/models"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "check-aggregates":
+		checkAggregates(os.Args[2:])
+	case "backup":
+		createBackup(os.Args[2:])
+	case "restore":
+		restoreBackup(os.Args[2:])
+	case "create-admin":
+		createAdmin(os.Args[2:])
+	case "grant-admin":
+		setAdmin("grant-admin", os.Args[2:], true)
+	case "revoke-admin":
+		setAdmin("revoke-admin", os.Args[2:], false)
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: admin <command> [flags]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Commands:")
+	fmt.Fprintln(os.Stderr, "  check-aggregates  Rebuild report aggregates and compare with stored ones")
+	fmt.Fprintln(os.Stderr, "  backup            Write a full backup archive")
+	fmt.Fprintln(os.Stderr, "  restore           Verify a backup archive and restore it")
+	fmt.Fprintln(os.Stderr, "  create-admin      Create an administrator account (password is read from stdin)")
+	fmt.Fprintln(os.Stderr, "  grant-admin       Give an existing user administrator access")
+	fmt.Fprintln(os.Stderr, "  revoke-admin      Take administrator access away from a user")
+}
+
+func openStorage(path string) *database.JSONStorage {
+	if _, err := os.Stat(path); err != nil {
+		fmt.Fprintf(os.Stderr, "data file %s: %v\n", path, err)
+		os.Exit(1)
+	}
+	return database.NewJSONStorage(path)
+}
+
+func checkAggregates(args []string) {
+	fs := flag.NewFlagSet("check-aggregates", flag.ExitOnError)
+	dataPath := fs.String("data", "./data.json", "path to the data file")
+	fix := fs.Bool("fix", false, "rewrite aggregates when they do not match")
+	fs.Parse(args)
+
+	storage := openStorage(*dataPath)
+
+	problems, err := storage.VerifyAggregates(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to verify aggregates: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(problems) == 0 {
+		fmt.Println("aggregates are consistent")
+		return
+	}
+
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+
+	if !*fix {
+		fmt.Printf("%d problem(s) found, run with -fix to rebuild\n", len(problems))
+		os.Exit(1)
+	}
+
+	if err := storage.RebuildAggregates(context.Background()); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to rebuild aggregates: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("%d problem(s) fixed, aggregates rebuilt\n", len(problems))
+}
+
+func createBackup(args []string) {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	dataPath := fs.String("data", "./data.json", "path to the data file")
+	outPath := fs.String("out", "", "archive path (default expense-tracker-backup-<time>.json.gz)")
+	fs.Parse(args)
+
+	storage := openStorage(*dataPath)
+
+	archive, err := backup.Create(database.AllLedgers(context.Background()), storage)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create backup: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *outPath == "" {
+		*outPath = fmt.Sprintf("expense-tracker-backup-%s.json.gz", archive.CreatedAt.Format("20060102-150405"))
+	}
+
+	file, err := os.Create(*outPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create %s: %v\n", *outPath, err)
+		os.Exit(1)
+	}
+
+	if err := backup.Write(file, archive); err != nil {
+		file.Close()
+		fmt.Fprintf(os.Stderr, "failed to write backup: %v\n", err)
+		os.Exit(1)
+	}
+	if err := file.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write backup: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("backup written to %s (%d categories, %d transactions, %d budgets, checksum %s)\n",
+		*outPath, len(archive.Categories), len(archive.Transactions), len(archive.Budgets), archive.Checksum)
+}
+
+func restoreBackup(args []string) {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	dataPath := fs.String("data", "./data.json", "path to the data file")
+	inPath := fs.String("in", "", "archive to restore")
+	mode := fs.String("mode", models.RestoreModeReplace, "restore mode: replace or merge")
+	verifyOnly := fs.Bool("verify", false, "only verify the archive, do not restore")
+	ledgerID := fs.Int("ledger", 0, "restore only into this ledger (required for schema version 1 archives)")
+	fs.Parse(args)
+
+	if *inPath == "" {
+		fmt.Fprintln(os.Stderr, "-in is required")
+		os.Exit(2)
+	}
+
+	file, err := os.Open(*inPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open %s: %v\n", *inPath, err)
+		os.Exit(1)
+	}
+	defer file.Close()
+
+	archive, err := backup.Read(file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if err := backup.Verify(archive); err != nil {
+		fmt.Fprintf(os.Stderr, "backup rejected: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *verifyOnly {
+		fmt.Println("backup is valid")
+		return
+	}
+
+	storage := openStorage(*dataPath)
+
+	ctx := database.AllLedgers(context.Background())
+	if *ledgerID != 0 {
+		if ctx, err = backup.IntoLedger(ctx, storage, *ledgerID); err != nil {
+			fmt.Fprintf(os.Stderr, "ledger %d: %v\n", *ledgerID, err)
+			os.Exit(1)
+		}
+	}
+
+	result, err := backup.Restore(ctx, storage, archive, *mode)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to restore backup: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("restored (%s): %d users, %d ledgers, %d categories, %d transactions, %d budgets, %d skipped\n",
+		result.Mode, result.Users, result.Ledgers, result.Categories, result.Transactions, result.Budgets, result.Skipped)
+}
+
+func createAdmin(args []string) {
+	fs := flag.NewFlagSet("create-admin", flag.ExitOnError)
+	dataPath := fs.String("data", "./data.json", "path to the data file")
+	email := fs.String("email", "", "administrator email")
+	fs.Parse(args)
+
+	if *email == "" {
+		fmt.Fprintln(os.Stderr, "-email is required")
+		os.Exit(2)
+	}
+
+	// пароль читаем из stdin, а не из флага, чтобы он не попал в историю команд и список процессов
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		fmt.Fprintf(os.Stderr, "failed to read password: %v\n", err)
+		os.Exit(1)
+	}
+
+	storage := openStorage(*dataPath)
+	service := auth.NewService(storage, auth.Config{})
+
+	user, err := service.RegisterAdmin(context.Background(), *email, strings.TrimRight(password, "\r\n"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create administrator: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("administrator %s created (id %d)\n", user.Email, user.ID)
+}
+
+func setAdmin(name string, args []string, admin bool) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	dataPath := fs.String("data", "./data.json", "path to the data file")
+	email := fs.String("email", "", "user email")
+	fs.Parse(args)
+
+	if *email == "" {
+		fmt.Fprintln(os.Stderr, "-email is required")
+		os.Exit(2)
+	}
+
+	storage := openStorage(*dataPath)
+	ctx := context.Background()
+
+	user, err := storage.GetUserByEmail(ctx, *email)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "user %s: %v\n", *email, err)
+		os.Exit(1)
+	}
+	if err := storage.SetUserAdmin(ctx, user.ID, admin); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to update %s: %v\n", user.Email, err)
+		os.Exit(1)
+	}
+
+	if admin {
+		fmt.Printf("%s is now an administrator\n", user.Email)
+	} else {
+		fmt.Printf("%s is no longer an administrator\n", user.Email)
+	}
+}
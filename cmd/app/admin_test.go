@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/ChixXx1/expense-tracker/internal/auth"
+)
+
+// Права администратора не получить регистрацией: адреса из ADMIN_EMAILS заняты, а доступ дает только флаг
+func TestAdminAccessIsGrantedExplicitly(t *testing.T) {
+	s := newTestServer(t)
+
+	credentials := map[string]string{"email": testAdminEmail, "password": testPassword}
+	s.expect(request{Method: http.MethodPost, Path: "/auth/register", Body: credentials}, http.StatusUnprocessableEntity, nil)
+	credentials["email"] = " Admin@Example.com "
+	s.expect(request{Method: http.MethodPost, Path: "/auth/register", Body: credentials}, http.StatusUnprocessableEntity, nil)
+
+	token := s.signUp("alice@example.com")
+	s.expect(request{Method: http.MethodGet, Path: "/admin/snapshots", Token: token}, http.StatusForbidden, nil)
+
+	user, err := s.storage.GetUserByEmail(context.Background(), "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.storage.SetUserAdmin(context.Background(), user.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	s.expect(request{Method: http.MethodGet, Path: "/admin/snapshots", Token: token}, http.StatusOK, nil)
+
+	if err := s.storage.SetUserAdmin(context.Background(), user.ID, false); err != nil {
+		t.Fatal(err)
+	}
+	s.expect(request{Method: http.MethodGet, Path: "/admin/snapshots", Token: token}, http.StatusForbidden, nil)
+
+	s.createAdmin(testAdminEmail)
+	admin := s.login(testAdminEmail)
+	s.expect(request{Method: http.MethodGet, Path: "/admin/snapshots", Token: admin}, http.StatusOK, nil)
+}
+
+// Замена учетных записей из копии завершает все сессии: ID в копии могут принадлежать другим людям
+func TestReplaceRestoreEndsSessions(t *testing.T) {
+	s := newTestServer(t)
+
+	s.createAdmin(testAdminEmail)
+	credentials := map[string]string{"email": testAdminEmail, "password": testPassword}
+	var session struct {
+		Tokens auth.Tokens `json:"tokens"`
+	}
+	s.expect(request{Method: http.MethodPost, Path: "/auth/login", Body: credentials}, http.StatusOK, &session)
+	admin := session.Tokens.AccessToken
+	alice := s.signUp("alice@example.com")
+
+	archive := s.expect(request{Method: http.MethodGet, Path: "/admin/backup", Token: admin}, http.StatusOK, nil).Body.Bytes()
+	s.expect(request{Method: http.MethodPost, Path: "/admin/restore?mode=replace", Token: admin, Body: archive, ContentType: "application/gzip"}, http.StatusOK, nil)
+
+	s.expect(request{Method: http.MethodGet, Path: "/categories", Token: alice}, http.StatusUnauthorized, nil)
+	s.expect(request{Method: http.MethodGet, Path: "/admin/snapshots", Token: admin}, http.StatusUnauthorized, nil)
+	refresh := map[string]string{"refresh_token": session.Tokens.RefreshToken}
+	s.expect(request{Method: http.MethodPost, Path: "/auth/refresh", Body: refresh}, http.StatusUnauthorized, nil)
+
+	alice = s.login("alice@example.com")
+	s.expect(request{Method: http.MethodGet, Path: "/categories", Token: alice}, http.StatusOK, nil)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/ChixXx1/expense-tracker/internal/models"
+)
+
+// If-Match сравнивает теги строго: слабый тег не разрешает запись, а If-None-Match принимает и его
+func TestIfMatchRejectsWeakETags(t *testing.T) {
+	s := newTestServer(t)
+	token := s.signUp("alice@example.com")
+
+	var category models.Category
+	s.expect(request{Method: http.MethodPost, Path: "/categories", Token: token,
+		Body: models.Category{Name: "Кофе", Type: models.TransactionTypeExpense}}, http.StatusCreated, &category)
+	path := "/categories/" + strconv.Itoa(category.ID)
+	strong := `"` + strconv.Itoa(category.Version) + `"`
+	update := models.Category{Name: "Кофейни", Type: models.TransactionTypeExpense}
+
+	s.expect(request{Method: http.MethodGet, Path: path, Token: token, Headers: map[string]string{"If-None-Match": "W/" + strong}},
+		http.StatusNotModified, nil)
+	s.expect(request{Method: http.MethodPut, Path: path, Token: token, Body: update, Headers: map[string]string{"If-Match": "W/" + strong}},
+		http.StatusPreconditionFailed, nil)
+	s.expect(request{Method: http.MethodPut, Path: path, Token: token, Body: update, Headers: map[string]string{"If-Match": `W/"0", ` + strong}},
+		http.StatusOK, nil)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ChixXx1/expense-tracker/internal/handlers"
+	"github.com/ChixXx1/expense-tracker/internal/models"
+)
+
+// Анонимные запросы не делят ключи: чужой ответ не повторяется, а ключ не занимается за другим клиентом
+func TestIdempotencySkipsAnonymousRequests(t *testing.T) {
+	s := newTestServer(t)
+	key := map[string]string{handlers.IdempotencyKeyHeader: "same-key"}
+
+	for _, email := range []string{"alice@example.com", "bob@example.com"} {
+		credentials := map[string]string{"email": email, "password": testPassword}
+		rec := s.expect(request{Method: http.MethodPost, Path: "/auth/register", Body: credentials, Headers: key}, http.StatusCreated, nil)
+		if rec.Header().Get("Idempotent-Replayed") != "" {
+			t.Errorf("%s: anonymous registration replayed a stored response", email)
+		}
+	}
+
+	// у вошедшего пользователя повтор с тем же ключом возвращает первый ответ
+	token := s.login("alice@example.com")
+	body := models.Category{Name: "Кофе", Type: models.TransactionTypeExpense}
+	var first, second models.Category
+	s.expect(request{Method: http.MethodPost, Path: "/categories", Token: token, Body: body, Headers: key}, http.StatusCreated, &first)
+	rec := s.expect(request{Method: http.MethodPost, Path: "/categories", Token: token, Body: body, Headers: key}, http.StatusCreated, &second)
+	if rec.Header().Get("Idempotent-Replayed") != "true" || second.ID != first.ID {
+		t.Errorf("authenticated repeat was not replayed: %+v, %+v", first, second)
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ChixXx1/expense-tracker/internal/handlers"
+	"github.com/ChixXx1/expense-tracker/internal/models"
+)
+
+// ledgerRecords - записи одной книги, к которым другие книги не должны иметь доступа
+type ledgerRecords struct {
+	expenseID   int
+	category    models.Category
+	transaction models.Transaction
+	budget      models.Budget
+	profile     models.ImportProfile
+}
+
+var isolationDate = time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+
+// Чужие записи - и другого пользователя, и другой книги того же пользователя - неотличимы от несуществующих
+func TestRecordsAreIsolatedBetweenLedgers(t *testing.T) {
+	s := newTestServer(t)
+
+	alice := s.signUp("alice@example.com")
+	bob := s.signUp("bob@example.com")
+
+	aliceRecords := s.createRecords(alice, nil, "Аренда")
+	bobRecords := s.createRecords(bob, nil, "Кофе")
+
+	// общая книга Алисы, в которую Боб вступил редактором
+	var shared models.Ledger
+	s.expect(request{Method: http.MethodPost, Path: "/ledgers", Token: alice, Body: map[string]string{"name": "Семья"}}, http.StatusCreated, &shared)
+	var invite models.LedgerInvite
+	s.expect(request{Method: http.MethodPost, Path: "/ledgers/" + strconv.Itoa(shared.ID) + "/invites", Token: alice,
+		Body: map[string]string{"role": models.LedgerRoleEditor}}, http.StatusCreated, &invite)
+	s.expect(request{Method: http.MethodPost, Path: "/ledgers/join", Token: bob, Body: map[string]string{"code": invite.Code}}, http.StatusOK, nil)
+	inShared := inLedger(shared.ID)
+	sharedRecords := s.createRecords(alice, inShared, "Продукты")
+
+	cases := []struct {
+		name    string
+		token   string
+		headers map[string]string
+		target  ledgerRecords
+		// own - записи книги вызывающего: из них берутся корректные тела запросов
+		own ledgerRecords
+	}{
+		{"другой пользователь", bob, nil, aliceRecords, bobRecords},
+		{"личная книга из общей", alice, inShared, aliceRecords, sharedRecords},
+		{"общая книга из личной", alice, nil, sharedRecords, aliceRecords},
+		{"участник общей книги из личной", bob, nil, sharedRecords, bobRecords},
+		{"личная книга участника из общей", bob, inShared, aliceRecords, sharedRecords},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, r := range tc.target.foreignRequests(tc.own) {
+				r.Token = tc.token
+				r.Headers = tc.headers
+				if rec := s.do(r); rec.Code != http.StatusNotFound {
+					t.Errorf("%s %s: status %d, want %d: %s", r.Method, r.Path, rec.Code, http.StatusNotFound, rec.Body.String())
+				}
+			}
+		})
+	}
+
+	// после всех попыток записи на месте и не изменились
+	for _, records := range []struct {
+		token   string
+		headers map[string]string
+		records ledgerRecords
+	}{{alice, nil, aliceRecords}, {alice, inShared, sharedRecords}} {
+		var transaction models.Transaction
+		s.expect(request{Method: http.MethodGet, Path: "/transactions/" + strconv.Itoa(records.records.transaction.ID),
+			Token: records.token, Headers: records.headers}, http.StatusOK, &transaction)
+		if transaction.Version != records.records.transaction.Version || transaction.Description != records.records.transaction.Description {
+			t.Errorf("transaction %d changed by another ledger: %+v", transaction.ID, transaction)
+		}
+	}
+
+	// в чужую личную книгу не войти и через заголовок
+	s.expect(request{Method: http.MethodGet, Path: "/transactions", Token: bob,
+		Headers: inLedger(aliceRecords.transaction.LedgerID)}, http.StatusNotFound, nil)
+}
+
+// Отчеты, поиск, дубликаты и выгрузка считаются только по книге вызывающего
+func TestReadModelsAreScopedToLedger(t *testing.T) {
+	s := newTestServer(t)
+
+	alice := s.signUp("alice@example.com")
+	bob := s.signUp("bob@example.com")
+
+	aliceRecords := s.createRecords(alice, nil, "Аренда")
+	// вторая такая же транзакция делает пару дубликатов в книге Алисы
+	s.expect(request{Method: http.MethodPost, Path: "/transactions?force=true", Token: alice,
+		Body: newIsolationTransaction(aliceRecords.expenseID, "Аренда", 1000)}, http.StatusCreated, nil)
+	s.createRecords(bob, nil, "Кофе")
+
+	var duplicates []models.DuplicatePair
+	s.expect(request{Method: http.MethodGet, Path: "/transactions/duplicates", Token: alice}, http.StatusOK, &duplicates)
+	if len(duplicates) == 0 {
+		t.Fatal("alice: expected a duplicate pair")
+	}
+	s.expect(request{Method: http.MethodGet, Path: "/transactions/duplicates", Token: bob}, http.StatusOK, &duplicates)
+	if len(duplicates) != 0 {
+		t.Errorf("bob: duplicates from another ledger: %+v", duplicates)
+	}
+
+	var transactions []models.Transaction
+	s.expect(request{Method: http.MethodGet, Path: "/transactions", Token: bob}, http.StatusOK, &transactions)
+	if len(transactions) != 1 || transactions[0].Description != "Кофе" {
+		t.Errorf("bob: transactions %+v, want only his own", transactions)
+	}
+
+	period := "?start_date=2026-03-01&end_date=2026-03-31"
+	var summary models.FinancialSummary
+	s.expect(request{Method: http.MethodGet, Path: "/reports/financial" + period, Token: bob}, http.StatusOK, &summary)
+	if summary.TotalExpenses != 1000 {
+		t.Errorf("bob: total expenses %.2f, want 1000", summary.TotalExpenses)
+	}
+	s.expect(request{Method: http.MethodGet, Path: "/reports/financial" + period, Token: alice}, http.StatusOK, &summary)
+	if summary.TotalExpenses != 2000 {
+		t.Errorf("alice: total expenses %.2f, want 2000", summary.TotalExpenses)
+	}
+
+	var byCategory []models.CategorySummary
+	s.expect(request{Method: http.MethodGet, Path: "/reports/categories" + period, Token: bob}, http.StatusOK, &byCategory)
+	for _, row := range byCategory {
+		if row.CategoryID == aliceRecords.expenseID || row.CategoryID == aliceRecords.category.ID {
+			t.Errorf("bob: category report includes alice's category %d", row.CategoryID)
+		}
+	}
+
+	// отчет по чужому бюджету - как по несуществующему
+	s.expect(request{Method: http.MethodGet, Path: "/reports/budgets/" + strconv.Itoa(aliceRecords.budget.ID), Token: bob}, http.StatusNotFound, nil)
+
+	var hits []struct {
+		Transaction models.Transaction `json:"transaction"`
+	}
+	s.expect(request{Method: http.MethodGet, Path: "/search?q=аренда", Token: bob}, http.StatusOK, &hits)
+	if len(hits) != 0 {
+		t.Errorf("bob: search found another ledger's transactions: %+v", hits)
+	}
+	s.expect(request{Method: http.MethodGet, Path: "/search?q=аренда", Token: alice}, http.StatusOK, &hits)
+	if len(hits) != 2 {
+		t.Errorf("alice: search found %d transactions, want 2", len(hits))
+	}
+
+	for _, path := range []string{"/export/transactions?format=csv", "/export/transactions?format=jsonl", "/export/journal"} {
+		rec := s.expect(request{Method: http.MethodGet, Path: path, Token: bob}, http.StatusOK, nil)
+		if body := rec.Body.String(); strings.Contains(body, "Аренда") || !strings.Contains(body, "Кофе") {
+			t.Errorf("bob: %s includes another ledger or misses his own data:\n%s", path, body)
+		}
+	}
+}
+
+// createRecords заводит в книге по записи каждого вида; headers выбирает книгу
+func (s *testServer) createRecords(token string, headers map[string]string, description string) ledgerRecords {
+	s.t.Helper()
+
+	var records ledgerRecords
+
+	var categories []models.Category
+	s.expect(request{Method: http.MethodGet, Path: "/categories", Token: token, Headers: headers}, http.StatusOK, &categories)
+	for _, category := range categories {
+		if category.Type == models.TransactionTypeExpense {
+			records.expenseID = category.ID
+			break
+		}
+	}
+	if records.expenseID == 0 {
+		s.t.Fatal("ledger has no expense category")
+	}
+
+	s.expect(request{Method: http.MethodPost, Path: "/categories", Token: token, Headers: headers,
+		Body: models.Category{Name: description, Type: models.TransactionTypeExpense}}, http.StatusCreated, &records.category)
+	s.expect(request{Method: http.MethodPost, Path: "/transactions", Token: token, Headers: headers,
+		Body: newIsolationTransaction(records.expenseID, description, 1000)}, http.StatusCreated, &records.transaction)
+	s.expect(request{Method: http.MethodPost, Path: "/budgets", Token: token, Headers: headers,
+		Body: newIsolationBudget(records.expenseID)}, http.StatusCreated, &records.budget)
+	s.expect(request{Method: http.MethodPost, Path: "/import/profiles", Token: token, Headers: headers,
+		Body: newIsolationProfile(records.expenseID, description)}, http.StatusCreated, &records.profile)
+
+	return records
+}
+
+// foreignRequests - чтение, замена, частичное изменение и удаление каждой записи r (в тех, где маршрут есть).
+// Тела запросов корректны для книги own, поэтому 404 означает именно недоступность записи.
+func (r ledgerRecords) foreignRequests(own ledgerRecords) []request {
+	const mergePatch = "application/merge-patch+json"
+
+	category := "/categories/" + strconv.Itoa(r.category.ID)
+	transaction := "/transactions/" + strconv.Itoa(r.transaction.ID)
+	budget := "/budgets/" + strconv.Itoa(r.budget.ID)
+	profile := "/import/profiles/" + strconv.Itoa(r.profile.ID)
+
+	return []request{
+		{Method: http.MethodGet, Path: category},
+		{Method: http.MethodPut, Path: category, Body: models.Category{Name: "Чужая", Type: models.TransactionTypeExpense}},
+		{Method: http.MethodPatch, Path: category, Body: map[string]string{"color": "#000000"}, ContentType: mergePatch},
+		{Method: http.MethodDelete, Path: category},
+
+		{Method: http.MethodGet, Path: transaction},
+		{Method: http.MethodPut, Path: transaction, Body: newIsolationTransaction(own.expenseID, "Чужая", 1)},
+		{Method: http.MethodPatch, Path: transaction, Body: map[string]string{"notes": "чужая"}, ContentType: mergePatch},
+		{Method: http.MethodDelete, Path: transaction},
+
+		{Method: http.MethodGet, Path: budget},
+		{Method: http.MethodPut, Path: budget, Body: newIsolationBudget(own.expenseID)},
+		{Method: http.MethodPatch, Path: budget, Body: map[string]float64{"amount": 1}, ContentType: mergePatch},
+		{Method: http.MethodDelete, Path: budget},
+
+		{Method: http.MethodPut, Path: profile, Body: newIsolationProfile(own.expenseID, "Чужой")},
+		{Method: http.MethodDelete, Path: profile},
+	}
+}
+
+func newIsolationTransaction(categoryID int, description string, amount float64) models.Transaction {
+	return models.Transaction{Amount: amount, Type: models.TransactionTypeExpense, CategoryID: categoryID, Date: isolationDate,
+		Description: description, PaymentMethod: models.PaymentMethodCard}
+}
+
+func newIsolationBudget(categoryID int) models.Budget {
+	return models.Budget{CategoryID: categoryID, Amount: 5000, Period: models.BudgetPeriodMonthly, Month: isolationDate}
+}
+
+func newIsolationProfile(categoryID int, name string) models.ImportProfile {
+	return models.ImportProfile{Name: name, Delimiter: ";", HasHeader: true, DateColumn: "Дата", DateFormat: "DD.MM.YYYY",
+		AmountColumn: "Сумма", AmountConvention: models.AmountConventionSigned, DecimalSeparator: ",",
+		DescriptionColumn: "Описание", DefaultCategoryID: categoryID}
+}
+
+func inLedger(id int) map[string]string {
+	return map[string]string{handlers.LedgerHeader: strconv.Itoa(id)}
+}
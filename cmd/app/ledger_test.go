@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/ChixXx1/expense-tracker/internal/models"
+)
+
+// Предпросмотр импорта ничего не меняет и доступен viewer, а сам импорт - нет
+func TestViewerCanPreviewImport(t *testing.T) {
+	s := newTestServer(t)
+
+	alice := s.signUp("alice@example.com")
+	bob := s.signUp("bob@example.com")
+
+	var shared models.Ledger
+	s.expect(request{Method: http.MethodPost, Path: "/ledgers", Token: alice, Body: map[string]string{"name": "Семья"}}, http.StatusCreated, &shared)
+	var invite models.LedgerInvite
+	s.expect(request{Method: http.MethodPost, Path: "/ledgers/" + strconv.Itoa(shared.ID) + "/invites", Token: alice,
+		Body: map[string]string{"role": models.LedgerRoleViewer}}, http.StatusCreated, &invite)
+	s.expect(request{Method: http.MethodPost, Path: "/ledgers/join", Token: bob, Body: map[string]string{"code": invite.Code}}, http.StatusOK, nil)
+
+	qif := []byte("!Type:Bank\nD03/12/2026\nT-120.00\nPТакси\n^\n")
+	body, contentType := multipartBody(t, "statement.qif", qif, nil)
+	s.expect(request{Method: http.MethodPost, Path: "/import/qif", Token: bob, Headers: inLedger(shared.ID),
+		Body: body, ContentType: contentType}, http.StatusOK, nil)
+
+	body, contentType = multipartBody(t, "statement.qif", qif, nil)
+	s.expect(request{Method: http.MethodPost, Path: "/import/qif?commit=true", Token: bob, Headers: inLedger(shared.ID),
+		Body: body, ContentType: contentType}, http.StatusForbidden, nil)
+	body, contentType = multipartBody(t, "statement.qif", qif, nil)
+	rec := s.do(request{Method: http.MethodPost, Path: "/import/qif?commit=true", Token: alice, Headers: inLedger(shared.ID),
+		Body: body, ContentType: contentType})
+	if rec.Code == http.StatusForbidden {
+		t.Errorf("owner import: status %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -1,10 +1,17 @@
 package main
 
 import (
+	"context"
+	"log"
 	"net/http"
+	"time"
 
+	"github.com/ChixXx1/expense-tracker/internal/api"
+	"github.com/ChixXx1/expense-tracker/internal/auth"
+	"github.com/ChixXx1/expense-tracker/internal/backup"
 	"github.com/ChixXx1/expense-tracker/internal/database"
 	"github.com/ChixXx1/expense-tracker/internal/handlers"
+	"github.com/ChixXx1/expense-tracker/internal/search"
 	"github.com/gin-gonic/gin"
 )
 
@@ -12,10 +19,61 @@ func main() {
 
 	//storage := database.NewMemoryStorage()
 	storage := database.NewJSONStorage("./data.json")
+
+	snapshotConfig, err := backup.SnapshotConfigFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	authConfig, err := auth.ConfigFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	idempotencyTTL, err := handlers.IdempotencyTTLFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	r, scheduler, err := newServer(storage, serverConfig{
+		auth:           authConfig,
+		snapshots:      snapshotConfig,
+		idempotencyTTL: idempotencyTTL,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	scheduler.Start()
+	defer scheduler.Stop()
+
+	r.Run(":8080")
+}
+
+// serverConfig - настройки, которые main читает из окружения, а тесты задают напрямую
+type serverConfig struct {
+	auth           auth.Config
+	snapshots      backup.SnapshotConfig
+	idempotencyTTL time.Duration
+}
+
+// newServer собирает сервер со всеми маршрутами; планировщик снимков запускает вызывающий
+func newServer(storage *database.JSONStorage, config serverConfig) (*gin.Engine, *backup.Scheduler, error) {
 	categoryHandler := handlers.NewCategoryHandler(storage)
 	transactionHadler := handlers.NewTransactionHandler(storage)
 	budgetHandler := handlers.NewBudgetHandler(storage)
 	reportHandler := handlers.NewReportHandler(storage)
+	importHandler := handlers.NewImportHandler(storage)
+	exportHandler := handlers.NewExportHandler(storage)
+
+	searchIndex := search.NewIndex()
+	if err := storage.Subscribe(context.Background(), searchIndex.Apply); err != nil {
+		return nil, nil, err
+	}
+	searchHandler := handlers.NewSearchHandler(searchIndex)
+
+	scheduler := backup.NewScheduler(storage, config.snapshots)
+	adminHandler := handlers.NewAdminHandler(storage, scheduler)
+
+	authHandler := handlers.NewAuthHandler(auth.NewService(storage, config.auth))
+	ledgerHandler := handlers.NewLedgerHandler(storage)
 
 	r := gin.Default()
 
@@ -34,27 +92,49 @@ func main() {
 			"message": "WEB-APPLICATION GO+REACT",
 		})
 	})
-	r.GET("/categories", categoryHandler.GetCategories)
-	r.GET("/categories/:id", categoryHandler.GetCategoryByID)
-	r.POST("/categories", categoryHandler.CreateCategory)
-	r.PUT("/categories/:id", categoryHandler.UpdateCategory)
-	r.DELETE("/categories/:id", categoryHandler.DeleteCategory)
-
-	r.GET("/transactions", transactionHadler.GetTransactions)
-	r.GET("/transactions/:id", transactionHadler.GetTransactionByID)
-	r.POST("/transactions", transactionHadler.CreateTransaction)
-	r.PUT("/transactions/:id", transactionHadler.UpdateTransaction)
-	r.DELETE("/transactions/:id", transactionHadler.DeleteTransaction)
-
-	r.GET("/budgets", budgetHandler.GetBudgets)
-	r.GET("/budgets/:id", budgetHandler.GetBudgetByID)
-	r.POST("/budgets", budgetHandler.CreateBudget)
-	r.PUT("/budgets/:id", budgetHandler.UpdateBudget)
-	r.DELETE("/budgets/:id", budgetHandler.DeleteBudget)
-
-	r.GET("/reports/financial", reportHandler.GetFinancialSummary)
-	r.GET("/reports/categories", reportHandler.GetCategorySummary)
-	r.GET("/reports/budgets/:id", reportHandler.GetBudgetReport)
+	routes := apiRoutes(appHandlers{
+		categories:   categoryHandler,
+		transactions: transactionHadler,
+		budgets:      budgetHandler,
+		reports:      reportHandler,
+		imports:      importHandler,
+		exports:      exportHandler,
+		admin:        adminHandler,
+		search:       searchHandler,
+		auth:         authHandler,
+		ledgers:      ledgerHandler,
+	})
 
-	r.Run(":8080")
+	idempotency := handlers.NewIdempotencyStore(config.idempotencyTTL)
+
+	r.NoRoute(handlers.NoRoute)
+
+	v1 := r.Group(apiBasePath)
+	v1.Use(authHandler.Authenticate, idempotency.Middleware())
+	// все маршруты, кроме помеченных Public, требуют Bearer-токен; Admin - еще и прав администратора,
+	// остальные, кроме Account, - доступа к книге (ReadOnly - хотя бы на чтение)
+	api.Register(v1, routes, func(route api.Route) []gin.HandlerFunc {
+		switch {
+		case route.Public:
+			return nil
+		case route.Account:
+			return []gin.HandlerFunc{handlers.RequireAuth}
+		case route.Admin:
+			return []gin.HandlerFunc{handlers.RequireAuth, authHandler.RequireAdmin}
+		case route.ReadOnly:
+			return []gin.HandlerFunc{handlers.RequireAuth, handlers.ReadOnly, ledgerHandler.RequireLedger}
+		default:
+			return []gin.HandlerFunc{handlers.RequireAuth, ledgerHandler.RequireLedger}
+		}
+	})
+
+	api.RegisterDeprecated(r, apiBasePath, routes, deprecatedPrefixes)
+
+	// соответствие спецификации маршрутам и ответам обработчиков проверяет openapi_test.go
+	spec := api.Spec(api.Info{Title: "Expense Tracker API", Version: "1.0.0"}, apiBasePath, routes)
+	v1.GET("/openapi.json", func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, spec)
+	})
+
+	return r, scheduler, nil
 }
@@ -0,0 +1,417 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ChixXx1/expense-tracker/internal/api"
+	"github.com/ChixXx1/expense-tracker/internal/auth"
+	"github.com/ChixXx1/expense-tracker/internal/backup"
+	"github.com/ChixXx1/expense-tracker/internal/models"
+)
+
+// Маршрут, добавленный в обход таблицы, не попадет в спецификацию
+func TestSpecCoversRegisteredRoutes(t *testing.T) {
+	s := newTestServer(t)
+
+	if err := api.Check(s.engine, apiBasePath, apiRoutes(appHandlers{}), "/openapi.json"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestHandlersMatchSpec вызывает каждый документированный маршрут и сверяет статус и тело ответа со спецификацией
+func TestHandlersMatchSpec(t *testing.T) {
+	c := newSpecCheck(t)
+
+	c.call("POST /auth/register", request{Body: map[string]string{"email": "user@example.com", "password": testPassword}}, http.StatusCreated, nil)
+	// адрес администратора через API не зарегистрировать, учетную запись заводит cmd/admin
+	c.createAdmin(testAdminEmail)
+	credentials := map[string]string{"email": testAdminEmail, "password": testPassword}
+
+	var session struct {
+		Tokens auth.Tokens `json:"tokens"`
+	}
+	c.call("POST /auth/login", request{Body: credentials}, http.StatusOK, &session)
+
+	var tokens auth.Tokens
+	c.call("POST /auth/refresh", request{Body: map[string]string{"refresh_token": session.Tokens.RefreshToken}}, http.StatusOK, &tokens)
+	token := tokens.AccessToken
+	c.call("GET /auth/me", request{Token: token}, http.StatusOK, nil)
+
+	// категории
+	var categories []models.Category
+	c.call("GET /categories", request{Token: token}, http.StatusOK, &categories)
+	if len(categories) == 0 {
+		t.Fatal("new user has no default categories")
+	}
+	expenseID, incomeID := 0, 0
+	for _, cat := range categories {
+		if cat.Type == models.TransactionTypeExpense && expenseID == 0 {
+			expenseID = cat.ID
+		}
+		if cat.Type == models.TransactionTypeIncome && incomeID == 0 {
+			incomeID = cat.ID
+		}
+	}
+
+	var category models.Category
+	c.call("POST /categories", request{Token: token, Body: models.Category{Name: "Хобби", Type: models.TransactionTypeExpense}}, http.StatusCreated, &category)
+	categoryPath := "/categories/" + strconv.Itoa(category.ID)
+	rec := c.call("GET /categories/:id", request{Token: token, Path: categoryPath}, http.StatusOK, nil)
+	c.call("GET /categories/:id", request{Token: token, Path: categoryPath, Headers: map[string]string{"If-None-Match": rec.Header().Get("ETag")}}, http.StatusNotModified, nil)
+	c.call("PUT /categories/:id", request{Token: token, Path: categoryPath, Body: models.Category{Name: "Хобби и досуг", Type: models.TransactionTypeExpense}}, http.StatusOK, nil)
+	c.call("PATCH /categories/:id", request{Token: token, Path: categoryPath, Body: map[string]string{"color": "#ff0000"}, ContentType: mergePatch}, http.StatusOK, nil)
+
+	// транзакции
+	date := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	newTransaction := func(description string) models.Transaction {
+		return models.Transaction{Amount: 250, Type: models.TransactionTypeExpense, CategoryID: expenseID, Date: date,
+			Description: description, PaymentMethod: models.PaymentMethodCard}
+	}
+
+	var first, second, third models.Transaction
+	c.call("POST /transactions", request{Token: token, Body: newTransaction("Кофе")}, http.StatusCreated, &first)
+	c.call("POST /transactions", request{Token: token, Path: "/transactions?force=true", Body: newTransaction("Кофе")}, http.StatusCreated, &second)
+	c.call("GET /transactions", request{Token: token}, http.StatusOK, nil)
+	c.call("GET /transactions/duplicates", request{Token: token}, http.StatusOK, nil)
+	c.call("POST /transactions/duplicates/dismiss", request{Token: token, Body: map[string]int{"first_id": first.ID, "second_id": second.ID}}, http.StatusOK, nil)
+	c.call("POST /transactions", request{Token: token, Path: "/transactions?force=true", Body: newTransaction("Кофе")}, http.StatusCreated, &third)
+	c.call("POST /transactions/duplicates/merge", request{Token: token, Body: map[string]int{"keep_id": first.ID, "remove_id": third.ID}}, http.StatusOK, nil)
+
+	transactionPath := "/transactions/" + strconv.Itoa(first.ID)
+	rec = c.call("GET /transactions/:id", request{Token: token, Path: transactionPath}, http.StatusOK, nil)
+	c.call("GET /transactions/:id", request{Token: token, Path: transactionPath, Headers: map[string]string{"If-None-Match": rec.Header().Get("ETag")}}, http.StatusNotModified, nil)
+	c.call("PUT /transactions/:id", request{Token: token, Path: transactionPath, Body: newTransaction("Кофе с собой")}, http.StatusOK, nil)
+	c.call("PATCH /transactions/:id", request{Token: token, Path: transactionPath, Body: map[string]string{"notes": "по пути на работу"}, ContentType: mergePatch}, http.StatusOK, nil)
+
+	bulk := map[string]any{"operations": []map[string]any{
+		{"op": "create", "transaction": newTransaction("Обед")},
+		{"op": "update", "filter": map[string]string{"q": "обед"}, "set": map[string]string{"notes": "бизнес-ланч"}},
+	}}
+	c.call("POST /transactions/bulk", request{Token: token, Body: bulk}, http.StatusOK, nil)
+
+	// бюджеты
+	newBudget := func(amount float64) models.Budget {
+		return models.Budget{CategoryID: expenseID, Amount: amount, Period: models.BudgetPeriodMonthly, Month: date}
+	}
+	var budget models.Budget
+	c.call("POST /budgets", request{Token: token, Body: newBudget(5000)}, http.StatusCreated, &budget)
+	budgetPath := "/budgets/" + strconv.Itoa(budget.ID)
+	c.call("GET /budgets", request{Token: token}, http.StatusOK, nil)
+	rec = c.call("GET /budgets/:id", request{Token: token, Path: budgetPath}, http.StatusOK, nil)
+	c.call("GET /budgets/:id", request{Token: token, Path: budgetPath, Headers: map[string]string{"If-None-Match": rec.Header().Get("ETag")}}, http.StatusNotModified, nil)
+	c.call("PUT /budgets/:id", request{Token: token, Path: budgetPath, Body: newBudget(6000)}, http.StatusOK, nil)
+	c.call("PATCH /budgets/:id", request{Token: token, Path: budgetPath, Body: map[string]float64{"amount": 7000}, ContentType: mergePatch}, http.StatusOK, nil)
+
+	// отчеты и поиск
+	period := "?start_date=2026-03-01&end_date=2026-03-31"
+	c.call("GET /reports/financial", request{Token: token, Path: "/reports/financial" + period}, http.StatusOK, nil)
+	c.call("GET /reports/categories", request{Token: token, Path: "/reports/categories" + period}, http.StatusOK, nil)
+	c.call("GET /reports/budgets/:id", request{Token: token, Path: "/reports" + budgetPath}, http.StatusOK, nil)
+	c.call("GET /reports/annual/:year", request{Token: token, Path: "/reports/annual/2026"}, http.StatusOK, nil)
+	c.call("GET /reports/pivot", request{Token: token, Path: "/reports/pivot?rows=category&columns=month"}, http.StatusOK, nil)
+	c.call("GET /search", request{Token: token, Path: "/search?q=коф"}, http.StatusOK, nil)
+
+	// импорт
+	profile := models.ImportProfile{Name: "Банк", Delimiter: ";", HasHeader: true, DateColumn: "Дата", DateFormat: "DD.MM.YYYY",
+		AmountColumn: "Сумма", AmountConvention: models.AmountConventionSigned, DecimalSeparator: ",",
+		DescriptionColumn: "Описание", DefaultCategoryID: expenseID}
+	var created models.ImportProfile
+	c.call("POST /import/profiles", request{Token: token, Body: profile}, http.StatusCreated, &created)
+	profilePath := "/import/profiles/" + strconv.Itoa(created.ID)
+	c.call("GET /import/profiles", request{Token: token}, http.StatusOK, nil)
+	profile.Name = "Основной банк"
+	c.call("PUT /import/profiles/:id", request{Token: token, Path: profilePath, Body: profile}, http.StatusOK, nil)
+
+	qif := "!Type:Bank\nD03/12/2026\nT-120.00\nPТакси\n^\nD03/14/2026\nT3000.00\nPЗарплата\n^\n"
+	form := map[string]string{
+		"expense_category_id": strconv.Itoa(expenseID),
+		"income_category_id":  strconv.Itoa(incomeID),
+	}
+	body, contentType := multipartBody(t, "statement.qif", []byte(qif), form)
+	c.call("POST /import/:format", request{Token: token, Path: "/import/qif", Body: body, ContentType: contentType}, http.StatusOK, nil)
+	body, contentType = multipartBody(t, "statement.qif", []byte(qif), form)
+	c.call("POST /import/:format", request{Token: token, Path: "/import/qif?commit=true", Body: body, ContentType: contentType}, http.StatusCreated, nil)
+
+	// выгрузка
+	c.call("GET /export/transactions", request{Token: token, Path: "/export/transactions?format=jsonl"}, http.StatusOK, nil)
+	c.call("GET /export/journal", request{Token: token}, http.StatusOK, nil)
+
+	// книги
+	var ledgers []models.Ledger
+	c.call("GET /ledgers", request{Token: token}, http.StatusOK, &ledgers)
+	var ledger models.Ledger
+	c.call("POST /ledgers", request{Token: token, Body: map[string]string{"name": "Семья"}}, http.StatusCreated, &ledger)
+	ledgerPath := "/ledgers/" + strconv.Itoa(ledger.ID)
+	rec = c.call("GET /ledgers/:id", request{Token: token, Path: ledgerPath}, http.StatusOK, nil)
+	c.call("GET /ledgers/:id", request{Token: token, Path: ledgerPath, Headers: map[string]string{"If-None-Match": rec.Header().Get("ETag")}}, http.StatusNotModified, nil)
+	c.call("PUT /ledgers/:id", request{Token: token, Path: ledgerPath, Body: map[string]string{"name": "Семейный бюджет"}}, http.StatusOK, nil)
+
+	var invite models.LedgerInvite
+	c.call("POST /ledgers/:id/invites", request{Token: token, Path: ledgerPath + "/invites", Body: map[string]string{"role": models.LedgerRoleViewer}}, http.StatusCreated, &invite)
+	partner := c.signUp("partner@example.com")
+	c.call("POST /ledgers/join", request{Token: partner, Body: map[string]string{"code": invite.Code}}, http.StatusOK, &ledger)
+	memberPath := ledgerPath + "/members/" + strconv.Itoa(ledger.Members[len(ledger.Members)-1].UserID)
+	c.call("PUT /ledgers/:id/members/:user_id", request{Token: token, Path: memberPath, Body: map[string]string{"role": models.LedgerRoleEditor}}, http.StatusOK, nil)
+	c.call("DELETE /ledgers/:id/members/:user_id", request{Token: partner, Path: memberPath}, http.StatusOK, nil)
+	c.call("DELETE /ledgers/:id", request{Token: token, Path: ledgerPath}, http.StatusOK, nil)
+
+	// администрирование
+	rec = c.call("GET /admin/backup", request{Token: token}, http.StatusOK, nil)
+	archive := rec.Body.Bytes()
+	c.call("POST /admin/restore", request{Token: token, Path: "/admin/restore?mode=merge", Body: archive, ContentType: "application/gzip"}, http.StatusOK, nil)
+	var snapshot backup.SnapshotInfo
+	c.call("POST /admin/snapshots", request{Token: token}, http.StatusCreated, &snapshot)
+	c.call("GET /admin/snapshots", request{Token: token}, http.StatusOK, nil)
+	c.call("POST /admin/snapshots/:name/restore", request{Token: token, Path: "/admin/snapshots/" + snapshot.Name + "/restore?mode=merge"}, http.StatusOK, nil)
+
+	// удаление
+	c.call("DELETE /import/profiles/:id", request{Token: token, Path: profilePath}, http.StatusOK, nil)
+	c.call("DELETE /budgets/:id", request{Token: token, Path: budgetPath}, http.StatusOK, nil)
+	c.call("DELETE /transactions/:id", request{Token: token, Path: transactionPath}, http.StatusOK, nil)
+	c.call("DELETE /categories/:id", request{Token: token, Path: categoryPath}, http.StatusOK, nil)
+	c.call("POST /auth/logout", request{Token: token}, http.StatusOK, nil)
+
+	c.checkCoverage()
+}
+
+// specCheck прогоняет запросы через сервер и сверяет ответы с описанием операции в /openapi.json
+type specCheck struct {
+	*testServer
+	spec    map[string]any
+	covered map[string]bool
+}
+
+func newSpecCheck(t *testing.T) *specCheck {
+	s := newTestServer(t)
+
+	rec := s.expect(request{Method: http.MethodGet, Path: "/openapi.json"}, http.StatusOK, nil)
+	var spec map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &spec); err != nil {
+		t.Fatal(err)
+	}
+
+	return &specCheck{testServer: s, spec: spec, covered: map[string]bool{}}
+}
+
+// call выполняет запрос к маршруту route ("METHOD /path/:id"); путь запроса по умолчанию совпадает с маршрутом
+func (c *specCheck) call(route string, r request, status int, out any) *httptest.ResponseRecorder {
+	c.t.Helper()
+
+	method, path, _ := strings.Cut(route, " ")
+	r.Method = method
+	if r.Path == "" {
+		r.Path = path
+	}
+	rec := c.expect(r, status, out)
+	c.covered[route] = true
+
+	operation := c.operation(method, path)
+	response := object(object(operation["responses"])[strconv.Itoa(status)])
+	if response == nil {
+		c.t.Fatalf("%s: status %d is not documented", route, status)
+	}
+
+	content := object(response["content"])
+	if content == nil {
+		if rec.Body.Len() != 0 {
+			c.t.Errorf("%s: documented without body, got %q", route, rec.Body.String())
+		}
+		return rec
+	}
+
+	media := object(content["application/json"])
+	if media == nil {
+		// файлы: сверяем только тип содержимого, если он конкретный
+		for contentType := range content {
+			if contentType != "application/octet-stream" && !strings.HasPrefix(rec.Header().Get("Content-Type"), contentType) {
+				c.t.Errorf("%s: Content-Type %q, documented %q", route, rec.Header().Get("Content-Type"), contentType)
+			}
+		}
+		if rec.Body.Len() == 0 {
+			c.t.Errorf("%s: empty body", route)
+		}
+		return rec
+	}
+
+	var body any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		c.t.Fatalf("%s: %v", route, err)
+	}
+	for _, problem := range c.validate(object(media["schema"]), body, "body") {
+		c.t.Errorf("%s: %s", route, problem)
+	}
+
+	return rec
+}
+
+func (c *specCheck) operation(method, path string) map[string]any {
+	c.t.Helper()
+
+	specPath := path
+	for _, part := range strings.Split(path, "/") {
+		if strings.HasPrefix(part, ":") {
+			specPath = strings.Replace(specPath, part, "{"+part[1:]+"}", 1)
+		}
+	}
+
+	paths := object(c.spec["paths"])
+	operation := object(object(paths[specPath])[strings.ToLower(method)])
+	if operation == nil {
+		c.t.Fatalf("%s %s is not in the spec", method, path)
+	}
+	return operation
+}
+
+// checkCoverage требует, чтобы тест вызвал каждую операцию спецификации
+func (c *specCheck) checkCoverage() {
+	c.t.Helper()
+
+	var missing []string
+	for path, item := range object(c.spec["paths"]) {
+		if path == "/openapi.json" {
+			continue
+		}
+		for method := range object(item) {
+			route := strings.ToUpper(method) + " " + strings.NewReplacer("{", ":", "}", "").Replace(path)
+			if !c.covered[route] {
+				missing = append(missing, route)
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		c.t.Errorf("routes not exercised by the test:\n  %s", strings.Join(missing, "\n  "))
+	}
+}
+
+// validate проверяет значение по подмножеству JSON Schema, которое порождает api.Spec
+func (c *specCheck) validate(schema map[string]any, value any, at string) []string {
+	if ref, ok := schema["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/components/schemas/")
+		return c.validate(object(object(object(c.spec["components"])["schemas"])[name]), value, at)
+	}
+
+	if value == nil {
+		if schema["nullable"] == true || len(schema) == 0 {
+			return nil
+		}
+		if _, typed := schema["type"]; typed {
+			return []string{at + ": null is not allowed"}
+		}
+	}
+
+	var problems []string
+	if allOf, ok := schema["allOf"].([]any); ok {
+		for _, sub := range allOf {
+			problems = append(problems, c.validate(object(sub), value, at)...)
+		}
+		return problems
+	}
+
+	switch schema["type"] {
+	case "object":
+		fields, ok := value.(map[string]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected object, got %T", at, value)}
+		}
+
+		properties := object(schema["properties"])
+		if required, ok := schema["required"].([]any); ok {
+			for _, name := range required {
+				if _, present := fields[name.(string)]; !present {
+					problems = append(problems, fmt.Sprintf("%s: missing required field %q", at, name))
+				}
+			}
+		}
+		for name, field := range fields {
+			if property, documented := properties[name]; documented {
+				problems = append(problems, c.validate(object(property), field, at+"."+name)...)
+				continue
+			}
+			switch extra := schema["additionalProperties"].(type) {
+			case bool:
+				if !extra {
+					problems = append(problems, fmt.Sprintf("%s: undocumented field %q", at, name))
+				}
+			case map[string]any:
+				problems = append(problems, c.validate(extra, field, at+"."+name)...)
+			default:
+				problems = append(problems, fmt.Sprintf("%s: undocumented field %q", at, name))
+			}
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected array, got %T", at, value)}
+		}
+		for i, item := range items {
+			problems = append(problems, c.validate(object(schema["items"]), item, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected string, got %T", at, value)}
+		}
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339, text); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %q is not a date-time", at, text))
+			}
+		}
+	case "integer":
+		number, ok := value.(float64)
+		if !ok || number != float64(int64(number)) {
+			return []string{fmt.Sprintf("%s: expected integer, got %v", at, value)}
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return []string{fmt.Sprintf("%s: expected number, got %T", at, value)}
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return []string{fmt.Sprintf("%s: expected boolean, got %T", at, value)}
+		}
+	}
+
+	return problems
+}
+
+// object достает узел спецификации; nil, если его нет
+func object(value any) map[string]any {
+	m, _ := value.(map[string]any)
+	return m
+}
+
+func multipartBody(t *testing.T, filename string, data []byte, fields map[string]string) ([]byte, string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	for name, value := range fields {
+		if err := writer.WriteField(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := part.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes(), writer.FormDataContentType()
+}
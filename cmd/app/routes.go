@@ -0,0 +1,282 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/ChixXx1/expense-tracker/internal/api"
+	"github.com/ChixXx1/expense-tracker/internal/auth"
+	"github.com/ChixXx1/expense-tracker/internal/backup"
+	"github.com/ChixXx1/expense-tracker/internal/handlers"
+	"github.com/ChixXx1/expense-tracker/internal/importer"
+	"github.com/ChixXx1/expense-tracker/internal/models"
+	"github.com/ChixXx1/expense-tracker/internal/search"
+)
+
+const apiBasePath = "/api/v1"
+
+// deprecatedPrefixes - разделы, которые до /api/v1 отвечали из корня. Старые пути перенаправляются
+// на /api/v1 хотя бы один выпуск, чтобы клиенты успели перейти.
+var deprecatedPrefixes = []string{"/categories", "/transactions", "/budgets", "/reports", "/import", "/export", "/admin"}
+
+const mergePatch = "application/merge-patch+json"
+
+type appHandlers struct {
+	categories   *handlers.CategoryHandler
+	transactions *handlers.TransactionHandler
+	budgets      *handlers.BudgetHandler
+	reports      *handlers.ReportHandler
+	imports      *handlers.ImportHandler
+	exports      *handlers.ExportHandler
+	admin        *handlers.AdminHandler
+	search       *handlers.SearchHandler
+	auth         *handlers.AuthHandler
+	ledgers      *handlers.LedgerHandler
+}
+
+var transactionFilterParams = []api.Param{
+	{Name: "start_date", Type: "string", Description: "YYYY-MM-DD"},
+	{Name: "end_date", Type: "string", Description: "YYYY-MM-DD"},
+	{Name: "category_id", Type: "string", Description: "comma-separated IDs, a leading ! excludes them"},
+	{Name: "type", Type: "string", Description: "income or expense, a leading ! excludes"},
+	{Name: "payment_method", Type: "string", Description: "comma-separated cash, card or transfer, a leading ! excludes them"},
+	{Name: "min_amount", Type: "number"},
+	{Name: "max_amount", Type: "number"},
+	{Name: "q", Type: "string", Description: "case-insensitive search in description, all words must match"},
+	{Name: "created_after", Type: "string", Description: "RFC 3339 or YYYY-MM-DD"},
+	{Name: "uncategorized", Type: "boolean", Description: "only transactions whose category was deleted"},
+	{Name: "limit", Type: "integer"},
+	{Name: "offset", Type: "integer"},
+	{Name: "sort", Type: "string", Description: "date (default), amount or created_at"},
+	{Name: "order", Type: "string", Description: "asc or desc (default)"},
+	{Name: "cursor", Type: "string", Description: "next_cursor or prev_cursor from the previous page"},
+}
+
+var periodParams = []api.Param{
+	{Name: "start_date", Type: "string", Description: "YYYY-MM-DD", Required: true},
+	{Name: "end_date", Type: "string", Description: "YYYY-MM-DD", Required: true},
+}
+
+var restoreModeParam = api.Param{Name: "mode", Type: "string", Description: "replace (default) or merge"}
+
+var restoreLedgerParam = api.Param{Name: "ledger_id", Type: "integer",
+	Description: "restore only into this ledger; required for schema version 1 archives, which have no ledgers"}
+
+var ifMatch = []api.Param{{Name: "If-Match", Type: "string", Description: "ETag of the version being changed, 412 on mismatch"}}
+
+var idempotencyKey = api.Param{Name: handlers.IdempotencyKeyHeader, Type: "string",
+	Description: "repeats with the same key and body replay the first response, a different body gets 422"}
+
+var ledgerHeader = api.Param{Name: handlers.LedgerHeader, Type: "integer",
+	Description: "ledger to work in, the personal ledger if omitted"}
+
+var ifNoneMatch = []api.Param{{Name: "If-None-Match", Type: "string", Description: "ETag the client already has, 304 if unchanged"}}
+
+// cached - ответ GET по ID, поддерживающий условный запрос
+func cached(data any) []api.Response {
+	return []api.Response{
+		{Status: http.StatusOK, Data: data},
+		{Status: http.StatusNotModified, Description: "not modified", NoContent: true},
+	}
+}
+
+func ok(data any) []api.Response {
+	return []api.Response{{Status: http.StatusOK, Data: data}}
+}
+
+func created(data any) []api.Response {
+	return []api.Response{{Status: http.StatusCreated, Data: data}}
+}
+
+func withFilters(params ...api.Param) []api.Param {
+	return append(append([]api.Param{}, transactionFilterParams...), params...)
+}
+
+// apiRoutes - единственный источник правды для маршрутов /api/v1 и спецификации OpenAPI
+func apiRoutes(h appHandlers) []api.Route {
+	routes := []api.Route{
+		{Method: http.MethodPost, Path: "/auth/register", Tag: "auth", Summary: "Create a user account",
+			Body: handlers.CredentialsRequest{}, Responses: created(handlers.UserResponse{}), Handler: h.auth.Register, Public: true},
+		{Method: http.MethodPost, Path: "/auth/login", Tag: "auth", Summary: "Log in and get access and refresh tokens",
+			Body: handlers.CredentialsRequest{}, Responses: ok(handlers.Session{}), Handler: h.auth.Login, Public: true},
+		{Method: http.MethodPost, Path: "/auth/refresh", Tag: "auth", Summary: "Exchange a refresh token for a new token pair",
+			Body: handlers.RefreshRequest{}, Responses: ok(auth.Tokens{}), Handler: h.auth.Refresh, Public: true},
+		{Method: http.MethodPost, Path: "/auth/logout", Tag: "auth", Summary: "Revoke the current session or all sessions",
+			Body: handlers.LogoutRequest{}, Responses: ok(nil), Handler: h.auth.Logout, Account: true},
+		{Method: http.MethodGet, Path: "/auth/me", Tag: "auth", Summary: "Get the current user",
+			Responses: ok(handlers.UserResponse{}), Handler: h.auth.Me, Account: true},
+
+		{Method: http.MethodGet, Path: "/ledgers", Tag: "ledgers", Summary: "List ledgers the user is a member of",
+			Responses: ok([]models.Ledger{}), Handler: h.ledgers.GetLedgers, Account: true},
+		{Method: http.MethodPost, Path: "/ledgers", Tag: "ledgers", Summary: "Create a shared ledger",
+			Body: handlers.LedgerRequest{}, Responses: created(models.Ledger{}), Handler: h.ledgers.CreateLedger, Account: true},
+		{Method: http.MethodPost, Path: "/ledgers/join", Tag: "ledgers", Summary: "Join a ledger by invite code",
+			Body: handlers.JoinRequest{}, Responses: ok(models.Ledger{}), Handler: h.ledgers.JoinLedger, Account: true},
+		{Method: http.MethodGet, Path: "/ledgers/:id", Tag: "ledgers", Summary: "Get a ledger with its members",
+			Headers: ifNoneMatch, Responses: cached(models.Ledger{}), Handler: h.ledgers.GetLedgerByID, Account: true},
+		{Method: http.MethodPut, Path: "/ledgers/:id", Tag: "ledgers", Summary: "Rename a ledger (owner only)",
+			Body: handlers.LedgerRequest{}, Headers: ifMatch, Responses: ok(models.Ledger{}), Handler: h.ledgers.UpdateLedger, Account: true},
+		{Method: http.MethodDelete, Path: "/ledgers/:id", Tag: "ledgers", Summary: "Delete a shared ledger with all its data (owner only)",
+			Headers: ifMatch, Responses: ok(nil), Handler: h.ledgers.DeleteLedger, Account: true},
+		{Method: http.MethodPost, Path: "/ledgers/:id/invites", Tag: "ledgers", Summary: "Create a single-use invite code (owner only)",
+			Body: handlers.InviteRequest{}, Responses: created(models.LedgerInvite{}), Handler: h.ledgers.CreateInvite, Account: true},
+		{Method: http.MethodPut, Path: "/ledgers/:id/members/:user_id", Tag: "ledgers", Summary: "Change a member's role (owner only)",
+			Body: handlers.MemberRequest{}, Responses: ok(models.Ledger{}), Handler: h.ledgers.UpdateMember, Account: true},
+		{Method: http.MethodDelete, Path: "/ledgers/:id/members/:user_id", Tag: "ledgers", Summary: "Remove a member or leave the ledger",
+			Responses: ok(models.Ledger{}), Handler: h.ledgers.RemoveMember, Account: true},
+
+		{Method: http.MethodGet, Path: "/categories", Tag: "categories", Summary: "List categories",
+			Responses: ok([]models.Category{}), Handler: h.categories.GetCategories},
+		{Method: http.MethodGet, Path: "/categories/:id", Tag: "categories", Summary: "Get a category",
+			Headers: ifNoneMatch, Responses: cached(models.Category{}), Handler: h.categories.GetCategoryByID},
+		{Method: http.MethodPost, Path: "/categories", Tag: "categories", Summary: "Create a category",
+			Body: models.Category{}, Responses: created(models.Category{}), Handler: h.categories.CreateCategory},
+		{Method: http.MethodPut, Path: "/categories/:id", Tag: "categories", Summary: "Replace a category",
+			Body: models.Category{}, Headers: ifMatch, Responses: ok(models.Category{}), Handler: h.categories.UpdateCategory},
+		{Method: http.MethodPatch, Path: "/categories/:id", Tag: "categories", Summary: "Update a category with JSON Merge Patch",
+			Body: models.Category{}, BodyType: mergePatch, Headers: ifMatch, Responses: ok(models.Category{}), Handler: h.categories.PatchCategory},
+		{Method: http.MethodDelete, Path: "/categories/:id", Tag: "categories", Summary: "Delete a category",
+			Query:   []api.Param{{Name: "reassign_to", Type: "integer", Description: "move transactions and budgets to this category first"}},
+			Headers: ifMatch, Responses: ok(nil), Handler: h.categories.DeleteCategory},
+
+		{Method: http.MethodGet, Path: "/transactions", Tag: "transactions", Summary: "List transactions",
+			Query: transactionFilterParams, Responses: ok([]models.Transaction{}), Handler: h.transactions.GetTransactions},
+		{Method: http.MethodGet, Path: "/transactions/duplicates", Tag: "transactions", Summary: "List likely duplicate pairs",
+			Responses: ok([]models.DuplicatePair{}), Handler: h.transactions.GetDuplicates},
+		{Method: http.MethodPost, Path: "/transactions/duplicates/merge", Tag: "transactions", Summary: "Merge two duplicates",
+			Body: handlers.MergeDuplicatesRequest{}, Responses: ok(models.Transaction{}), Handler: h.transactions.MergeDuplicates},
+		{Method: http.MethodPost, Path: "/transactions/duplicates/dismiss", Tag: "transactions", Summary: "Mark a pair as not duplicate",
+			Body: handlers.DismissDuplicateRequest{}, Responses: ok(nil), Handler: h.transactions.DismissDuplicate},
+		{Method: http.MethodPost, Path: "/transactions/bulk", Tag: "transactions", Summary: "Create, update and delete transactions in one atomic batch",
+			Body: handlers.BulkRequest{}, Responses: ok([]models.BulkResult{}), Handler: h.transactions.Bulk},
+		{Method: http.MethodGet, Path: "/transactions/:id", Tag: "transactions", Summary: "Get a transaction",
+			Headers: ifNoneMatch, Responses: cached(models.Transaction{}), Handler: h.transactions.GetTransactionByID},
+		{Method: http.MethodPost, Path: "/transactions", Tag: "transactions", Summary: "Create a transaction",
+			Query: []api.Param{{Name: "force", Type: "boolean", Description: "create even if a likely duplicate exists"}},
+			Body:  models.Transaction{}, Responses: created(models.Transaction{}), Handler: h.transactions.CreateTransaction},
+		{Method: http.MethodPut, Path: "/transactions/:id", Tag: "transactions", Summary: "Replace a transaction",
+			Body: models.Transaction{}, Headers: ifMatch, Responses: ok(models.Transaction{}), Handler: h.transactions.UpdateTransaction},
+		{Method: http.MethodPatch, Path: "/transactions/:id", Tag: "transactions", Summary: "Update a transaction with JSON Merge Patch",
+			Body: models.Transaction{}, BodyType: mergePatch, Headers: ifMatch, Responses: ok(models.Transaction{}), Handler: h.transactions.PatchTransaction},
+		{Method: http.MethodDelete, Path: "/transactions/:id", Tag: "transactions", Summary: "Delete a transaction",
+			Headers: ifMatch, Responses: ok(nil), Handler: h.transactions.DeleteTransaction},
+
+		{Method: http.MethodGet, Path: "/budgets", Tag: "budgets", Summary: "List budgets",
+			Query: []api.Param{
+				{Name: "category_id", Type: "integer"},
+				{Name: "period", Type: "string", Description: "monthly, weekly or yearly"},
+				{Name: "month", Type: "string", Description: "YYYY-MM"},
+			},
+			Responses: ok([]models.Budget{}), Handler: h.budgets.GetBudgets},
+		{Method: http.MethodGet, Path: "/budgets/:id", Tag: "budgets", Summary: "Get a budget",
+			Headers: ifNoneMatch, Responses: cached(models.Budget{}), Handler: h.budgets.GetBudgetByID},
+		{Method: http.MethodPost, Path: "/budgets", Tag: "budgets", Summary: "Create a budget",
+			Body: models.Budget{}, Responses: created(models.Budget{}), Handler: h.budgets.CreateBudget},
+		{Method: http.MethodPut, Path: "/budgets/:id", Tag: "budgets", Summary: "Replace a budget",
+			Body: models.Budget{}, Headers: ifMatch, Responses: ok(models.Budget{}), Handler: h.budgets.UpdateBudget},
+		{Method: http.MethodPatch, Path: "/budgets/:id", Tag: "budgets", Summary: "Update a budget with JSON Merge Patch",
+			Body: models.Budget{}, BodyType: mergePatch, Headers: ifMatch, Responses: ok(models.Budget{}), Handler: h.budgets.PatchBudget},
+		{Method: http.MethodDelete, Path: "/budgets/:id", Tag: "budgets", Summary: "Delete a budget",
+			Headers: ifMatch, Responses: ok(nil), Handler: h.budgets.DeleteBudget},
+
+		{Method: http.MethodGet, Path: "/reports/financial", Tag: "reports", Summary: "Income, expenses and balance for a period",
+			Query: periodParams, Responses: ok(models.FinancialSummary{}), Handler: h.reports.GetFinancialSummary},
+		{Method: http.MethodGet, Path: "/reports/categories", Tag: "reports", Summary: "Totals by category for a period",
+			Query: periodParams, Responses: ok([]models.CategorySummary{}), Handler: h.reports.GetCategorySummary},
+		{Method: http.MethodGet, Path: "/reports/budgets/:id", Tag: "reports", Summary: "Budget usage",
+			Responses: ok(models.BudgetReport{}), Handler: h.reports.GetBudgetReport},
+		{Method: http.MethodGet, Path: "/reports/annual/:year", Tag: "reports", Summary: "Annual report with year-over-year comparison",
+			Responses: ok(models.AnnualReport{}), Handler: h.reports.GetAnnualReport},
+		{Method: http.MethodGet, Path: "/reports/pivot", Tag: "reports", Summary: "Pivot table over transactions",
+			Query: withFilters(
+				api.Param{Name: "rows", Type: "string", Description: "comma-separated dimensions", Required: true},
+				api.Param{Name: "columns", Type: "string", Description: "comma-separated dimensions"},
+				api.Param{Name: "measures", Type: "string", Description: "comma-separated: sum, count, avg, min, max"},
+			),
+			Responses: ok(models.PivotTable{}), Handler: h.reports.GetPivot},
+
+		{Method: http.MethodGet, Path: "/search", Tag: "search", Summary: "Full-text search over descriptions, notes and category names",
+			Query: []api.Param{
+				{Name: "q", Type: "string", Description: "words or word prefixes, all must match", Required: true},
+				{Name: "limit", Type: "integer", Description: "1-100, default 20"},
+			},
+			Responses: ok([]search.Hit{}), Handler: h.search.Search},
+
+		{Method: http.MethodPost, Path: "/import/:format", Tag: "import", Summary: "Preview or import a bank statement",
+			Query: []api.Param{
+				{Name: "commit", Type: "boolean", Description: "create transactions instead of previewing (editor or owner); a preview is read-only"},
+				{Name: "skip_invalid", Type: "boolean"},
+				{Name: "force", Type: "boolean", Description: "import likely duplicates too"},
+			},
+			Form: []api.Param{
+				{Name: "file", Type: "file", Required: true},
+				{Name: "profile_id", Type: "integer", Description: "csv only"},
+				{Name: "profile", Type: "string", Description: "csv only, inline profile JSON"},
+				{Name: "account", Type: "string"},
+				{Name: "payment_method", Type: "string"},
+				{Name: "date_format", Type: "string"},
+				{Name: "expense_category_id", Type: "integer"},
+				{Name: "income_category_id", Type: "integer"},
+			},
+			Responses: []api.Response{
+				{Status: http.StatusOK, Description: "preview", Data: []importer.ParsedRow{}},
+				{Status: http.StatusCreated, Description: "imported", Data: []models.Transaction{}},
+			},
+			Handler: h.imports.Import, ReadOnly: true},
+		{Method: http.MethodGet, Path: "/import/profiles", Tag: "import", Summary: "List CSV import profiles",
+			Responses: ok([]models.ImportProfile{}), Handler: h.imports.GetProfiles},
+		{Method: http.MethodPost, Path: "/import/profiles", Tag: "import", Summary: "Create a CSV import profile",
+			Body: models.ImportProfile{}, Responses: created(models.ImportProfile{}), Handler: h.imports.CreateProfile},
+		{Method: http.MethodPut, Path: "/import/profiles/:id", Tag: "import", Summary: "Replace a CSV import profile",
+			Body: models.ImportProfile{}, Headers: ifMatch, Responses: ok(models.ImportProfile{}), Handler: h.imports.UpdateProfile},
+		{Method: http.MethodDelete, Path: "/import/profiles/:id", Tag: "import", Summary: "Delete a CSV import profile",
+			Headers: ifMatch, Responses: ok(nil), Handler: h.imports.DeleteProfile},
+
+		{Method: http.MethodGet, Path: "/export/transactions", Tag: "export", Summary: "Export transactions as CSV, JSON Lines or XLSX",
+			Query: withFilters(
+				api.Param{Name: "format", Type: "string", Description: "csv (default), jsonl or xlsx"},
+				api.Param{Name: "columns", Type: "string", Description: "comma-separated column list"},
+				api.Param{Name: "date_format", Type: "string"},
+				api.Param{Name: "decimal_separator", Type: "string"},
+				api.Param{Name: "delimiter", Type: "string"},
+			),
+			Responses: []api.Response{{Status: http.StatusOK, ContentType: "application/octet-stream"}},
+			Handler:   h.exports.ExportTransactions},
+		{Method: http.MethodGet, Path: "/export/journal", Tag: "export", Summary: "Export a ledger, hledger or beancount journal",
+			Query: withFilters(
+				api.Param{Name: "format", Type: "string", Description: "ledger (default), hledger or beancount"},
+				api.Param{Name: "currency", Type: "string"},
+			),
+			Responses: []api.Response{{Status: http.StatusOK, ContentType: "text/plain"}},
+			Handler:   h.exports.ExportJournal},
+
+		{Method: http.MethodGet, Path: "/admin/backup", Tag: "admin", Summary: "Download a full backup archive",
+			Responses: []api.Response{{Status: http.StatusOK, ContentType: "application/gzip"}},
+			Handler:   h.admin.GetBackup, Admin: true},
+		{Method: http.MethodPost, Path: "/admin/restore", Tag: "admin", Summary: "Verify and restore a backup archive",
+			Query:     []api.Param{restoreModeParam, restoreLedgerParam},
+			Form:      []api.Param{{Name: "file", Type: "file", Required: true, Description: "or send the archive as the raw body"}},
+			Responses: ok(models.RestoreResult{}), Handler: h.admin.Restore, Admin: true},
+		{Method: http.MethodGet, Path: "/admin/snapshots", Tag: "admin", Summary: "List scheduled snapshots",
+			Responses: ok([]backup.SnapshotInfo{}), Handler: h.admin.GetSnapshots, Admin: true},
+		{Method: http.MethodPost, Path: "/admin/snapshots", Tag: "admin", Summary: "Take a snapshot now",
+			Responses: created(backup.SnapshotInfo{}), Handler: h.admin.CreateSnapshot, Admin: true},
+		{Method: http.MethodPost, Path: "/admin/snapshots/:name/restore", Tag: "admin", Summary: "Restore a snapshot",
+			Query: []api.Param{restoreModeParam}, Responses: ok(models.RestoreResult{}), Handler: h.admin.RestoreSnapshot, Admin: true},
+	}
+
+	// Idempotency-Key обрабатывает middleware группы для каждого POST вошедшего пользователя
+	for i := range routes {
+		if routes[i].Method == http.MethodPost && !routes[i].Public {
+			routes[i].Headers = append(append([]api.Param{}, routes[i].Headers...), idempotencyKey)
+		}
+	}
+
+	// данные книги выбирает RequireLedger по заголовку X-Ledger-ID
+	for i := range routes {
+		if !routes[i].Public && !routes[i].Account && !routes[i].Admin {
+			routes[i].Headers = append(append([]api.Param{}, routes[i].Headers...), ledgerHeader)
+		}
+	}
+
+	return routes
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Пути до /api/v1 еще работают: перенаправляются на новые с тем же методом и пометкой об устаревании
+func TestDeprecatedRoutesRedirect(t *testing.T) {
+	s := newTestServer(t)
+
+	cases := []struct {
+		method, path, location string
+	}{
+		{http.MethodGet, "/transactions?limit=5", apiBasePath + "/transactions?limit=5"},
+		{http.MethodPost, "/categories", apiBasePath + "/categories"},
+		{http.MethodDelete, "/budgets/7", apiBasePath + "/budgets/7"},
+		{http.MethodPost, "/import/csv?preview=true", apiBasePath + "/import/csv?preview=true"},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		s.engine.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, nil))
+
+		if rec.Code != http.StatusPermanentRedirect {
+			t.Errorf("%s %s: status %d, want %d", tc.method, tc.path, rec.Code, http.StatusPermanentRedirect)
+		}
+		if location := rec.Header().Get("Location"); location != tc.location {
+			t.Errorf("%s %s: Location %q, want %q", tc.method, tc.path, location, tc.location)
+		}
+		if rec.Header().Get("Deprecation") != "true" {
+			t.Errorf("%s %s: no Deprecation header", tc.method, tc.path)
+		}
+	}
+
+	// разделы, появившиеся уже под /api/v1, в корне не отвечают
+	rec := httptest.NewRecorder()
+	s.engine.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/auth/login", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("POST /auth/login: status %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
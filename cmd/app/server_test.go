@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ChixXx1/expense-tracker/internal/auth"
+	"github.com/ChixXx1/expense-tracker/internal/backup"
+	"github.com/ChixXx1/expense-tracker/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	testAdminEmail = "admin@example.com"
+	testPassword   = "password123"
+)
+
+type testServer struct {
+	t       *testing.T
+	engine  *gin.Engine
+	storage *database.JSONStorage
+}
+
+// request - запрос к тестовому серверу; Body сериализуется в JSON, если это не []byte
+type request struct {
+	Method      string
+	Path        string
+	Token       string
+	Body        any
+	ContentType string
+	Headers     map[string]string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+
+	dir := t.TempDir()
+	storage := database.NewJSONStorage(filepath.Join(dir, "data.json"))
+
+	engine, _, err := newServer(storage, serverConfig{
+		auth: auth.Config{
+			Secret:      []byte(strings.Repeat("s", 32)),
+			AccessTTL:   time.Hour,
+			RefreshTTL:  time.Hour,
+			AdminEmails: map[string]bool{testAdminEmail: true},
+		},
+		snapshots:      backup.SnapshotConfig{Dir: filepath.Join(dir, "backups")},
+		idempotencyTTL: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testServer{t: t, engine: engine, storage: storage}
+}
+
+func (s *testServer) do(r request) *httptest.ResponseRecorder {
+	s.t.Helper()
+
+	var body io.Reader
+	switch value := r.Body.(type) {
+	case nil:
+	case []byte:
+		body = bytes.NewReader(value)
+	default:
+		data, err := json.Marshal(value)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		body = bytes.NewReader(data)
+		if r.ContentType == "" {
+			r.ContentType = "application/json"
+		}
+	}
+
+	req := httptest.NewRequest(r.Method, apiBasePath+r.Path, body)
+	if r.ContentType != "" {
+		req.Header.Set("Content-Type", r.ContentType)
+	}
+	if r.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.Token)
+	}
+	for name, value := range r.Headers {
+		req.Header.Set(name, value)
+	}
+
+	rec := httptest.NewRecorder()
+	s.engine.ServeHTTP(rec, req)
+	return rec
+}
+
+// expect выполняет запрос и падает, если статус не совпал; data из ответа раскладывается в out
+func (s *testServer) expect(r request, status int, out any) *httptest.ResponseRecorder {
+	s.t.Helper()
+
+	rec := s.do(r)
+	if rec.Code != status {
+		s.t.Fatalf("%s %s: status %d, want %d: %s", r.Method, r.Path, rec.Code, status, rec.Body.String())
+	}
+	if out != nil {
+		var envelope struct {
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &envelope); err != nil {
+			s.t.Fatalf("%s %s: %v", r.Method, r.Path, err)
+		}
+		if err := json.Unmarshal(envelope.Data, out); err != nil {
+			s.t.Fatalf("%s %s: %v", r.Method, r.Path, err)
+		}
+	}
+	return rec
+}
+
+// createAdmin заводит администратора так же, как cmd/admin create-admin
+func (s *testServer) createAdmin(email string) {
+	s.t.Helper()
+
+	if _, err := auth.NewService(s.storage, auth.Config{}).RegisterAdmin(context.Background(), email, testPassword); err != nil {
+		s.t.Fatal(err)
+	}
+}
+
+// signUp регистрирует пользователя и возвращает его access-токен
+func (s *testServer) signUp(email string) string {
+	s.t.Helper()
+
+	credentials := map[string]string{"email": email, "password": testPassword}
+	s.expect(request{Method: http.MethodPost, Path: "/auth/register", Body: credentials}, http.StatusCreated, nil)
+	return s.login(email)
+}
+
+// login входит с testPassword и возвращает access-токен
+func (s *testServer) login(email string) string {
+	s.t.Helper()
+
+	credentials := map[string]string{"email": email, "password": testPassword}
+	var session struct {
+		Tokens auth.Tokens `json:"tokens"`
+	}
+	s.expect(request{Method: http.MethodPost, Path: "/auth/login", Body: credentials}, http.StatusOK, &session)
+	return session.Tokens.AccessToken
+}
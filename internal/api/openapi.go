@@ -0,0 +1,342 @@
+package api
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const OpenAPIVersion = "3.0.3"
+
+type Info struct {
+	Title   string
+	Version string
+}
+
+// Spec строит документ OpenAPI 3 по таблице маршрутов. Схемы тел запросов и ответов
+// выводятся рефлексией из типов моделей, поэтому не расходятся с json-тегами.
+func Spec(info Info, basePath string, routes []Route) map[string]any {
+	builder := &schemaBuilder{components: map[string]any{}}
+
+	paths := map[string]map[string]any{}
+	for _, route := range routes {
+		path, pathParams := openAPIPath(route.Path)
+		if paths[path] == nil {
+			paths[path] = map[string]any{}
+		}
+
+		parameters := []any{}
+		for _, name := range pathParams {
+			parameters = append(parameters, map[string]any{
+				"name":     name,
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]any{"type": pathParamType(name)},
+			})
+		}
+		for _, param := range route.Query {
+			parameters = append(parameters, parameter(param, "query"))
+		}
+		for _, param := range route.Headers {
+			parameters = append(parameters, parameter(param, "header"))
+		}
+
+		operation := map[string]any{
+			"operationId": operationID(route),
+			"summary":     route.Summary,
+			"tags":        []string{route.Tag},
+			"parameters":  parameters,
+			"responses":   builder.responses(route.Responses),
+		}
+		if !route.Public {
+			operation["security"] = []any{map[string]any{"bearerAuth": []string{}}}
+		}
+
+		if route.Body != nil {
+			bodyType := route.BodyType
+			if bodyType == "" {
+				bodyType = "application/json"
+			}
+			operation["requestBody"] = map[string]any{
+				"required": true,
+				"content": map[string]any{
+					bodyType: map[string]any{
+						"schema": builder.schemaFor(reflect.TypeOf(route.Body)),
+					},
+				},
+			}
+		} else if len(route.Form) > 0 {
+			operation["requestBody"] = map[string]any{
+				"required": true,
+				"content": map[string]any{
+					"multipart/form-data": map[string]any{
+						"schema": formSchema(route.Form),
+					},
+				},
+			}
+		}
+
+		paths[path][strings.ToLower(route.Method)] = operation
+	}
+
+	builder.components["Problem"] = map[string]any{
+		"type":        "object",
+		"description": "RFC 7807 problem details",
+		"required":    []string{"type", "title", "status", "code"},
+		"properties": map[string]any{
+			"type":     map[string]any{"type": "string"},
+			"title":    map[string]any{"type": "string"},
+			"status":   map[string]any{"type": "integer"},
+			"detail":   map[string]any{"type": "string"},
+			"instance": map[string]any{"type": "string"},
+			"code":     map[string]any{"type": "string"},
+			"errors": map[string]any{
+				"type": "array",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"field":   map[string]any{"type": "string"},
+						"message": map[string]any{"type": "string"},
+					},
+				},
+			},
+		},
+		"additionalProperties": true,
+	}
+
+	return map[string]any{
+		"openapi": OpenAPIVersion,
+		"info": map[string]any{
+			"title":   info.Title,
+			"version": info.Version,
+		},
+		"servers": []any{
+			map[string]any{"url": basePath},
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": builder.components,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+	}
+}
+
+func parameter(param Param, in string) map[string]any {
+	return map[string]any{
+		"name":        param.Name,
+		"in":          in,
+		"required":    param.Required,
+		"description": param.Description,
+		"schema":      map[string]any{"type": param.Type},
+	}
+}
+
+// openAPIPath переводит /budgets/:id в /budgets/{id}
+func openAPIPath(path string) (string, []string) {
+	var params []string
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, ":") || strings.HasPrefix(part, "*") {
+			params = append(params, part[1:])
+			parts[i] = "{" + part[1:] + "}"
+		}
+	}
+	return strings.Join(parts, "/"), params
+}
+
+func pathParamType(name string) string {
+	if name == "id" || strings.HasSuffix(name, "_id") || name == "year" {
+		return "integer"
+	}
+	return "string"
+}
+
+// operationID собирается из метода и пути: GET /budgets/:id -> getBudgetsById
+func operationID(route Route) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(route.Method))
+	for _, part := range strings.FieldsFunc(route.Path, func(r rune) bool {
+		return r == '/' || r == ':' || r == '_' || r == '-' || r == '.'
+	}) {
+		if part == "" {
+			continue
+		}
+		if strings.Contains(route.Path, ":"+part) {
+			b.WriteString("By")
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+func formSchema(fields []Param) map[string]any {
+	properties := map[string]any{}
+	required := []string{}
+	for _, field := range fields {
+		schema := map[string]any{"type": field.Type, "description": field.Description}
+		if field.Type == "file" {
+			schema = map[string]any{"type": "string", "format": "binary", "description": field.Description}
+		}
+		properties[field.Name] = schema
+		if field.Required {
+			required = append(required, field.Name)
+		}
+	}
+
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+type schemaBuilder struct {
+	components map[string]any
+}
+
+func (b *schemaBuilder) responses(responses []Response) map[string]any {
+	result := map[string]any{
+		"default": map[string]any{
+			"description": "error",
+			"content": map[string]any{
+				"application/problem+json": map[string]any{
+					"schema": map[string]any{"$ref": "#/components/schemas/Problem"},
+				},
+			},
+		},
+	}
+
+	for _, response := range responses {
+		description := response.Description
+		if description == "" {
+			description = http.StatusText(response.Status)
+		}
+
+		if response.NoContent {
+			result[strconv.Itoa(response.Status)] = map[string]any{"description": description}
+			continue
+		}
+
+		if response.ContentType != "" {
+			result[strconv.Itoa(response.Status)] = map[string]any{
+				"description": description,
+				"content": map[string]any{
+					response.ContentType: map[string]any{
+						"schema": map[string]any{"type": "string", "format": "binary"},
+					},
+				},
+			}
+			continue
+		}
+
+		data := map[string]any{"nullable": true}
+		if response.Data != nil {
+			data = b.schemaFor(reflect.TypeOf(response.Data))
+		}
+
+		result[strconv.Itoa(response.Status)] = map[string]any{
+			"description": description,
+			"content": map[string]any{
+				"application/json": map[string]any{
+					"schema": map[string]any{
+						"type":     "object",
+						"required": []string{"data"},
+						"properties": map[string]any{
+							"data": data,
+							"meta": map[string]any{"type": "object", "additionalProperties": true},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	return result
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (b *schemaBuilder) schemaFor(t reflect.Type) map[string]any {
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Pointer:
+		schema := b.schemaFor(t.Elem())
+		if _, isRef := schema["$ref"]; isRef {
+			return map[string]any{"allOf": []any{schema}, "nullable": true}
+		}
+		schema["nullable"] = true
+		return schema
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		schema := map[string]any{"type": "array", "items": b.schemaFor(t.Elem())}
+		if t.Kind() == reflect.Array {
+			schema["minItems"] = t.Len()
+			schema["maxItems"] = t.Len()
+		}
+		return schema
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		name := t.Name()
+		if _, ok := b.components[name]; !ok {
+			// заглушка защищает от бесконечной рекурсии на ссылающихся друг на друга типах
+			b.components[name] = map[string]any{}
+			b.components[name] = b.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	default:
+		return map[string]any{}
+	}
+}
+
+func (b *schemaBuilder) structSchema(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	required := []string{}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = b.schemaFor(field.Type)
+		if !strings.Contains(options, "omitempty") && field.Type.Kind() != reflect.Pointer {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Param - query-параметр или поле multipart-формы
+type Param struct {
+	Name        string
+	Type        string // string, integer, number, boolean, file
+	Description string
+	Required    bool
+}
+
+// Response описывает один из ответов маршрута.
+// Data - нулевое значение типа из поля data конверта; для ответов без конверта задается ContentType.
+type Response struct {
+	Status      int
+	Description string
+	Data        any
+	ContentType string
+	NoContent   bool
+}
+
+// Route связывает обработчик с его описанием: из одной таблицы регистрируются маршруты и строится спецификация
+type Route struct {
+	Method    string
+	Path      string
+	Tag       string
+	Summary   string
+	Query     []Param
+	Headers   []Param
+	Body      any
+	BodyType  string // по умолчанию application/json
+	Form      []Param
+	Responses []Response
+	Handler   gin.HandlerFunc
+	// Public - маршрут доступен без входа (регистрация, логин)
+	Public bool
+	// Account - маршрут требует входа, но не работает с данными книги (профиль, сами книги)
+	Account bool
+	// Admin - маршрут только для администраторов, работает со всеми книгами сразу
+	Admin bool
+	// ReadOnly - POST без побочных эффектов (предпросмотр): в книге для него хватает роли viewer
+	ReadOnly bool
+}
+
+// Register регистрирует маршруты группы; middleware выдает для маршрута обработчики, которые встают перед ним
+func Register(group gin.IRoutes, routes []Route, middleware func(Route) []gin.HandlerFunc) {
+	for _, route := range routes {
+		chain := append(middleware(route), route.Handler)
+		group.Handle(route.Method, route.Path, chain...)
+	}
+}
+
+// RegisterDeprecated оставляет в корне старые пути маршрутов, чьи пути начинаются с одного из prefixes:
+// такой запрос получает 308 на тот же путь под basePath. 308, в отличие от 301, сохраняет метод и тело,
+// а заголовки Deprecation и Link подсказывают клиенту новый адрес.
+func RegisterDeprecated(engine gin.IRoutes, basePath string, routes []Route, prefixes []string) {
+	redirect := func(ctx *gin.Context) {
+		location := basePath + ctx.Request.URL.Path
+		if ctx.Request.URL.RawQuery != "" {
+			location += "?" + ctx.Request.URL.RawQuery
+		}
+		ctx.Header("Deprecation", "true")
+		ctx.Header("Link", "<"+location+`>; rel="successor-version"`)
+		ctx.Redirect(http.StatusPermanentRedirect, location)
+	}
+
+	for _, route := range routes {
+		for _, prefix := range prefixes {
+			if route.Path == prefix || strings.HasPrefix(route.Path, prefix+"/") {
+				engine.Handle(route.Method, route.Path, redirect)
+				break
+			}
+		}
+	}
+}
+
+// Check сверяет маршруты, реально зарегистрированные в gin под basePath, с таблицей маршрутов.
+// Маршрут без описания или описание без маршрута считаются ошибкой.
+func Check(engine *gin.Engine, basePath string, routes []Route, ignore ...string) error {
+	documented := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		documented[route.Method+" "+basePath+route.Path] = true
+
+		if len(route.Responses) == 0 {
+			return fmt.Errorf("%s %s: no responses documented", route.Method, route.Path)
+		}
+		if route.Body != nil && route.Method == http.MethodGet {
+			return fmt.Errorf("%s %s: GET route must not have a body", route.Method, route.Path)
+		}
+	}
+
+	skip := make(map[string]bool, len(ignore))
+	for _, path := range ignore {
+		skip[basePath+path] = true
+	}
+
+	var problems []string
+	registered := make(map[string]bool)
+	for _, info := range engine.Routes() {
+		if !strings.HasPrefix(info.Path, basePath+"/") || skip[info.Path] {
+			continue
+		}
+
+		key := info.Method + " " + info.Path
+		registered[key] = true
+		if !documented[key] {
+			problems = append(problems, "undocumented route "+key)
+		}
+	}
+
+	for key := range documented {
+		if !registered[key] {
+			problems = append(problems, "documented route is not registered "+key)
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("openapi spec does not match routes:\n  %s", strings.Join(problems, "\n  "))
+	}
+
+	return nil
+}
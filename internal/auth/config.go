@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ChixXx1/expense-tracker/internal/models"
+)
+
+// minSecretLen - короче 32 байт HMAC-SHA256 заметно слабее
+const minSecretLen = 32
+
+type Config struct {
+	Secret     []byte
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+	// AdminEmails - адреса, закрепленные за администраторами: через API их не зарегистрировать,
+	// такие учетные записи заводит cmd/admin create-admin
+	AdminEmails map[string]bool
+}
+
+// ConfigFromEnv читает JWT_SECRET, JWT_ACCESS_TTL, JWT_REFRESH_TTL и ADMIN_EMAILS (через запятую).
+// Без JWT_SECRET ключ генерируется при старте, и после перезапуска всем придется войти заново.
+// Права администратора дает только cmd/admin; ADMIN_EMAILS лишь запрещает занять эти адреса регистрацией.
+func ConfigFromEnv() (Config, error) {
+	config := Config{
+		AccessTTL:   15 * time.Minute,
+		RefreshTTL:  30 * 24 * time.Hour,
+		AdminEmails: make(map[string]bool),
+	}
+
+	for _, email := range strings.Split(os.Getenv("ADMIN_EMAILS"), ",") {
+		if email = models.NormalizeEmail(email); email != "" {
+			config.AdminEmails[email] = true
+		}
+	}
+
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		if len(secret) < minSecretLen {
+			return config, fmt.Errorf("JWT_SECRET must be at least %d bytes", minSecretLen)
+		}
+		config.Secret = []byte(secret)
+	} else {
+		log.Print("JWT_SECRET is not set, using a random key: sessions will not survive a restart")
+		config.Secret = []byte(randomToken(minSecretLen))
+	}
+
+	for name, ttl := range map[string]*time.Duration{
+		"JWT_ACCESS_TTL":  &config.AccessTTL,
+		"JWT_REFRESH_TTL": &config.RefreshTTL,
+	} {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			return config, fmt.Errorf("%s must be a positive duration like 15m, got '%s'", name, value)
+		}
+		*ttl = parsed
+	}
+
+	return config, nil
+}
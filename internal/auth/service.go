@@ -0,0 +1,270 @@
+// Package auth - учетные записи и сессии: пароли в bcrypt, короткоживущие access-токены (JWT)
+// и долгоживущие refresh-токены, которые меняются при каждом обновлении.
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ChixXx1/expense-tracker/internal/database"
+	"github.com/ChixXx1/expense-tracker/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrInvalidCredentials - неверная почта или пароль; что именно, не уточняем
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	// ErrInvalidToken - токен поддельный, просрочен или отозван
+	ErrInvalidToken = errors.New("invalid or expired token")
+)
+
+const refreshTokenSize = 32
+
+// dummyHash сравнивается с паролем, когда пользователя нет, чтобы время ответа не выдавало существующие адреса
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+type Tokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	// ExpiresIn - время жизни access-токена в секундах
+	ExpiresIn int `json:"expires_in"`
+}
+
+type Service struct {
+	storage database.Storage
+	config  Config
+
+	// revoked - jti access-токенов, отозванных при выходе, до истечения их срока.
+	// Access-токены живут недолго, поэтому список держим в памяти.
+	mu      sync.Mutex
+	revoked map[string]time.Time
+}
+
+func NewService(storage database.Storage, config Config) *Service {
+	return &Service{
+		storage: storage,
+		config:  config,
+		revoked: make(map[string]time.Time),
+	}
+}
+
+// Register заводит обычную учетную запись. Адреса из ADMIN_EMAILS заняты за администраторами:
+// иначе их мог бы зарегистрировать кто угодно до того, как администратора заведут через cmd/admin.
+func (s *Service) Register(ctx context.Context, email, password string) (*models.User, error) {
+	email = models.NormalizeEmail(email)
+	if s.config.AdminEmails[email] {
+		return nil, &database.ValidationError{Field: "email", Message: "this email cannot be used for registration"}
+	}
+	return s.register(ctx, email, password, false)
+}
+
+// RegisterAdmin заводит учетную запись администратора; вызывается только из cmd/admin
+func (s *Service) RegisterAdmin(ctx context.Context, email, password string) (*models.User, error) {
+	return s.register(ctx, models.NormalizeEmail(email), password, true)
+}
+
+func (s *Service) register(ctx context.Context, email, password string, admin bool) (*models.User, error) {
+	if err := models.ValidateEmail(email); err != nil {
+		return nil, err
+	}
+	if err := models.ValidatePassword(password); err != nil {
+		return nil, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{Email: email, PasswordHash: string(hash), IsAdmin: admin}
+	if err := s.storage.CreateUser(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *Service) Login(ctx context.Context, email, password string) (*models.User, *Tokens, error) {
+	user, err := s.storage.GetUserByEmail(ctx, email)
+	if errors.Is(err, database.ErrNotFound) {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return nil, nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return nil, nil, ErrInvalidCredentials
+	}
+
+	tokens, err := s.issue(ctx, s.storage, user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return user, tokens, nil
+}
+
+// Refresh меняет refresh-токен на новую пару. Старый токен отзывается; если кто-то предъявит
+// уже отозванный токен, значит он утек - отзываем все сессии пользователя.
+func (s *Service) Refresh(ctx context.Context, refreshToken string) (*Tokens, error) {
+	var tokens *Tokens
+	var reusedBy int
+
+	err := s.storage.WithTx(ctx, func(tx database.Storage) error {
+		stored, err := tx.GetRefreshToken(ctx, hashToken(refreshToken))
+		if errors.Is(err, database.ErrNotFound) {
+			return ErrInvalidToken
+		}
+		if err != nil {
+			return err
+		}
+
+		if stored.RevokedAt != nil {
+			reusedBy = stored.UserID
+			return ErrInvalidToken
+		}
+		if !time.Now().Before(stored.ExpiresAt) {
+			return ErrInvalidToken
+		}
+
+		if err := tx.RevokeRefreshToken(ctx, stored.TokenHash); err != nil {
+			return err
+		}
+
+		tokens, err = s.issue(ctx, tx, stored.UserID)
+		return err
+	})
+
+	if reusedBy != 0 {
+		if err := s.storage.RevokeUserRefreshTokens(ctx, reusedBy); err != nil {
+			return nil, err
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// Logout отзывает refresh-токен и текущий access-токен
+func (s *Service) Logout(ctx context.Context, claims *Claims, refreshToken string) error {
+	if refreshToken != "" {
+		stored, err := s.storage.GetRefreshToken(ctx, hashToken(refreshToken))
+		if errors.Is(err, database.ErrNotFound) {
+			return ErrInvalidToken
+		}
+		if err != nil {
+			return err
+		}
+		// чужой refresh-токен отозвать нельзя
+		if stored.UserID != claims.Subject {
+			return ErrInvalidToken
+		}
+		if err := s.storage.RevokeRefreshToken(ctx, stored.TokenHash); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, expires := range s.revoked {
+		if !now.Before(expires) {
+			delete(s.revoked, id)
+		}
+	}
+	s.revoked[claims.ID] = claims.Expires()
+
+	return nil
+}
+
+// LogoutAll отзывает все refresh-токены пользователя; выданные access-токены доживают свой короткий срок
+func (s *Service) LogoutAll(ctx context.Context, claims *Claims) error {
+	if err := s.storage.RevokeUserRefreshTokens(ctx, claims.Subject); err != nil {
+		return err
+	}
+	return s.Logout(ctx, claims, "")
+}
+
+func (s *Service) User(ctx context.Context, id int) (*models.User, error) {
+	return s.storage.GetUserByID(ctx, id)
+}
+
+// IsAdmin сообщает, выданы ли пользователю права администратора
+func (s *Service) IsAdmin(ctx context.Context, userID int) (bool, error) {
+	user, err := s.storage.GetUserByID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return user.IsAdmin, nil
+}
+
+// Authenticate проверяет access-токен из заголовка Authorization. Токен, выданный до того,
+// как восстановление из копии заменило учетные записи, недействителен.
+func (s *Service) Authenticate(ctx context.Context, token string) (*Claims, error) {
+	claims, err := parseToken(token, s.config.Secret, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	epoch, err := s.storage.SessionEpoch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Epoch != epoch {
+		return nil, ErrInvalidToken
+	}
+
+	s.mu.Lock()
+	_, revoked := s.revoked[claims.ID]
+	s.mu.Unlock()
+	if revoked {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+func (s *Service) issue(ctx context.Context, storage database.Storage, userID int) (*Tokens, error) {
+	now := time.Now()
+
+	epoch, err := storage.SessionEpoch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	access, err := signToken(Claims{
+		Subject:   userID,
+		ID:        randomToken(16),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(s.config.AccessTTL).Unix(),
+		Epoch:     epoch,
+	}, s.config.Secret)
+	if err != nil {
+		return nil, err
+	}
+
+	refresh := randomToken(refreshTokenSize)
+	if err := storage.SaveRefreshToken(ctx, &models.RefreshToken{
+		TokenHash: hashToken(refresh),
+		UserID:    userID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.config.RefreshTTL),
+	}); err != nil {
+		return nil, err
+	}
+
+	return &Tokens{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.config.AccessTTL.Seconds()),
+	}, nil
+}
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ChixXx1/expense-tracker/internal/database"
+)
+
+func testConfig() Config {
+	return Config{
+		Secret:     []byte(strings.Repeat("s", 32)),
+		AccessTTL:  time.Hour,
+		RefreshTTL: time.Hour,
+	}
+}
+
+// После перезапуска пользователь должен войти с тем же паролем: хеш хранится в файле данных
+func TestLoginAfterReload(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "data.json")
+
+	registered, err := NewService(database.NewJSONStorage(path), testConfig()).Register(ctx, "User@Example.com", "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service := NewService(database.NewJSONStorage(path), testConfig())
+
+	user, tokens, err := service.Login(ctx, "user@example.com", "password123")
+	if err != nil {
+		t.Fatalf("login after reload: %v", err)
+	}
+	if user.ID != registered.ID || tokens.AccessToken == "" {
+		t.Fatalf("login after reload: user %d, want %d", user.ID, registered.ID)
+	}
+
+	if _, _, err := service.Login(ctx, "user@example.com", "wrong password"); err != ErrInvalidCredentials {
+		t.Fatalf("login with wrong password: %v, want %v", err, ErrInvalidCredentials)
+	}
+}
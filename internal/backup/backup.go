@@ -0,0 +1,329 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ChixXx1/expense-tracker/internal/database"
+	"github.com/ChixXx1/expense-tracker/internal/models"
+)
+
+// Create снимает данные через интерфейс Storage, поэтому работает с любым хранилищем
+func Create(ctx context.Context, storage database.Storage) (*models.Backup, error) {
+	snapshot, err := storage.Snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot.SchemaVersion = models.BackupSchemaVersion
+	snapshot.CreatedAt = time.Now().UTC()
+
+	checksum, err := Checksum(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	snapshot.Checksum = checksum
+
+	return snapshot, nil
+}
+
+// Checksum - SHA-256 от JSON копии без поля checksum
+func Checksum(backup *models.Backup) (string, error) {
+	copyWithoutChecksum := *backup
+	copyWithoutChecksum.Checksum = ""
+
+	data, err := json.Marshal(&copyWithoutChecksum)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// sourceChecksum считает контрольную сумму по JSON, как он был прочитан: поля верхнего уровня
+// идут в исходном порядке, значения - как есть, только без отступов, а checksum пустой. Так
+// совпадает с тем, что посчитал Checksum при создании копии, даже если с тех пор у моделей
+// появились новые поля - например, у копий первой версии нет ledger_id и учетных записей.
+func sourceChecksum(source []byte) (string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(source))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return "", errors.New("invalid backup archive: not a JSON object")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", fmt.Errorf("invalid backup archive: %w", err)
+		}
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return "", fmt.Errorf("invalid backup archive: %w", err)
+		}
+
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(token)
+		buf.Write(key)
+		buf.WriteByte(':')
+		if token == "checksum" {
+			buf.WriteString(`""`)
+		} else if err := json.Compact(&buf, value); err != nil {
+			return "", fmt.Errorf("invalid backup archive: %w", err)
+		}
+	}
+	buf.WriteByte('}')
+
+	sum := sha256.Sum256(buf.Bytes())
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Verify проверяет версию схемы, контрольную сумму и целостность данных.
+// Копии первой версии принимаются: перед восстановлением их обновляет Migrate.
+func Verify(backup *models.Backup) error {
+	if backup.SchemaVersion < 1 || backup.SchemaVersion > models.BackupSchemaVersion {
+		return fmt.Errorf("unsupported schema version %d, expected 1 to %d", backup.SchemaVersion, models.BackupSchemaVersion)
+	}
+
+	checksum, err := Checksum(backup)
+	if backup.Source != nil {
+		checksum, err = sourceChecksum(backup.Source)
+	}
+	if err != nil {
+		return err
+	}
+	if checksum != backup.Checksum {
+		return errors.New("checksum mismatch, backup is corrupted")
+	}
+
+	ledgerIDs, err := verifyAccounts(backup)
+	if err != nil {
+		return err
+	}
+	// в копии первой версии книг нет, там ссылки на книги не проверить
+	checkLedger := func(ledgerID int) error {
+		if backup.SchemaVersion > 1 && ledgerID != 0 && !ledgerIDs[ledgerID] {
+			return fmt.Errorf("ledger %d does not exist", ledgerID)
+		}
+		return nil
+	}
+
+	categoryIDs := make(map[int]bool, len(backup.Categories))
+	for _, cat := range backup.Categories {
+		if err := cat.Validate(); err != nil {
+			return fmt.Errorf("category %d: %w", cat.ID, err)
+		}
+		if err := checkLedger(cat.LedgerID); err != nil {
+			return fmt.Errorf("category %d: %w", cat.ID, err)
+		}
+		if categoryIDs[cat.ID] {
+			return fmt.Errorf("category %d: duplicate id", cat.ID)
+		}
+		categoryIDs[cat.ID] = true
+	}
+
+	transactionIDs := make(map[int]bool, len(backup.Transactions))
+	for _, tx := range backup.Transactions {
+		if err := tx.Validate(); err != nil {
+			return fmt.Errorf("transaction %d: %w", tx.ID, err)
+		}
+		if err := checkLedger(tx.LedgerID); err != nil {
+			return fmt.Errorf("transaction %d: %w", tx.ID, err)
+		}
+		if transactionIDs[tx.ID] {
+			return fmt.Errorf("transaction %d: duplicate id", tx.ID)
+		}
+		transactionIDs[tx.ID] = true
+	}
+
+	budgetIDs := make(map[int]bool, len(backup.Budgets))
+	for _, budget := range backup.Budgets {
+		if err := budget.Validate(); err != nil {
+			return fmt.Errorf("budget %d: %w", budget.ID, err)
+		}
+		if err := checkLedger(budget.LedgerID); err != nil {
+			return fmt.Errorf("budget %d: %w", budget.ID, err)
+		}
+		if !categoryIDs[budget.CategoryID] {
+			return fmt.Errorf("budget %d: category %d does not exist", budget.ID, budget.CategoryID)
+		}
+		if budgetIDs[budget.ID] {
+			return fmt.Errorf("budget %d: duplicate id", budget.ID)
+		}
+		budgetIDs[budget.ID] = true
+	}
+
+	for _, profile := range backup.Settings.ImportProfiles {
+		if err := profile.Validate(); err != nil {
+			return fmt.Errorf("import profile %d: %w", profile.ID, err)
+		}
+		if err := checkLedger(profile.LedgerID); err != nil {
+			return fmt.Errorf("import profile %d: %w", profile.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// verifyAccounts проверяет пользователей, книги, приглашения и сессии; возвращает ID книг
+func verifyAccounts(backup *models.Backup) (map[int]bool, error) {
+	userIDs := make(map[int]bool, len(backup.Users))
+	emails := make(map[string]bool, len(backup.Users))
+	for _, user := range backup.Users {
+		if err := models.ValidateEmail(user.Email); err != nil {
+			return nil, fmt.Errorf("user %d: %w", user.ID, err)
+		}
+		if user.PasswordHash == "" {
+			return nil, fmt.Errorf("user %d: password hash is missing", user.ID)
+		}
+		if userIDs[user.ID] {
+			return nil, fmt.Errorf("user %d: duplicate id", user.ID)
+		}
+		if emails[user.Email] {
+			return nil, fmt.Errorf("user %d: duplicate email %s", user.ID, user.Email)
+		}
+		userIDs[user.ID] = true
+		emails[user.Email] = true
+	}
+
+	ledgerIDs := make(map[int]bool, len(backup.Ledgers))
+	for _, ledger := range backup.Ledgers {
+		if ledgerIDs[ledger.ID] {
+			return nil, fmt.Errorf("ledger %d: duplicate id", ledger.ID)
+		}
+		ledgerIDs[ledger.ID] = true
+
+		owners := 0
+		for _, member := range ledger.Members {
+			if !userIDs[member.UserID] {
+				return nil, fmt.Errorf("ledger %d: member %d does not exist", ledger.ID, member.UserID)
+			}
+			if !models.ValidLedgerRole(member.Role) {
+				return nil, fmt.Errorf("ledger %d: member %d has unknown role '%s'", ledger.ID, member.UserID, member.Role)
+			}
+			if member.Role == models.LedgerRoleOwner {
+				owners++
+			}
+		}
+		if owners == 0 {
+			return nil, fmt.Errorf("ledger %d: no owner", ledger.ID)
+		}
+	}
+
+	for _, invite := range backup.LedgerInvites {
+		if !ledgerIDs[invite.LedgerID] {
+			return nil, fmt.Errorf("invite for ledger %d: ledger does not exist", invite.LedgerID)
+		}
+	}
+	for _, token := range backup.RefreshTokens {
+		if !userIDs[token.UserID] {
+			return nil, fmt.Errorf("refresh token of user %d: user does not exist", token.UserID)
+		}
+	}
+
+	return ledgerIDs, nil
+}
+
+// Migrate обновляет проверенную копию старой версии схемы до текущей. В копии первой версии
+// нет учетных записей и книг, поэтому восстановить ее можно только в книгу (см. IntoLedger).
+func Migrate(backup *models.Backup) error {
+	if backup.SchemaVersion == models.BackupSchemaVersion {
+		return nil
+	}
+
+	if backup.SchemaVersion == 1 {
+		backup.Users = []models.UserRecord{}
+		backup.Ledgers = []models.Ledger{}
+		backup.LedgerInvites = []models.LedgerInvite{}
+		backup.RefreshTokens = []models.RefreshToken{}
+		backup.SchemaVersion = 2
+	}
+
+	// после обновления копия уже не совпадает с прочитанным JSON
+	backup.Source = nil
+	checksum, err := Checksum(backup)
+	if err != nil {
+		return err
+	}
+	backup.Checksum = checksum
+
+	return nil
+}
+
+// IntoLedger ограничивает восстановление одной книгой: в нее попадают записи этой книги и ничьи,
+// автором ничьих становится владелец книги. Копию первой версии можно восстановить только так.
+func IntoLedger(ctx context.Context, storage database.Storage, ledgerID int) (context.Context, error) {
+	ledger, err := storage.GetLedgerByID(ctx, ledgerID)
+	if err != nil {
+		return nil, err
+	}
+	return database.WithLedger(ctx, ledger.ID, ledger.Owner()), nil
+}
+
+func Restore(ctx context.Context, storage database.Storage, backup *models.Backup, mode string) (*models.RestoreResult, error) {
+	if mode != models.RestoreModeReplace && mode != models.RestoreModeMerge {
+		return nil, fmt.Errorf("mode must be '%s' or '%s'", models.RestoreModeReplace, models.RestoreModeMerge)
+	}
+
+	if err := Verify(backup); err != nil {
+		return nil, err
+	}
+	if err := Migrate(backup); err != nil {
+		return nil, err
+	}
+
+	return storage.Restore(ctx, backup, mode)
+}
+
+// Write сохраняет резервную копию как JSON, сжатый gzip
+func Write(w io.Writer, backup *models.Backup) error {
+	gz := gzip.NewWriter(w)
+
+	encoder := json.NewEncoder(gz)
+	encoder.SetIndent("", "\t")
+	if err := encoder.Encode(backup); err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
+
+// Read принимает как сжатый, так и обычный JSON
+func Read(r io.Reader) (*models.Backup, error) {
+	reader := bufio.NewReader(r)
+
+	var source io.Reader = reader
+	if magic, err := reader.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, fmt.Errorf("invalid backup archive: %w", err)
+		}
+		defer gz.Close()
+		source = gz
+	}
+
+	data, err := io.ReadAll(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid backup archive: %w", err)
+	}
+
+	var backup models.Backup
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return nil, fmt.Errorf("invalid backup archive: %w", err)
+	}
+	backup.Source = data
+
+	return &backup, nil
+}
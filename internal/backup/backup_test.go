@@ -0,0 +1,317 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ChixXx1/expense-tracker/internal/database"
+	"github.com/ChixXx1/expense-tracker/internal/models"
+)
+
+var testDate = time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+
+func newStorage(t *testing.T) (*database.JSONStorage, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "data.json")
+	return database.NewJSONStorage(path), path
+}
+
+func createUser(t *testing.T, storage database.Storage, email string) (*models.User, models.Ledger) {
+	t.Helper()
+	ctx := context.Background()
+
+	user := &models.User{Email: email, PasswordHash: "hash of " + email}
+	if err := storage.CreateUser(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	ledgers, err := storage.GetLedgers(ctx, user.ID)
+	if err != nil || len(ledgers) != 1 {
+		t.Fatalf("personal ledger of %s: %v, %v", email, ledgers, err)
+	}
+	return user, ledgers[0]
+}
+
+func createTransaction(t *testing.T, storage database.Storage, ctx context.Context, description string) models.Transaction {
+	t.Helper()
+
+	categories, err := storage.GetCategories(ctx)
+	if err != nil || len(categories) == 0 {
+		t.Fatalf("categories: %v, %v", categories, err)
+	}
+	tx := models.Transaction{Amount: 100, Type: categories[0].Type, CategoryID: categories[0].ID, Date: testDate,
+		Description: description, PaymentMethod: models.PaymentMethodCard}
+	if err := storage.CreateTransaction(ctx, &tx); err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+func transactions(t *testing.T, storage database.Storage, ctx context.Context) []models.Transaction {
+	t.Helper()
+
+	page, err := storage.GetTransactions(ctx, database.TransactionFilters{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return page.Transactions
+}
+
+// roundTrip пишет копию в архив и читает обратно, как при скачивании и загрузке через API
+func roundTrip(t *testing.T, archive *models.Backup) *models.Backup {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := Write(&buf, archive); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return restored
+}
+
+// Полная копия, восстановленная в пустой файл данных, дает рабочий экземпляр:
+// пользователи входят со старыми паролями и видят свои книги и записи
+func TestRestoreIntoEmptyStorage(t *testing.T) {
+	ctx := context.Background()
+	source, _ := newStorage(t)
+
+	alice, personal := createUser(t, source, "alice@example.com")
+	bob, _ := createUser(t, source, "bob@example.com")
+	carol, _ := createUser(t, source, "carol@example.com")
+
+	shared := models.Ledger{Name: "Семья", Members: []models.LedgerMember{
+		{UserID: alice.ID, Role: models.LedgerRoleOwner, JoinedAt: testDate},
+		{UserID: bob.ID, Role: models.LedgerRoleViewer, JoinedAt: testDate},
+	}}
+	if err := source.CreateLedger(ctx, &shared); err != nil {
+		t.Fatal(err)
+	}
+	invite := models.LedgerInvite{Code: "code", LedgerID: shared.ID, Role: models.LedgerRoleEditor, CreatedBy: alice.ID,
+		CreatedAt: testDate, ExpiresAt: time.Now().Add(time.Hour)}
+	if err := source.CreateLedgerInvite(ctx, &invite); err != nil {
+		t.Fatal(err)
+	}
+	token := models.RefreshToken{TokenHash: "token hash", UserID: alice.ID, CreatedAt: testDate, ExpiresAt: time.Now().Add(time.Hour)}
+	if err := source.SaveRefreshToken(ctx, &token); err != nil {
+		t.Fatal(err)
+	}
+	inPersonal := database.WithLedger(ctx, personal.ID, alice.ID)
+	inShared := database.WithLedger(ctx, shared.ID, alice.ID)
+	createTransaction(t, source, inPersonal, "Кофе")
+	createTransaction(t, source, inShared, "Продукты")
+
+	archive, err := Create(database.AllLedgers(ctx), source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive = roundTrip(t, archive)
+	if archive.SchemaVersion != models.BackupSchemaVersion {
+		t.Fatalf("schema version %d, want %d", archive.SchemaVersion, models.BackupSchemaVersion)
+	}
+
+	target, path := newStorage(t)
+	result, err := Restore(database.AllLedgers(ctx), target, archive, models.RestoreModeReplace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Users != 3 || result.Ledgers != 4 {
+		t.Errorf("restored %d users, %d ledgers; want 3 and 4", result.Users, result.Ledgers)
+	}
+
+	// данные переживают и перезапуск после восстановления
+	target = database.NewJSONStorage(path)
+
+	user, err := target.GetUserByEmail(ctx, alice.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != alice.ID || user.PasswordHash != alice.PasswordHash {
+		t.Errorf("restored user %+v, want %+v", user, alice)
+	}
+	if _, err := target.GetRefreshToken(ctx, token.TokenHash); err != nil {
+		t.Errorf("refresh token: %v", err)
+	}
+
+	ledgers, err := target.GetLedgers(ctx, bob.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ledgers) != 2 {
+		t.Errorf("bob's ledgers after restore: %+v, want personal and shared", ledgers)
+	}
+	if _, err := target.AcceptLedgerInvite(ctx, invite.Code, carol.ID); err != nil {
+		t.Errorf("invite after restore: %v", err)
+	}
+
+	if got := transactions(t, target, inPersonal); len(got) != 1 || got[0].Description != "Кофе" {
+		t.Errorf("personal ledger after restore: %+v", got)
+	}
+	if got := transactions(t, target, database.WithLedger(ctx, shared.ID, bob.ID)); len(got) != 1 || got[0].Description != "Продукты" {
+		t.Errorf("shared ledger after restore: %+v", got)
+	}
+}
+
+// Слияние сопоставляет пользователей по почте и переносит записи в их книги в этом хранилище
+func TestMergeMapsAccounts(t *testing.T) {
+	ctx := context.Background()
+	source, _ := newStorage(t)
+
+	createUser(t, source, "carol@example.com")
+	alice, personal := createUser(t, source, "alice@example.com")
+	createTransaction(t, source, database.WithLedger(ctx, personal.ID, alice.ID), "Кофе")
+
+	archive, err := Create(database.AllLedgers(ctx), source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// в целевом хранилище Алиса зарегистрирована первой и с другими ID
+	target, _ := newStorage(t)
+	targetAlice, targetPersonal := createUser(t, target, "alice@example.com")
+
+	result, err := Restore(database.AllLedgers(ctx), target, roundTrip(t, archive), models.RestoreModeMerge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Users != 1 || result.Ledgers != 1 {
+		t.Errorf("merged %d users, %d ledgers; want only carol and her ledger", result.Users, result.Ledgers)
+	}
+
+	got := transactions(t, target, database.WithLedger(ctx, targetPersonal.ID, targetAlice.ID))
+	if len(got) != 1 || got[0].Description != "Кофе" || got[0].OwnerID != targetAlice.ID {
+		t.Errorf("alice's ledger after merge: %+v", got)
+	}
+
+	carol, err := target.GetUserByEmail(ctx, "carol@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ledgers, err := target.GetLedgers(ctx, carol.ID); err != nil || len(ledgers) != 1 || !ledgers[0].Personal {
+		t.Errorf("carol's ledgers after merge: %+v, %v", ledgers, err)
+	}
+}
+
+// readFixture читает копию из testdata
+func readFixture(t *testing.T, name string) *models.Backup {
+	t.Helper()
+
+	file, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	archive, err := Read(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return archive
+}
+
+// backup-v1.json.gz записан командой admin backup до появления книг (схема 1, записи с owner_id).
+// Контрольная сумма такой копии сходится, хотя у моделей с тех пор появились новые поля,
+// а любое изменение содержимого ее ломает.
+func TestVerifySchemaV1Archive(t *testing.T) {
+	archive := readFixture(t, "backup-v1.json.gz")
+	if archive.SchemaVersion != 1 {
+		t.Fatalf("fixture schema version %d, want 1", archive.SchemaVersion)
+	}
+	if err := Verify(archive); err != nil {
+		t.Fatalf("v1 archive rejected: %v", err)
+	}
+
+	tampered := bytes.Replace(archive.Source, []byte(`"amount": 1200`), []byte(`"amount": 1300`), 1)
+	if bytes.Equal(tampered, archive.Source) {
+		t.Fatal("fixture has no transaction to tamper with")
+	}
+	changed, err := Read(bytes.NewReader(tampered))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(changed); err == nil {
+		t.Error("tampered v1 archive passed verification")
+	}
+
+	if err := Migrate(archive); err != nil {
+		t.Fatal(err)
+	}
+	if archive.SchemaVersion != models.BackupSchemaVersion {
+		t.Errorf("archive not migrated: schema version %d", archive.SchemaVersion)
+	}
+	if err := Verify(archive); err != nil {
+		t.Errorf("migrated archive rejected: %v", err)
+	}
+}
+
+// Копию первой версии нельзя восстановить во все книги сразу: ее записи без книги никому не видны.
+// В выбранную книгу они попадают целиком, а автором становится владелец книги.
+func TestRestoreSchemaV1IntoLedger(t *testing.T) {
+	ctx := context.Background()
+
+	target, _ := newStorage(t)
+	alice, personal := createUser(t, target, "alice@example.com")
+	bob, bobPersonal := createUser(t, target, "bob@example.com")
+	inPersonal := database.WithLedger(ctx, personal.ID, alice.ID)
+
+	_, err := Restore(database.AllLedgers(ctx), target, readFixture(t, "backup-v1.json.gz"), models.RestoreModeReplace)
+	if !errors.Is(err, database.ErrValidation) {
+		t.Fatalf("v1 restore into all ledgers: %v, want %v", err, database.ErrValidation)
+	}
+
+	restoreCtx, err := IntoLedger(database.AllLedgers(ctx), target, personal.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := readFixture(t, "backup-v1.json.gz")
+	result, err := Restore(restoreCtx, target, archive, models.RestoreModeReplace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// записи двух авторов из копии совпадают, в одной книге повторы пропускаются
+	if result.Transactions == 0 || result.Users != 0 {
+		t.Errorf("restored %d transactions, %d users; want some transactions and no users", result.Transactions, result.Users)
+	}
+
+	got := transactions(t, target, inPersonal)
+	if len(got) != result.Transactions {
+		t.Fatalf("alice's ledger after v1 restore: %d transactions, want %d", len(got), result.Transactions)
+	}
+	for _, tx := range got {
+		if tx.OwnerID != alice.ID {
+			t.Errorf("transaction %d owner %d, want %d", tx.ID, tx.OwnerID, alice.ID)
+		}
+	}
+	if got := transactions(t, target, database.WithLedger(ctx, bobPersonal.ID, bob.ID)); len(got) != 0 {
+		t.Errorf("bob's ledger got v1 records: %+v", got)
+	}
+	if _, err := target.GetUserByEmail(ctx, bob.Email); err != nil {
+		t.Errorf("existing users must survive a v1 restore: %v", err)
+	}
+}
+
+func TestVerifyRejectsBrokenAccounts(t *testing.T) {
+	ctx := context.Background()
+	source, _ := newStorage(t)
+	createUser(t, source, "alice@example.com")
+
+	archive, err := Create(database.AllLedgers(ctx), source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// книга ссылается на пользователя, которого нет в копии
+	archive.Users = archive.Users[:0]
+	if archive.Checksum, err = Checksum(archive); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(archive); err == nil {
+		t.Error("archive with a ledger member missing from users passed verification")
+	}
+}
@@ -0,0 +1,383 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ChixXx1/expense-tracker/internal/database"
+	"github.com/ChixXx1/expense-tracker/internal/models"
+)
+
+const snapshotTimeLayout = "20060102-150405"
+
+var snapshotNamePattern = regexp.MustCompile(`^snapshot-(\d{8}-\d{6})\.json\.gz$`)
+
+var ErrSnapshotNotFound = fmt.Errorf("snapshot %w", database.ErrNotFound)
+
+// SnapshotConfig - настройки автоматических снимков.
+// Interval = 0 отключает расписание, снимки можно делать вручную.
+type SnapshotConfig struct {
+	Dir         string
+	Interval    time.Duration
+	KeepDaily   int
+	KeepWeekly  int
+	KeepMonthly int
+}
+
+// SnapshotConfigFromEnv читает SNAPSHOT_DIR, SNAPSHOT_INTERVAL и SNAPSHOT_KEEP_DAILY/WEEKLY/MONTHLY
+func SnapshotConfigFromEnv() (SnapshotConfig, error) {
+	config := SnapshotConfig{
+		Dir:         "./backups",
+		Interval:    24 * time.Hour,
+		KeepDaily:   7,
+		KeepWeekly:  4,
+		KeepMonthly: 12,
+	}
+
+	if dir := os.Getenv("SNAPSHOT_DIR"); dir != "" {
+		config.Dir = dir
+	}
+
+	if value := os.Getenv("SNAPSHOT_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil || interval < 0 {
+			return config, fmt.Errorf("SNAPSHOT_INTERVAL must be a duration like 6h, got '%s'", value)
+		}
+		config.Interval = interval
+	}
+
+	keeps := map[string]*int{
+		"SNAPSHOT_KEEP_DAILY":   &config.KeepDaily,
+		"SNAPSHOT_KEEP_WEEKLY":  &config.KeepWeekly,
+		"SNAPSHOT_KEEP_MONTHLY": &config.KeepMonthly,
+	}
+	for name, target := range keeps {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		keep, err := strconv.Atoi(value)
+		if err != nil || keep < 0 {
+			return config, fmt.Errorf("%s must be a non-negative integer, got '%s'", name, value)
+		}
+		*target = keep
+	}
+
+	return config, nil
+}
+
+type SnapshotInfo struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	Size      int64     `json:"size"`
+	Valid     bool      `json:"valid"`
+	Error     string    `json:"error,omitempty"`
+}
+
+type Scheduler struct {
+	storage database.Storage
+	config  SnapshotConfig
+	mu      sync.Mutex
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func NewScheduler(storage database.Storage, config SnapshotConfig) *Scheduler {
+	return &Scheduler{
+		storage: storage,
+		config:  config,
+	}
+}
+
+// Start запускает фоновые снимки. Если последний снимок старше интервала, первый делается сразу.
+func (s *Scheduler) Start() {
+	if s.config.Interval <= 0 || s.stop != nil {
+		return
+	}
+
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+
+		wait := s.config.Interval
+		if latest, ok := s.latest(); !ok {
+			wait = 0
+		} else if age := time.Since(latest); age < s.config.Interval {
+			wait = s.config.Interval - age
+		} else {
+			wait = 0
+		}
+
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-timer.C:
+				if info, err := s.TakeSnapshot(context.Background()); err != nil {
+					log.Printf("snapshot: %v", err)
+				} else {
+					log.Printf("snapshot: %s written", info.Name)
+				}
+				timer.Reset(s.config.Interval)
+			}
+		}
+	}()
+}
+
+func (s *Scheduler) Stop() {
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	<-s.done
+	s.stop = nil
+}
+
+// TakeSnapshot пишет снимок во временный файл, проверяет его и только потом переименовывает
+func (s *Scheduler) TakeSnapshot(ctx context.Context) (*SnapshotInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.config.Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	// снимок всегда полный, даже если его запросил пользователь через API
+	archive, err := Create(database.AllLedgers(ctx), s.storage)
+	if err != nil {
+		return nil, err
+	}
+
+	name := "snapshot-" + archive.CreatedAt.Format(snapshotTimeLayout) + ".json.gz"
+	path := filepath.Join(s.config.Dir, name)
+	tmpPath := path + ".tmp"
+
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := Write(file, archive); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+
+	if _, err := readSnapshot(tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return nil, fmt.Errorf("snapshot failed verification: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+
+	if _, err := s.prune(); err != nil {
+		log.Printf("snapshot: retention failed: %v", err)
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SnapshotInfo{
+		Name:      name,
+		CreatedAt: archive.CreatedAt,
+		Size:      stat.Size(),
+		Valid:     true,
+	}, nil
+}
+
+// List возвращает снимки от новых к старым, проверяя целостность каждого
+func (s *Scheduler) List() ([]SnapshotInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshots, err := s.scan()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range snapshots {
+		if _, err := readSnapshot(filepath.Join(s.config.Dir, snapshots[i].Name)); err != nil {
+			snapshots[i].Error = err.Error()
+			continue
+		}
+		snapshots[i].Valid = true
+	}
+
+	return snapshots, nil
+}
+
+// Load читает и проверяет снимок по имени; имена вне шаблона отклоняются
+func (s *Scheduler) Load(name string) (*models.Backup, error) {
+	if !snapshotNamePattern.MatchString(name) {
+		return nil, ErrSnapshotNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := filepath.Join(s.config.Dir, name)
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrSnapshotNotFound
+		}
+		return nil, err
+	}
+
+	return readSnapshot(path)
+}
+
+func (s *Scheduler) latest() (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshots, err := s.scan()
+	if err != nil || len(snapshots) == 0 {
+		return time.Time{}, false
+	}
+	return snapshots[0].CreatedAt, true
+}
+
+// scan читает каталог без проверки содержимого, вызывать под s.mu
+func (s *Scheduler) scan() ([]SnapshotInfo, error) {
+	entries, err := os.ReadDir(s.config.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []SnapshotInfo{}, nil
+		}
+		return nil, err
+	}
+
+	snapshots := []SnapshotInfo{}
+	for _, entry := range entries {
+		match := snapshotNamePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		createdAt, err := time.Parse(snapshotTimeLayout, match[1])
+		if err != nil {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		snapshots = append(snapshots, SnapshotInfo{
+			Name:      entry.Name(),
+			CreatedAt: createdAt,
+			Size:      info.Size(),
+		})
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.After(snapshots[j].CreatedAt)
+	})
+
+	return snapshots, nil
+}
+
+// prune оставляет самый новый снимок за каждый из последних KeepDaily дней,
+// KeepWeekly недель и KeepMonthly месяцев, остальные удаляет. Вызывать под s.mu.
+func (s *Scheduler) prune() ([]string, error) {
+	snapshots, err := s.scan()
+	if err != nil {
+		return nil, err
+	}
+
+	keep := retained(snapshots, s.config)
+
+	removed := []string{}
+	for _, snapshot := range snapshots {
+		if keep[snapshot.Name] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.config.Dir, snapshot.Name)); err != nil {
+			return removed, err
+		}
+		removed = append(removed, snapshot.Name)
+	}
+
+	return removed, nil
+}
+
+// retained ожидает снимки, отсортированные от новых к старым
+func retained(snapshots []SnapshotInfo, config SnapshotConfig) map[string]bool {
+	keep := make(map[string]bool)
+	if len(snapshots) == 0 {
+		return keep
+	}
+
+	// самый свежий снимок не удаляется никогда
+	keep[snapshots[0].Name] = true
+
+	buckets := []struct {
+		limit int
+		key   func(t time.Time) string
+	}{
+		{config.KeepDaily, func(t time.Time) string { return t.Format("2006-01-02") }},
+		{config.KeepWeekly, func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}},
+		{config.KeepMonthly, func(t time.Time) string { return t.Format("2006-01") }},
+	}
+
+	for _, bucket := range buckets {
+		seen := make(map[string]bool)
+		for _, snapshot := range snapshots {
+			if len(seen) >= bucket.limit {
+				break
+			}
+			key := bucket.key(snapshot.CreatedAt)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			keep[snapshot.Name] = true
+		}
+	}
+
+	return keep
+}
+
+func readSnapshot(path string) (*models.Backup, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	archive, err := Read(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := Verify(archive); err != nil {
+		return nil, err
+	}
+
+	return archive, nil
+}
@@ -0,0 +1,198 @@
+package database
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/ChixXx1/expense-tracker/internal/models"
+)
+
+const aggregateDayLayout = "2006-01-02"
+
+// aggregatesVersion меняется вместе со способом раскладки по корзинам: файл с другой версией
+// пересчитывается при загрузке. 2 - дни считаются в UTC.
+const aggregatesVersion = 2
+
+// AggregateBucket хранит сумму и количество транзакций книги за день по категории и типу
+type AggregateBucket struct {
+	LedgerID   int     `json:"ledger_id"`
+	Day        string  `json:"day"`
+	CategoryID int     `json:"category_id"`
+	Type       string  `json:"type"`
+	Amount     float64 `json:"amount"`
+	Count      int     `json:"count"`
+}
+
+type aggregateKey struct {
+	ledgerID   int
+	day        string
+	categoryID int
+	txType     string
+}
+
+type aggregates struct {
+	buckets map[aggregateKey]*AggregateBucket
+}
+
+func newAggregates() *aggregates {
+	return &aggregates{
+		buckets: make(map[aggregateKey]*AggregateBucket),
+	}
+}
+
+func buildAggregates(transactions []models.Transaction) *aggregates {
+	agg := newAggregates()
+	for _, tx := range transactions {
+		agg.add(tx)
+	}
+	return agg
+}
+
+func (a *aggregates) clone() *aggregates {
+	agg := newAggregates()
+	for key, bucket := range a.buckets {
+		copied := *bucket
+		agg.buckets[key] = &copied
+	}
+	return agg
+}
+
+func aggregatesFromBuckets(buckets []AggregateBucket) *aggregates {
+	agg := newAggregates()
+	for i := range buckets {
+		bucket := buckets[i]
+		agg.buckets[bucket.key()] = &bucket
+	}
+	return agg
+}
+
+func (b AggregateBucket) key() aggregateKey {
+	return aggregateKey{b.LedgerID, b.Day, b.CategoryID, b.Type}
+}
+
+// String - корзина в сообщениях о расхождениях: книга/день/категория/тип
+func (k aggregateKey) String() string {
+	return fmt.Sprintf("%d/%s/%d/%s", k.ledgerID, k.day, k.categoryID, k.txType)
+}
+
+// aggregateDay - день корзины. Дата приводится к UTC, иначе транзакция со смещением пояса
+// попала бы не в тот день, что при сравнении моментов времени.
+func aggregateDay(t time.Time) string {
+	return t.UTC().Format(aggregateDayLayout)
+}
+
+func keyFor(tx models.Transaction) aggregateKey {
+	return aggregateKey{
+		ledgerID:   tx.LedgerID,
+		day:        aggregateDay(tx.Date),
+		categoryID: tx.CategoryID,
+		txType:     tx.Type,
+	}
+}
+
+func (a *aggregates) add(tx models.Transaction) {
+	key := keyFor(tx)
+	bucket, exists := a.buckets[key]
+	if !exists {
+		bucket = &AggregateBucket{LedgerID: key.ledgerID, Day: key.day, CategoryID: key.categoryID, Type: key.txType}
+		a.buckets[key] = bucket
+	}
+	bucket.Amount += tx.Amount
+	bucket.Count++
+}
+
+func (a *aggregates) remove(tx models.Transaction) {
+	key := keyFor(tx)
+	bucket, exists := a.buckets[key]
+	if !exists {
+		return
+	}
+	bucket.Amount -= tx.Amount
+	bucket.Count--
+	if bucket.Count <= 0 {
+		delete(a.buckets, key)
+	}
+}
+
+// inRange возвращает корзины книги за дни, целиком попавшие в [startDate, endDate], и граничные дни,
+// покрытые диапазоном лишь частично, если в них есть корзины: такие дни считаются по самим транзакциям
+func (a *aggregates) inRange(sc scope, startDate, endDate time.Time) ([]AggregateBucket, map[string]bool) {
+	startDay := aggregateDay(startDate)
+	endDay := aggregateDay(endDate)
+
+	cut := make(map[string]bool, 2)
+	if !startDate.Equal(dayStart(startDay)) {
+		cut[startDay] = true
+	}
+	if !endDate.Equal(dayStart(endDay).AddDate(0, 0, 1).Add(-time.Nanosecond)) {
+		cut[endDay] = true
+	}
+
+	var result []AggregateBucket
+	partial := make(map[string]bool)
+	for key, bucket := range a.buckets {
+		if !sc.owns(key.ledgerID) || key.day < startDay || key.day > endDay {
+			continue
+		}
+		if cut[key.day] {
+			partial[key.day] = true
+			continue
+		}
+		result = append(result, *bucket)
+	}
+	return result, partial
+}
+
+func dayStart(day string) time.Time {
+	start, _ := time.Parse(aggregateDayLayout, day)
+	return start
+}
+
+func (a *aggregates) list() []AggregateBucket {
+	result := make([]AggregateBucket, 0, len(a.buckets))
+	for _, bucket := range a.buckets {
+		result = append(result, *bucket)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].LedgerID != result[j].LedgerID {
+			return result[i].LedgerID < result[j].LedgerID
+		}
+		if result[i].Day != result[j].Day {
+			return result[i].Day < result[j].Day
+		}
+		if result[i].CategoryID != result[j].CategoryID {
+			return result[i].CategoryID < result[j].CategoryID
+		}
+		return result[i].Type < result[j].Type
+	})
+
+	return result
+}
+
+// diff сравнивает корзины с эталоном и описывает расхождения
+func (a *aggregates) diff(expected *aggregates) []string {
+	var problems []string
+
+	for _, want := range expected.list() {
+		got, exists := a.buckets[want.key()]
+		if !exists {
+			problems = append(problems, fmt.Sprintf("missing bucket %s", want.key()))
+			continue
+		}
+		if got.Count != want.Count || math.Abs(got.Amount-want.Amount) > 1e-6 {
+			problems = append(problems, fmt.Sprintf("bucket %s: got amount %.2f count %d, want amount %.2f count %d",
+				want.key(), got.Amount, got.Count, want.Amount, want.Count))
+		}
+	}
+
+	for _, got := range a.list() {
+		if _, exists := expected.buckets[got.key()]; !exists {
+			problems = append(problems, fmt.Sprintf("unexpected bucket %s", got.key()))
+		}
+	}
+
+	return problems
+}
@@ -0,0 +1,130 @@
+package database
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ChixXx1/expense-tracker/internal/models"
+)
+
+// fullScanTotals - расчет отчета до появления корзин: перебор транзакций с точным сравнением моментов
+func fullScanTotals(transactions []models.Transaction, startDate, endDate time.Time) (income, expenses float64, byCategory map[int]float64) {
+	byCategory = make(map[int]float64)
+	for _, tx := range transactions {
+		if tx.Date.Before(startDate) || tx.Date.After(endDate) {
+			continue
+		}
+		switch tx.Type {
+		case models.TransactionTypeIncome:
+			income += tx.Amount
+		case models.TransactionTypeExpense:
+			expenses += tx.Amount
+		}
+		byCategory[tx.CategoryID] += tx.Amount
+	}
+	return income, expenses, byCategory
+}
+
+// Отчеты по корзинам совпадают с полным перебором и для дат со смещением пояса, и на границах диапазона
+func TestAggregatesMatchFullScan(t *testing.T) {
+	s := NewJSONStorage(filepath.Join(t.TempDir(), "data.json"))
+	ctx := WithLedger(context.Background(), 1, 1)
+
+	expense := models.Category{Name: "Еда", Type: models.TransactionTypeExpense}
+	income := models.Category{Name: "Зарплата", Type: models.TransactionTypeIncome}
+	for _, cat := range []*models.Category{&expense, &income} {
+		if err := s.CreateCategory(ctx, cat); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	moscow := time.FixedZone("MSK", 3*60*60)
+	newYork := time.FixedZone("EST", -5*60*60)
+	dates := []time.Time{
+		// в местном поясе 1 марта, в UTC - еще 28 февраля
+		time.Date(2026, 3, 1, 0, 0, 0, 0, moscow),
+		time.Date(2026, 3, 1, 2, 59, 59, 0, moscow),
+		time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC),
+		// в местном поясе 31 марта, в UTC - уже 1 апреля
+		time.Date(2026, 3, 31, 21, 0, 0, 0, newYork),
+		time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC),
+		time.Date(2026, 3, 31, 10, 0, 0, 0, time.UTC),
+		time.Date(2026, 3, 31, 23, 59, 59, 0, time.UTC),
+		time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC),
+	}
+	for i, date := range dates {
+		category := expense
+		if i%3 == 0 {
+			category = income
+		}
+		tx := models.Transaction{Amount: float64(10 * (i + 1)), Type: category.Type, CategoryID: category.ID, Date: date,
+			Description: "Покупка", PaymentMethod: models.PaymentMethodCard}
+		if err := s.CreateTransaction(ctx, &tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	day := func(month time.Month, d int) time.Time { return time.Date(2026, month, d, 0, 0, 0, 0, time.UTC) }
+	ranges := []struct {
+		name       string
+		start, end time.Time
+	}{
+		// так диапазон задает обработчик отчетов: конец - полночь последнего дня
+		{"handler range", day(3, 1), day(3, 31)},
+		{"whole days", day(3, 1), day(4, 1).Add(-time.Nanosecond)},
+		{"single day", day(3, 31), day(4, 1).Add(-time.Nanosecond)},
+		{"mid-day bounds", day(3, 1).Add(2 * time.Hour), day(3, 31).Add(12 * time.Hour)},
+		{"local midnight bounds", time.Date(2026, 3, 1, 0, 0, 0, 0, moscow), time.Date(2026, 4, 1, 0, 0, 0, 0, newYork)},
+		{"empty", day(5, 1), day(5, 31)},
+	}
+
+	for _, r := range ranges {
+		wantIncome, wantExpenses, wantByCategory := fullScanTotals(s.transactions, r.start, r.end)
+
+		summary, err := s.GetFinancialSummary(ctx, r.start, r.end)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(summary.TotalIncome-wantIncome) > 1e-9 || math.Abs(summary.TotalExpenses-wantExpenses) > 1e-9 {
+			t.Errorf("%s: income %.2f, expenses %.2f; full scan gives %.2f, %.2f",
+				r.name, summary.TotalIncome, summary.TotalExpenses, wantIncome, wantExpenses)
+		}
+
+		categories, err := s.GetCategorySummary(ctx, r.start, r.end)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[int]float64)
+		for _, row := range categories {
+			got[row.CategoryID] = row.Amount
+		}
+		for id, want := range wantByCategory {
+			if math.Abs(got[id]-want) > 1e-9 {
+				t.Errorf("%s: category %d amount %.2f, full scan gives %.2f", r.name, id, got[id], want)
+			}
+		}
+		if len(got) != len(wantByCategory) {
+			t.Errorf("%s: %d categories, full scan gives %d", r.name, len(got), len(wantByCategory))
+		}
+	}
+
+	// бюджет на март с месяцем в местном поясе: границы периода не совпадают с полночью UTC
+	budget := models.Budget{CategoryID: expense.ID, Amount: 1000, Period: models.BudgetPeriodMonthly,
+		Month: time.Date(2026, 3, 1, 0, 0, 0, 0, moscow)}
+	if err := s.CreateBudget(ctx, &budget); err != nil {
+		t.Fatal(err)
+	}
+	report, err := s.GetBudgetReport(ctx, budget.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start, end := budgetPeriod(budget)
+	_, _, byCategory := fullScanTotals(s.transactions, start, end)
+	if math.Abs(report.SpentAmount-byCategory[expense.ID]) > 1e-9 {
+		t.Errorf("budget report: spent %.2f, full scan gives %.2f", report.SpentAmount, byCategory[expense.ID])
+	}
+}
@@ -0,0 +1,145 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ChixXx1/expense-tracker/internal/models"
+)
+
+const (
+	BulkCreate = "create"
+	BulkUpdate = "update"
+	BulkDelete = "delete"
+)
+
+// BulkSet - поля, которые update выставляет всем выбранным транзакциям; nil - не менять
+type BulkSet struct {
+	CategoryID    *int    `json:"category_id,omitempty"`
+	Type          *string `json:"type,omitempty"`
+	PaymentMethod *string `json:"payment_method,omitempty"`
+	Description   *string `json:"description,omitempty"`
+	Notes         *string `json:"notes,omitempty"`
+}
+
+func (set BulkSet) apply(tx *models.Transaction) {
+	if set.CategoryID != nil {
+		tx.CategoryID = *set.CategoryID
+	}
+	if set.Type != nil {
+		tx.Type = *set.Type
+	}
+	if set.PaymentMethod != nil {
+		tx.PaymentMethod = *set.PaymentMethod
+	}
+	if set.Description != nil {
+		tx.Description = *set.Description
+	}
+	if set.Notes != nil {
+		tx.Notes = *set.Notes
+	}
+}
+
+// BulkOperation - одна операция пакета.
+// create использует Transaction; update и delete выбирают записи по ID (с проверкой Version, если она задана)
+// или по Filter, update затем применяет Set.
+type BulkOperation struct {
+	Op          string
+	ID          int
+	Version     int
+	Filter      *TransactionFilters
+	Transaction *models.Transaction
+	Set         *BulkSet
+}
+
+// ApplyBulk выполняет операции по очереди и останавливается на первой ошибке.
+// Атомарность обеспечивает вызывающий, передавая хранилище из WithTx.
+func ApplyBulk(ctx context.Context, storage Storage, operations []BulkOperation) ([]models.BulkResult, error) {
+	results := make([]models.BulkResult, 0, len(operations))
+	for i, op := range operations {
+		result, err := applyBulkOperation(ctx, storage, op)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i+1, err)
+		}
+		results = append(results, *result)
+	}
+	return results, nil
+}
+
+func applyBulkOperation(ctx context.Context, storage Storage, op BulkOperation) (*models.BulkResult, error) {
+	switch op.Op {
+	case BulkCreate:
+		if op.Transaction == nil {
+			return nil, invalidField("transaction", "transaction is required for create")
+		}
+		tx := *op.Transaction
+		if err := storage.CreateTransaction(ctx, &tx); err != nil {
+			return nil, err
+		}
+		return &models.BulkResult{Op: op.Op, IDs: []int{tx.ID}, Transactions: []models.Transaction{tx}}, nil
+
+	case BulkUpdate:
+		if op.Set == nil {
+			return nil, invalidField("set", "set is required for update")
+		}
+		targets, err := bulkTargets(ctx, storage, op)
+		if err != nil {
+			return nil, err
+		}
+		result := &models.BulkResult{Op: op.Op, IDs: []int{}, Transactions: []models.Transaction{}}
+		for _, tx := range targets {
+			op.Set.apply(&tx)
+			if err := storage.UpdateTransaction(ctx, &tx); err != nil {
+				return nil, fmt.Errorf("transaction %d: %w", tx.ID, err)
+			}
+			result.IDs = append(result.IDs, tx.ID)
+			result.Transactions = append(result.Transactions, tx)
+		}
+		return result, nil
+
+	case BulkDelete:
+		targets, err := bulkTargets(ctx, storage, op)
+		if err != nil {
+			return nil, err
+		}
+		result := &models.BulkResult{Op: op.Op, IDs: []int{}}
+		for _, tx := range targets {
+			if err := storage.DeleteTransaction(ctx, tx.ID, tx.Version); err != nil {
+				return nil, fmt.Errorf("transaction %d: %w", tx.ID, err)
+			}
+			result.IDs = append(result.IDs, tx.ID)
+		}
+		return result, nil
+
+	default:
+		return nil, invalidField("op", fmt.Sprintf("unknown operation '%s', use create, update or delete", op.Op))
+	}
+}
+
+// bulkTargets возвращает транзакции, выбранные операцией по ID или по фильтру
+func bulkTargets(ctx context.Context, storage Storage, op BulkOperation) ([]models.Transaction, error) {
+	if (op.ID == 0) == (op.Filter == nil) {
+		return nil, invalidField("id", "exactly one of id or filter is required")
+	}
+
+	if op.ID != 0 {
+		tx, err := storage.GetTransactionByID(ctx, op.ID)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkVersion(op.Version, tx.Version); err != nil {
+			return nil, err
+		}
+		return []models.Transaction{*tx}, nil
+	}
+
+	// пагинация к выбору записей не применяется
+	filters := *op.Filter
+	filters.Limit, filters.Offset, filters.Cursor = nil, nil, ""
+
+	page, err := storage.GetTransactions(ctx, filters)
+	if err != nil {
+		return nil, err
+	}
+	return page.Transactions, nil
+}
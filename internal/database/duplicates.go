@@ -0,0 +1,121 @@
+package database
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/ChixXx1/expense-tracker/internal/models"
+)
+
+const (
+	duplicateDateWindow = 3 * 24 * time.Hour
+	// Пустое описание не исключает дубликат: такие пары получают пороговое значение
+	duplicateMinSimilarity = 0.6
+)
+
+// duplicateScore возвращает сходство описаний, если транзакции похожи на дубликаты, и false иначе
+func duplicateScore(a, b models.Transaction) (float64, bool) {
+	if a.ID != 0 && a.ID == b.ID {
+		return 0, false
+	}
+
+	if a.Type != b.Type || a.Account != b.Account {
+		return 0, false
+	}
+
+	if math.Abs(a.Amount-b.Amount) >= 0.005 {
+		return 0, false
+	}
+
+	if diff := a.Date.Sub(b.Date); diff > duplicateDateWindow || diff < -duplicateDateWindow {
+		return 0, false
+	}
+
+	similarity := descriptionSimilarity(a.Description, b.Description)
+	if similarity < duplicateMinSimilarity {
+		return 0, false
+	}
+
+	return similarity, true
+}
+
+// descriptionSimilarity - доля общих слов относительно более короткого описания,
+// так "PEREKRESTOK 1234 MOSCOW" и "Perekrestok" считаются одинаковыми
+func descriptionSimilarity(a, b string) float64 {
+	tokensA, tokensB := tokenSet(a), tokenSet(b)
+	if len(tokensA) == 0 || len(tokensB) == 0 {
+		return duplicateMinSimilarity
+	}
+
+	common := 0
+	for token := range tokensA {
+		if tokensB[token] {
+			common++
+		}
+	}
+
+	return float64(common) / float64(min(len(tokensA), len(tokensB)))
+}
+
+func tokenSet(value string) map[string]bool {
+	tokens := make(map[string]bool)
+	for _, token := range strings.FieldsFunc(strings.ToLower(value), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		tokens[token] = true
+	}
+	return tokens
+}
+
+func pairKey(a, b int) [2]int {
+	if a > b {
+		a, b = b, a
+	}
+	return [2]int{a, b}
+}
+
+func daysApart(a, b time.Time) int {
+	diff := a.Sub(b)
+	if diff < 0 {
+		diff = -diff
+	}
+	return int(diff.Hours() / 24)
+}
+
+// findDuplicatePairs сортирует по дате и сравнивает только соседей внутри окна
+func findDuplicatePairs(ctx context.Context, transactions []models.Transaction, dismissed map[[2]int]bool) ([]models.DuplicatePair, error) {
+	sorted := make([]models.Transaction, len(transactions))
+	copy(sorted, transactions)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Date.Before(sorted[j].Date)
+	})
+
+	pairs := []models.DuplicatePair{}
+	for i := range sorted {
+		if err := canceled(ctx, i); err != nil {
+			return nil, err
+		}
+		for j := i + 1; j < len(sorted); j++ {
+			if sorted[j].Date.Sub(sorted[i].Date) > duplicateDateWindow {
+				break
+			}
+			if dismissed[pairKey(sorted[i].ID, sorted[j].ID)] {
+				continue
+			}
+			if similarity, ok := duplicateScore(sorted[i], sorted[j]); ok {
+				pairs = append(pairs, models.DuplicatePair{
+					First:      sorted[i],
+					Second:     sorted[j],
+					Similarity: similarity,
+					DaysApart:  daysApart(sorted[i].Date, sorted[j].Date),
+				})
+			}
+		}
+	}
+
+	return pairs, nil
+}
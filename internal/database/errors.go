@@ -0,0 +1,88 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ChixXx1/expense-tracker/internal/models"
+)
+
+// Ошибки хранилища проверяются через errors.Is, текст сообщения для клиента берется из Error()
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrConflict   = errors.New("conflict")
+	ErrValidation = errors.New("validation failed")
+	// ErrPreconditionFailed - запись изменилась с тех пор, как клиент ее прочитал
+	ErrPreconditionFailed = errors.New("precondition failed")
+)
+
+// ValidationError - ошибка валидации с указанием поля; errors.Is(err, ErrValidation) == true
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
+
+type storageError struct {
+	kind    error
+	message string
+}
+
+func (e *storageError) Error() string {
+	return e.message
+}
+
+func (e *storageError) Unwrap() error {
+	return e.kind
+}
+
+func notFound(message string) error {
+	return &storageError{kind: ErrNotFound, message: message}
+}
+
+func conflict(message string) error {
+	return &storageError{kind: ErrConflict, message: message}
+}
+
+// checkVersion сравнивает ожидаемую клиентом версию с текущей; 0 означает «без проверки»
+func checkVersion(expected, current int) error {
+	if expected != 0 && expected != current {
+		return &storageError{
+			kind:    ErrPreconditionFailed,
+			message: fmt.Sprintf("version mismatch: expected %d, current is %d", expected, current),
+		}
+	}
+	return nil
+}
+
+// cancelCheckEvery - как часто длинные проходы по данным проверяют отмену запроса
+const cancelCheckEvery = 1024
+
+// canceled возвращает ошибку контекста на каждой cancelCheckEvery-й итерации
+func canceled(ctx context.Context, i int) error {
+	if i%cancelCheckEvery != 0 {
+		return nil
+	}
+	return ctx.Err()
+}
+
+func invalidField(field, message string) error {
+	return &ValidationError{Field: field, Message: message}
+}
+
+// invalid приводит ошибку валидации модели к ValidationError, сохраняя имя поля
+func invalid(err error) error {
+	var fieldErr *models.FieldError
+	if errors.As(err, &fieldErr) {
+		return &ValidationError{Field: fieldErr.Field, Message: fieldErr.Message}
+	}
+	return &ValidationError{Message: err.Error()}
+}
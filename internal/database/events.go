@@ -0,0 +1,77 @@
+package database
+
+import (
+	"context"
+
+	"github.com/ChixXx1/expense-tracker/internal/models"
+)
+
+// Типы событий об изменении данных
+const (
+	EventTransactionSaved   = "transaction.saved"
+	EventTransactionDeleted = "transaction.deleted"
+	EventCategorySaved      = "category.saved"
+	EventCategoryDeleted    = "category.deleted"
+	// EventReset - данные заменены целиком; за ним следуют события saved для всех записей
+	EventReset = "reset"
+)
+
+// Event описывает одно изменение. Для saved заполнены Transaction или Category, для deleted - только ID.
+type Event struct {
+	Type        string
+	ID          int
+	Transaction *models.Transaction
+	Category    *models.Category
+}
+
+// Listener вызывается синхронно под блокировкой хранилища, поэтому не должен обращаться к нему
+type Listener func(Event)
+
+func transactionSaved(tx models.Transaction) Event {
+	return Event{Type: EventTransactionSaved, ID: tx.ID, Transaction: &tx}
+}
+
+func categorySaved(cat models.Category) Event {
+	return Event{Type: EventCategorySaved, ID: cat.ID, Category: &cat}
+}
+
+// Subscribe регистрирует слушателя и сразу передает ему текущее состояние через EventReset
+func (s *JSONStorage) Subscribe(ctx context.Context, listener Listener) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.listeners = append(s.listeners, listener)
+	for _, event := range s.stateEvents() {
+		listener(event)
+	}
+	return nil
+}
+
+func (s *JSONStorage) notify(events ...Event) {
+	if s.inTx {
+		s.pending = append(s.pending, events...)
+		return
+	}
+	for _, listener := range s.listeners {
+		for _, event := range events {
+			listener(event)
+		}
+	}
+}
+
+// stateEvents - события, воспроизводящие все текущие данные с нуля
+func (s *JSONStorage) stateEvents() []Event {
+	events := make([]Event, 0, 1+len(s.categories)+len(s.transactions))
+	events = append(events, Event{Type: EventReset})
+	for _, cat := range s.categories {
+		events = append(events, categorySaved(cat))
+	}
+	for _, tx := range s.transactions {
+		events = append(events, transactionSaved(tx))
+	}
+	return events
+}
@@ -0,0 +1,74 @@
+package database
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/ChixXx1/expense-tracker/internal/models"
+)
+
+// ValueFilter - фильтр по набору допустимых значений; при Negate значения, наоборот, исключаются.
+// Пустой фильтр пропускает все.
+type ValueFilter[T comparable] struct {
+	Values []T
+	Negate bool
+}
+
+func (f ValueFilter[T]) IsSet() bool {
+	return len(f.Values) > 0
+}
+
+func (f ValueFilter[T]) Match(value T) bool {
+	if !f.IsSet() {
+		return true
+	}
+	return slices.Contains(f.Values, value) != f.Negate
+}
+
+// searchTerms разбивает поисковую строку на слова в нижнем регистре
+func searchTerms(search string) []string {
+	return strings.Fields(strings.ToLower(search))
+}
+
+// match проверяет транзакцию по всем фильтрам, кроме пагинации.
+// categories - множество существующих категорий, нужно для Uncategorized.
+func (f TransactionFilters) match(tx models.Transaction, terms []string, categories map[int]bool) bool {
+	if f.StartDate != nil && tx.Date.Before(*f.StartDate) {
+		return false
+	}
+
+	if f.EndDate != nil && tx.Date.After(*f.EndDate) {
+		return false
+	}
+
+	if f.CreatedAfter != nil && !tx.CreatedAt.After(*f.CreatedAfter) {
+		return false
+	}
+
+	if !f.CategoryID.Match(tx.CategoryID) || !f.Type.Match(tx.Type) || !f.PaymentMethod.Match(tx.PaymentMethod) {
+		return false
+	}
+
+	if f.Uncategorized && categories[tx.CategoryID] {
+		return false
+	}
+
+	if f.MinAmount != nil && tx.Amount < *f.MinAmount {
+		return false
+	}
+
+	if f.MaxAmount != nil && tx.Amount > *f.MaxAmount {
+		return false
+	}
+
+	if len(terms) > 0 {
+		description := strings.ToLower(tx.Description)
+		for _, term := range terms {
+			if !strings.Contains(description, term) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -1,9 +1,12 @@
 package database
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
+	"fmt"
 	"os"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -11,30 +14,69 @@ import (
 )
 
 type JSONStorage struct {
-	mu           sync.RWMutex
-	categories   []models.Category
-	transactions []models.Transaction
-	budgets      []models.Budget
-	filepath     string
-	nextID       map[string]int
+	mu             sync.RWMutex
+	categories     []models.Category
+	transactions   []models.Transaction
+	budgets        []models.Budget
+	importProfiles []models.ImportProfile
+	users          []models.User
+	refreshTokens  []models.RefreshToken
+	ledgers        []models.Ledger
+	ledgerInvites  []models.LedgerInvite
+	dismissed      map[[2]int]bool
+	aggregates     *aggregates
+	filepath       string
+	nextID         map[string]int
+	listeners      []Listener
+
+	// sessionEpoch растет, когда восстановление заменяет учетные записи: токены прежних эпох недействительны
+	sessionEpoch int
+
+	// inTx - копия внутри WithTx: запись в файл откладывается до фиксации, события копятся в pending
+	inTx    bool
+	pending []Event
+}
+
+// storageData - формат файла данных
+type storageData struct {
+	Categories     []models.Category      `json:"categories"`
+	Transactions   []models.Transaction   `json:"transactions"`
+	Budgets        []models.Budget        `json:"budgets"`
+	ImportProfiles []models.ImportProfile `json:"import_profiles"`
+	Users          []models.UserRecord    `json:"users"`
+	RefreshTokens  []models.RefreshToken  `json:"refresh_tokens"`
+	Ledgers        []models.Ledger        `json:"ledgers"`
+	LedgerInvites  []models.LedgerInvite  `json:"ledger_invites"`
+	Dismissed      [][2]int               `json:"dismissed_duplicates"`
+	Aggregates     []AggregateBucket      `json:"aggregates"`
+	// AggregatesVersion - способ раскладки корзин, см. aggregatesVersion
+	AggregatesVersion int `json:"aggregates_version"`
+	SessionEpoch      int `json:"session_epoch"`
 }
 
 func NewJSONStorage(filepath string) *JSONStorage {
 	storage := &JSONStorage{
 		mu: sync.RWMutex{},
 		//categories: 	models.GetDefaultCategories(),
-		budgets:  []models.Budget{},
-		filepath: filepath,
+		budgets:    []models.Budget{},
+		dismissed:  make(map[[2]int]bool),
+		aggregates: newAggregates(),
+		filepath:   filepath,
 		nextID: map[string]int{
-			"category":    1,
-			"transaction": 1,
-			"budget":      1,
+			"category":       1,
+			"transaction":    1,
+			"budget":         1,
+			"import_profile": 1,
+			"user":           1,
+			"ledger":         1,
 		},
 	}
 
 	if err := storage.load(); err != nil {
-		storage.categories = models.GetDefaultCategories()
+		// категории по умолчанию получает каждая новая книга
+		storage.categories = []models.Category{}
 		storage.transactions = []models.Transaction{}
+		storage.normalizeVersions()
 		storage.updateNextID()
 		storage.save()
 	}
@@ -43,12 +85,20 @@ func NewJSONStorage(filepath string) *JSONStorage {
 }
 
 func (s *JSONStorage) updateNextID() {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.resetNextID()
+}
 
+// resetNextID пересчитывает следующие ID по данным, вызывать под блокировкой
+func (s *JSONStorage) resetNextID() {
 	catMaxID := 0
 	transMaxID := 0
 	budgetMaxID := 0
+	profileMaxID := 0
+	userMaxID := 0
+	ledgerMaxID := 0
 
 	for _, cat := range s.categories {
 		if cat.ID > catMaxID {
@@ -68,9 +118,64 @@ func (s *JSONStorage) updateNextID() {
 		}
 	}
 
+	for _, profile := range s.importProfiles {
+		if profile.ID > profileMaxID {
+			profileMaxID = profile.ID
+		}
+	}
+
 	s.nextID["category"] = catMaxID + 1
 	s.nextID["transaction"] = transMaxID + 1
 	s.nextID["budget"] = budgetMaxID + 1
+	for _, user := range s.users {
+		if user.ID > userMaxID {
+			userMaxID = user.ID
+		}
+	}
+
+	s.nextID["import_profile"] = profileMaxID + 1
+	s.nextID["user"] = userMaxID + 1
+
+	for _, ledger := range s.ledgers {
+		if ledger.ID > ledgerMaxID {
+			ledgerMaxID = ledger.ID
+		}
+	}
+	s.nextID["ledger"] = ledgerMaxID + 1
+}
+
+// normalizeVersions выдает версию 1 записям из файлов, созданных до появления версий
+func (s *JSONStorage) normalizeVersions() {
+	for i := range s.categories {
+		if s.categories[i].Version == 0 {
+			s.categories[i].Version = 1
+		}
+	}
+	for i := range s.transactions {
+		if s.transactions[i].Version == 0 {
+			s.transactions[i].Version = 1
+		}
+	}
+	for i := range s.budgets {
+		if s.budgets[i].Version == 0 {
+			s.budgets[i].Version = 1
+		}
+	}
+	for i := range s.importProfiles {
+		if s.importProfiles[i].Version == 0 {
+			s.importProfiles[i].Version = 1
+		}
+	}
+	for i := range s.users {
+		if s.users[i].Version == 0 {
+			s.users[i].Version = 1
+		}
+	}
+	for i := range s.ledgers {
+		if s.ledgers[i].Version == 0 {
+			s.ledgers[i].Version = 1
+		}
+	}
 }
 
 func (s *JSONStorage) load() error {
@@ -79,11 +184,7 @@ func (s *JSONStorage) load() error {
 		return err
 	}
 
-	var data struct {
-		Categories   []models.Category    `json:"categories"`
-		Transactions []models.Transaction `json:"transactions"`
-		Budgets      []models.Budget      `json:"budgets"`
-	}
+	var data storageData
 
 	if err := json.Unmarshal(fileData, &data); err != nil {
 		return err
@@ -93,24 +194,67 @@ func (s *JSONStorage) load() error {
 	s.categories = data.Categories
 	s.transactions = data.Transactions
 	s.budgets = data.Budgets
+	s.importProfiles = data.ImportProfiles
+	s.users = models.UsersFromRecords(data.Users)
+	s.refreshTokens = data.RefreshTokens
+	s.ledgers = data.Ledgers
+	s.ledgerInvites = data.LedgerInvites
+	s.sessionEpoch = data.SessionEpoch
+	s.dismissed = make(map[[2]int]bool, len(data.Dismissed))
+	for _, pair := range data.Dismissed {
+		s.dismissed[pairKey(pair[0], pair[1])] = true
+	}
+	// Старые файлы без агрегатов или с корзинами по местному времени пересчитываем по транзакциям
+	if data.Aggregates == nil || data.AggregatesVersion != aggregatesVersion {
+		s.aggregates = buildAggregates(data.Transactions)
+	} else {
+		s.aggregates = aggregatesFromBuckets(data.Aggregates)
+	}
+	s.normalizeVersions()
+	s.resetNextID()
+	if s.migrateLedgers() {
+		if err := s.save(); err != nil {
+			s.mu.Unlock()
+			return err
+		}
+	}
 	s.mu.Unlock()
 
-	s.updateNextID()
-
 	return nil
 }
 func (s *JSONStorage) save() error {
 	//s.mu.RLock()
 	//defer s.mu.RUnlock()
 
-	data := struct {
-		Categories   []models.Category    `json:"categories"`
-		Transactions []models.Transaction `json:"transactions"`
-		Budgets      []models.Budget      `json:"budgets"`
-	}{
-		Categories:   s.categories,
-		Transactions: s.transactions,
-		Budgets:      s.budgets,
+	if s.inTx {
+		return nil
+	}
+
+	dismissed := make([][2]int, 0, len(s.dismissed))
+	for pair := range s.dismissed {
+		dismissed = append(dismissed, pair)
+	}
+	sort.Slice(dismissed, func(i, j int) bool {
+		if dismissed[i][0] != dismissed[j][0] {
+			return dismissed[i][0] < dismissed[j][0]
+		}
+		return dismissed[i][1] < dismissed[j][1]
+	})
+
+	data := storageData{
+		Categories:     s.categories,
+		Transactions:   s.transactions,
+		Budgets:        s.budgets,
+		ImportProfiles: s.importProfiles,
+		Users:          models.UserRecords(s.users),
+		RefreshTokens:  s.refreshTokens,
+		Ledgers:        s.ledgers,
+		LedgerInvites:  s.ledgerInvites,
+		Dismissed:      dismissed,
+		Aggregates:     s.aggregates.list(),
+
+		AggregatesVersion: aggregatesVersion,
+		SessionEpoch:      s.sessionEpoch,
 	}
 
 	fileData, err := json.MarshalIndent(&data, "", "	")
@@ -121,166 +265,229 @@ func (s *JSONStorage) save() error {
 	return os.WriteFile(s.filepath, fileData, 0644)
 }
 
-func (s *JSONStorage) GetCategories() ([]models.Category, error) {
+func (s *JSONStorage) GetCategories(ctx context.Context) ([]models.Category, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	categories := make([]models.Category, len(s.categories))
-	copy(categories, s.categories)
+
+	sc, err := scopeOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	categories := []models.Category{}
+	for _, cat := range s.categories {
+		if sc.owns(cat.LedgerID) {
+			categories = append(categories, cat)
+		}
+	}
 
 	return categories, nil
 }
-func (s *JSONStorage) GetCategoryByID(id int) (*models.Category, error) {
+func (s *JSONStorage) GetCategoryByID(ctx context.Context, id int) (*models.Category, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	for i := range s.categories {
-		if id == s.categories[i].ID {
-			category := &s.categories[i]
-			return category, nil
-		}
+	sc, err := scopeOf(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil, errors.New("category is not found!")
+	if i := s.categoryIndex(sc, id); i >= 0 {
+		category := s.categories[i]
+		return &category, nil
+	}
+
+	return nil, notFound("category not found")
 }
-func (s *JSONStorage) CreateCategory(category *models.Category) error {
+func (s *JSONStorage) CreateCategory(ctx context.Context, category *models.Category) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if err := category.Validate(); err != nil {
+	sc, err := scopeOf(ctx)
+	if err != nil {
 		return err
 	}
 
+	if err := category.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	sc.assign(&category.LedgerID, &category.OwnerID)
 	for _, cat := range s.categories {
-		if cat.Name == category.Name && cat.Type == category.Type {
-			return errors.New("category with this name is already exists with this type")
+		if cat.LedgerID == category.LedgerID && cat.Name == category.Name && cat.Type == category.Type {
+			return conflict("category with this name already exists for this type")
 		}
 	}
 
 	category.ID = s.nextID["category"]
+	category.Version = 1
 	s.nextID["category"]++
 	s.categories = append(s.categories, *category)
+	s.notify(categorySaved(*category))
 
 	return s.save()
 }
-func (s *JSONStorage) UpdateCategory(category *models.Category) error {
+func (s *JSONStorage) UpdateCategory(ctx context.Context, category *models.Category) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if err := category.Validate(); err != nil {
+	sc, err := scopeOf(ctx)
+	if err != nil {
 		return err
 	}
 
+	if err := category.Validate(); err != nil {
+		return invalid(err)
+	}
+
 	for i, cat := range s.categories {
-		if cat.ID == category.ID {
+		if cat.ID == category.ID && sc.owns(cat.LedgerID) {
+			if err := checkVersion(category.Version, cat.Version); err != nil {
+				return err
+			}
+			category.LedgerID = cat.LedgerID
+			category.OwnerID = cat.OwnerID
 			for j, other := range s.categories {
-				if i != j && category.Name == other.Name && category.Type == other.Type {
-					return errors.New("category with this name already exists for this type")
+				if i != j && other.LedgerID == cat.LedgerID && category.Name == other.Name && category.Type == other.Type {
+					return conflict("category with this name already exists for this type")
 				}
 			}
+			category.Version = cat.Version + 1
 			s.categories[i] = *category
+			s.notify(categorySaved(*category))
 			return s.save()
 		}
 	}
 
-	return errors.New("category is not found")
+	return notFound("category not found")
 }
-func (s *JSONStorage) DeleteCategory(id int) error {
+func (s *JSONStorage) DeleteCategory(ctx context.Context, id, expectedVersion int) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	sc, err := scopeOf(ctx)
+	if err != nil {
+		return err
+	}
+
 	for i, cat := range s.categories {
-		if id == cat.ID {
+		if id == cat.ID && sc.owns(cat.LedgerID) {
+			if err := checkVersion(expectedVersion, cat.Version); err != nil {
+				return err
+			}
 			s.categories = append(s.categories[:i], s.categories[i+1:]...)
+			s.notify(Event{Type: EventCategoryDeleted, ID: id})
 			return s.save()
 		}
 	}
 
-	return errors.New("category is not found")
+	return notFound("category not found")
 }
 
-func (s *JSONStorage) GetTransactions(filters TransactionFilters) ([]models.Transaction, error) {
+// VerifyAggregates пересчитывает агрегаты по транзакциям и возвращает расхождения
+func (s *JSONStorage) VerifyAggregates(ctx context.Context) ([]string, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	var result []models.Transaction
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
-	for _, tr := range s.transactions {
-		if filters.StartDate != nil && tr.Date.Before(*filters.StartDate) {
-			continue
-		}
+	return s.aggregates.diff(buildAggregates(s.transactions)), nil
+}
 
-		if filters.EndDate != nil && tr.Date.After(*filters.EndDate) {
-			continue
-		}
+func (s *JSONStorage) RebuildAggregates(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-		if filters.CategoryID != nil && tr.CategoryID != *filters.CategoryID {
-			continue
-		}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
-		if filters.Type != nil && tr.Type != *filters.Type {
-			continue
-		}
+	s.aggregates = buildAggregates(s.transactions)
 
-		if filters.PaymentMethod != nil && tr.PaymentMethod != *filters.PaymentMethod {
-			continue
-		}
+	return s.save()
+}
+
+func (s *JSONStorage) GetTransactions(ctx context.Context, filters TransactionFilters) (*models.TransactionPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-		result = append(result, tr)
+	sc, err := scopeOf(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	start := 0
-	if filters.Offset != nil && *filters.Offset > 0 {
-		start = *filters.Offset
+	transactions, err := s.filterTransactions(ctx, sc, filters)
+	if err != nil {
+		return nil, err
 	}
 
-	if start >= len(result) {
-		return []models.Transaction{}, nil
+	return paginate(transactions, filters)
+}
+
+func (s *JSONStorage) filterTransactions(ctx context.Context, sc scope, filters TransactionFilters) ([]models.Transaction, error) {
+	var categories map[int]bool
+	if filters.Uncategorized {
+		categories = make(map[int]bool, len(s.categories))
+		for _, cat := range s.categories {
+			categories[cat.ID] = true
+		}
 	}
 
-	end := len(result)
-	if filters.Limit != nil && *filters.Limit > 0 {
-		end = start + *filters.Limit
-		if end > len(result) {
-			end = len(result)
+	terms := searchTerms(filters.Search)
+
+	var result []models.Transaction
+	for i, tr := range s.transactions {
+		if err := canceled(ctx, i); err != nil {
+			return nil, err
+		}
+		if sc.owns(tr.LedgerID) && filters.match(tr, terms, categories) {
+			result = append(result, tr)
 		}
 	}
 
-	return result[start:end], nil
+	return result, nil
 }
-func (s *JSONStorage) GetTransactionByID(id int) (*models.Transaction, error) {
+func (s *JSONStorage) GetTransactionByID(ctx context.Context, id int) (*models.Transaction, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	for i, tr := range s.transactions {
-		if tr.ID == id {
-			transaction := s.transactions[i]
-			return &transaction, nil
-		}
+	sc, err := scopeOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if i := s.transactionIndex(sc, id); i >= 0 {
+		transaction := s.transactions[i]
+		return &transaction, nil
 	}
 
-	return nil, errors.New("transaction not found")
+	return nil, notFound("transaction not found")
 }
-func (s *JSONStorage) CreateTransaction(transaction *models.Transaction) error {
+func (s *JSONStorage) CreateTransaction(ctx context.Context, transaction *models.Transaction) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if err := transaction.Validate(); err != nil {
+	sc, err := scopeOf(ctx)
+	if err != nil {
 		return err
 	}
 
-	categoryExists := false
-	for _, cat := range s.categories {
-		if cat.ID == transaction.CategoryID {
-			categoryExists = true
-			break
-		}
+	if err := transaction.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	sc.assign(&transaction.LedgerID, &transaction.OwnerID)
+	if !s.ownsCategory(transaction.LedgerID, transaction.CategoryID) {
+		return invalidField("category_id", "category does not exist")
 	}
 
-	if !categoryExists {
-		return errors.New("category does not exist")
+	if key := transaction.ExternalKey(); key != "" && s.externalKeys(transaction.LedgerID)[key] {
+		return conflict("transaction already imported")
 	}
 
 	transaction.ID = s.nextID["transaction"]
+	transaction.Version = 1
 	s.nextID["transaction"]++
 
 	if transaction.CreatedAt.IsZero() {
@@ -288,59 +495,303 @@ func (s *JSONStorage) CreateTransaction(transaction *models.Transaction) error {
 	}
 
 	s.transactions = append(s.transactions, *transaction)
+	s.aggregates.add(*transaction)
+	s.notify(transactionSaved(*transaction))
 
 	return s.save()
 }
-func (s *JSONStorage) UpdateTransaction(transaction *models.Transaction) error {
+
+// CreateTransactions сохраняет пачку транзакций за одну запись файла: либо все, либо ни одной
+func (s *JSONStorage) CreateTransactions(ctx context.Context, transactions []models.Transaction) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if err := transaction.Validate(); err != nil {
+	sc, err := scopeOf(ctx)
+	if err != nil {
 		return err
 	}
 
-	categoryExists := false
-	for _, cat := range s.categories {
-		if cat.ID == transaction.CategoryID {
-			categoryExists = true
-			break
+	// ключи импорта уникальны в пределах книги
+	imported := make(map[int]map[string]bool)
+
+	for i := range transactions {
+		if err := transactions[i].Validate(); err != nil {
+			return fmt.Errorf("transaction %d: %w", i+1, invalid(err))
+		}
+		sc.assign(&transactions[i].LedgerID, &transactions[i].OwnerID)
+		ledger := transactions[i].LedgerID
+		if !s.ownsCategory(ledger, transactions[i].CategoryID) {
+			return fmt.Errorf("transaction %d: %w", i+1, invalidField("category_id", "category does not exist"))
+		}
+		if key := transactions[i].ExternalKey(); key != "" {
+			if imported[ledger] == nil {
+				imported[ledger] = s.externalKeys(ledger)
+			}
+			if imported[ledger][key] {
+				return fmt.Errorf("transaction %d: %w", i+1, conflict("transaction already imported"))
+			}
+			imported[ledger][key] = true
+		}
+	}
+
+	now := time.Now()
+	for i := range transactions {
+		transactions[i].ID = s.nextID["transaction"]
+		transactions[i].Version = 1
+		s.nextID["transaction"]++
+
+		if transactions[i].CreatedAt.IsZero() {
+			transactions[i].CreatedAt = now
 		}
+
+		s.transactions = append(s.transactions, transactions[i])
+		s.aggregates.add(transactions[i])
+		s.notify(transactionSaved(transactions[i]))
+	}
+
+	return s.save()
+}
+func (s *JSONStorage) GetImportedKeys(ctx context.Context) (map[string]bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sc, err := scopeOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]bool)
+	for _, tr := range s.transactions {
+		if key := tr.ExternalKey(); key != "" && sc.owns(tr.LedgerID) {
+			keys[key] = true
+		}
+	}
+	return keys, nil
+}
+
+// externalKeys - ключи импорта транзакций одной книги
+func (s *JSONStorage) externalKeys(ledgerID int) map[string]bool {
+	keys := make(map[string]bool)
+	for _, tr := range s.transactions {
+		if key := tr.ExternalKey(); key != "" && tr.LedgerID == ledgerID {
+			keys[key] = true
+		}
+	}
+	return keys
+}
+func (s *JSONStorage) UpdateTransaction(ctx context.Context, transaction *models.Transaction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sc, err := scopeOf(ctx)
+	if err != nil {
+		return err
 	}
 
-	if !categoryExists {
-		return errors.New("category does not exist")
+	if err := transaction.Validate(); err != nil {
+		return invalid(err)
 	}
 
 	for i, tr := range s.transactions {
-		if tr.ID == transaction.ID {
+		if tr.ID == transaction.ID && sc.owns(tr.LedgerID) {
+			if err := checkVersion(transaction.Version, tr.Version); err != nil {
+				return err
+			}
+			if !s.ownsCategory(tr.LedgerID, transaction.CategoryID) {
+				return invalidField("category_id", "category does not exist")
+			}
+			// ledger_id, owner_id, created_at и version выставляет сервер, клиент не может их стереть или подменить
+			transaction.LedgerID = tr.LedgerID
+			transaction.OwnerID = tr.OwnerID
+			transaction.CreatedAt = tr.CreatedAt
+			transaction.Version = tr.Version + 1
+			s.aggregates.remove(tr)
+			s.aggregates.add(*transaction)
 			s.transactions[i] = *transaction
+			s.notify(transactionSaved(*transaction))
 			return s.save()
 		}
 	}
 
-	return errors.New("transaction not found")
+	return notFound("transaction not found")
 }
-func (s *JSONStorage) DeleteTransaction(id int) error {
+func (s *JSONStorage) DeleteTransaction(ctx context.Context, id, expectedVersion int) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	sc, err := scopeOf(ctx)
+	if err != nil {
+		return err
+	}
+
 	for i, tr := range s.transactions {
-		if id == tr.ID {
+		if id == tr.ID && sc.owns(tr.LedgerID) {
+			if err := checkVersion(expectedVersion, tr.Version); err != nil {
+				return err
+			}
+			s.aggregates.remove(tr)
 			s.transactions = append(s.transactions[:i], s.transactions[i+1:]...)
+			s.notify(Event{Type: EventTransactionDeleted, ID: id})
 			return s.save()
 		}
 	}
 
-	return errors.New("transaction is not found")
+	return notFound("transaction not found")
+}
+
+func (s *JSONStorage) FindDuplicates(ctx context.Context, transaction models.Transaction) ([]models.Transaction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sc, err := scopeOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	duplicates := []models.Transaction{}
+	for _, tr := range s.transactions {
+		if !sc.owns(tr.LedgerID) || s.dismissed[pairKey(tr.ID, transaction.ID)] {
+			continue
+		}
+		if _, ok := duplicateScore(transaction, tr); ok {
+			duplicates = append(duplicates, tr)
+		}
+	}
+
+	return duplicates, nil
+}
+
+func (s *JSONStorage) GetDuplicatePairs(ctx context.Context) ([]models.DuplicatePair, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sc, err := scopeOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	owned := make([]models.Transaction, 0, len(s.transactions))
+	for _, tr := range s.transactions {
+		if sc.owns(tr.LedgerID) {
+			owned = append(owned, tr)
+		}
+	}
+
+	return findDuplicatePairs(ctx, owned, s.dismissed)
+}
+
+func (s *JSONStorage) DismissDuplicate(ctx context.Context, firstID, secondID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sc, err := scopeOf(ctx)
+	if err != nil {
+		return err
+	}
+
+	if firstID == secondID {
+		return invalidField("second_id", "cannot dismiss a transaction against itself")
+	}
+
+	if s.transactionIndex(sc, firstID) < 0 || s.transactionIndex(sc, secondID) < 0 {
+		return notFound("transaction not found")
+	}
+
+	s.dismissed[pairKey(firstID, secondID)] = true
+
+	return s.save()
+}
+
+// MergeDuplicates оставляет keepID, дополняя пустые поля из removeID, и удаляет removeID
+func (s *JSONStorage) MergeDuplicates(ctx context.Context, keepID, removeID int) (*models.Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sc, err := scopeOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if keepID == removeID {
+		return nil, invalidField("remove_id", "cannot merge a transaction with itself")
+	}
+
+	keepIdx, removeIdx := s.transactionIndex(sc, keepID), s.transactionIndex(sc, removeID)
+	if keepIdx < 0 || removeIdx < 0 {
+		return nil, notFound("transaction not found")
+	}
+
+	keep := s.transactions[keepIdx]
+	removed := s.transactions[removeIdx]
+
+	if keep.Description == "" {
+		keep.Description = removed.Description
+	}
+	if keep.Notes == "" {
+		keep.Notes = removed.Notes
+	}
+	if keep.Account == "" {
+		keep.Account = removed.Account
+	}
+	if keep.ExternalID == "" {
+		keep.ExternalID = removed.ExternalID
+	}
+
+	keep.Version++
+	s.transactions[keepIdx] = keep
+	s.aggregates.remove(removed)
+	s.transactions = append(s.transactions[:removeIdx], s.transactions[removeIdx+1:]...)
+	s.notify(transactionSaved(keep), Event{Type: EventTransactionDeleted, ID: removeID})
+
+	for pair := range s.dismissed {
+		if pair[0] == removeID || pair[1] == removeID {
+			delete(s.dismissed, pair)
+		}
+	}
+
+	return &keep, s.save()
 }
 
-func (s *JSONStorage) GetBudgets(filters BudgetFilters) ([]models.Budget, error) {
+// transactionIndex ищет транзакцию в книге; запись другой книги для нее не существует
+func (s *JSONStorage) transactionIndex(sc scope, id int) int {
+	for i, tr := range s.transactions {
+		if tr.ID == id && sc.owns(tr.LedgerID) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *JSONStorage) categoryIndex(sc scope, id int) int {
+	for i, cat := range s.categories {
+		if cat.ID == id && sc.owns(cat.LedgerID) {
+			return i
+		}
+	}
+	return -1
+}
+
+// ownsCategory проверяет, что транзакция или бюджет ссылаются на категорию той же книги
+func (s *JSONStorage) ownsCategory(ledgerID, categoryID int) bool {
+	return s.categoryIndex(scope{ledgerID: ledgerID}, categoryID) >= 0
+}
+
+func (s *JSONStorage) GetBudgets(ctx context.Context, filters BudgetFilters) ([]models.Budget, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	sc, err := scopeOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	var result []models.Budget
 
 	for _, budget := range s.budgets {
+		if !sc.owns(budget.LedgerID) {
+			continue
+		}
+
 		if filters.CategoryID != nil && budget.CategoryID != *filters.CategoryID {
 			continue
 		}
@@ -364,39 +815,41 @@ func (s *JSONStorage) GetBudgets(filters BudgetFilters) ([]models.Budget, error)
 	return result, nil
 }
 
-func (s *JSONStorage) GetBudgetByID(id int) (*models.Budget, error) {
+func (s *JSONStorage) GetBudgetByID(ctx context.Context, id int) (*models.Budget, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	for i, budget := range s.budgets {
-		if budget.ID == id {
-			return &s.budgets[i], nil
-		}
+	sc, err := scopeOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if i := s.budgetIndex(sc, id); i >= 0 {
+		budget := s.budgets[i]
+		return &budget, nil
 	}
 
-	return nil, errors.New("budget not found")
+	return nil, notFound("budget not found")
 }
 
-func (s *JSONStorage) CreateBudget(budget *models.Budget) error {
+func (s *JSONStorage) CreateBudget(ctx context.Context, budget *models.Budget) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Валидация
-	if err := budget.Validate(); err != nil {
+	sc, err := scopeOf(ctx)
+	if err != nil {
 		return err
 	}
 
-	// Проверка существования категории
-	categoryExists := false
-	for _, cat := range s.categories {
-		if cat.ID == budget.CategoryID {
-			categoryExists = true
-			break
-		}
+	// Валидация
+	if err := budget.Validate(); err != nil {
+		return invalid(err)
 	}
 
-	if !categoryExists {
-		return errors.New("category does not exist")
+	// Проверка существования категории
+	sc.assign(&budget.LedgerID, &budget.OwnerID)
+	if !s.ownsCategory(budget.LedgerID, budget.CategoryID) {
+		return invalidField("category_id", "category does not exist")
 	}
 
 	// Проверка дубликата бюджета для категории в тот же период
@@ -405,11 +858,12 @@ func (s *JSONStorage) CreateBudget(budget *models.Budget) error {
 			existing.Period == budget.Period &&
 			existing.Month.Year() == budget.Month.Year() &&
 			existing.Month.Month() == budget.Month.Month() {
-			return errors.New("budget already exists for this category and period")
+			return conflict("budget already exists for this category and period")
 		}
 	}
 
 	budget.ID = s.nextID["budget"]
+	budget.Version = 1
 	s.nextID["budget"]++
 
 	if budget.CreatedAt.IsZero() {
@@ -421,55 +875,619 @@ func (s *JSONStorage) CreateBudget(budget *models.Budget) error {
 	return s.save()
 }
 
-func (s *JSONStorage) UpdateBudget(budget *models.Budget) error {
+func (s *JSONStorage) UpdateBudget(ctx context.Context, budget *models.Budget) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if err := budget.Validate(); err != nil {
+	sc, err := scopeOf(ctx)
+	if err != nil {
 		return err
 	}
 
+	if err := budget.Validate(); err != nil {
+		return invalid(err)
+	}
+
 	for i, existing := range s.budgets {
-		if existing.ID == budget.ID {
+		if existing.ID == budget.ID && sc.owns(existing.LedgerID) {
+			if err := checkVersion(budget.Version, existing.Version); err != nil {
+				return err
+			}
+			if !s.ownsCategory(existing.LedgerID, budget.CategoryID) {
+				return invalidField("category_id", "category does not exist")
+			}
+			budget.LedgerID = existing.LedgerID
+			budget.OwnerID = existing.OwnerID
+			budget.CreatedAt = existing.CreatedAt
+			budget.Version = existing.Version + 1
 			s.budgets[i] = *budget
 			return s.save()
 		}
 	}
 
-	return errors.New("budget not found")
+	return notFound("budget not found")
 }
 
-func (s *JSONStorage) DeleteBudget(id int) error {
+func (s *JSONStorage) DeleteBudget(ctx context.Context, id, expectedVersion int) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	sc, err := scopeOf(ctx)
+	if err != nil {
+		return err
+	}
+
 	for i, budget := range s.budgets {
-		if budget.ID == id {
+		if budget.ID == id && sc.owns(budget.LedgerID) {
+			if err := checkVersion(expectedVersion, budget.Version); err != nil {
+				return err
+			}
 			s.budgets = append(s.budgets[:i], s.budgets[i+1:]...)
 			return s.save()
 		}
 	}
 
-	return errors.New("budget not found")
+	return notFound("budget not found")
 }
 
-func (s *JSONStorage) GetFinancialSummary(startDate, endDate time.Time) (*models.FinancialSummary, error) {
+func (s *JSONStorage) GetImportProfiles(ctx context.Context) ([]models.ImportProfile, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	var totalIncome, totalExpenses float64
+	sc, err := scopeOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	profiles := []models.ImportProfile{}
+	for _, profile := range s.importProfiles {
+		if sc.owns(profile.LedgerID) {
+			profiles = append(profiles, profile)
+		}
+	}
+
+	return profiles, nil
+}
+
+func (s *JSONStorage) GetImportProfileByID(ctx context.Context, id int) (*models.ImportProfile, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sc, err := scopeOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, profile := range s.importProfiles {
+		if profile.ID == id && sc.owns(profile.LedgerID) {
+			return &profile, nil
+		}
+	}
+
+	return nil, notFound("import profile not found")
+}
+
+func (s *JSONStorage) CreateImportProfile(ctx context.Context, profile *models.ImportProfile) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sc, err := scopeOf(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := profile.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	sc.assign(&profile.LedgerID, &profile.OwnerID)
+	for _, existing := range s.importProfiles {
+		if existing.LedgerID == profile.LedgerID && existing.Name == profile.Name {
+			return conflict("import profile with this name already exists")
+		}
+	}
+
+	profile.ID = s.nextID["import_profile"]
+	profile.Version = 1
+	s.nextID["import_profile"]++
+
+	if profile.CreatedAt.IsZero() {
+		profile.CreatedAt = time.Now()
+	}
+
+	s.importProfiles = append(s.importProfiles, *profile)
+
+	return s.save()
+}
+
+func (s *JSONStorage) UpdateImportProfile(ctx context.Context, profile *models.ImportProfile) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sc, err := scopeOf(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := profile.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	for i, existing := range s.importProfiles {
+		if existing.ID == profile.ID && sc.owns(existing.LedgerID) {
+			if err := checkVersion(profile.Version, existing.Version); err != nil {
+				return err
+			}
+			profile.LedgerID = existing.LedgerID
+			profile.OwnerID = existing.OwnerID
+			for j, other := range s.importProfiles {
+				if i != j && other.LedgerID == existing.LedgerID && other.Name == profile.Name {
+					return conflict("import profile with this name already exists")
+				}
+			}
+			profile.CreatedAt = existing.CreatedAt
+			profile.Version = existing.Version + 1
+			s.importProfiles[i] = *profile
+			return s.save()
+		}
+	}
+
+	return notFound("import profile not found")
+}
+
+func (s *JSONStorage) DeleteImportProfile(ctx context.Context, id, expectedVersion int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sc, err := scopeOf(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i, profile := range s.importProfiles {
+		if profile.ID == id && sc.owns(profile.LedgerID) {
+			if err := checkVersion(expectedVersion, profile.Version); err != nil {
+				return err
+			}
+			s.importProfiles = append(s.importProfiles[:i], s.importProfiles[i+1:]...)
+			return s.save()
+		}
+	}
+
+	return notFound("import profile not found")
+}
+
+// Snapshot возвращает копию данных книги для резервного копирования
+func (s *JSONStorage) Snapshot(ctx context.Context) (*models.Backup, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
+	sc, err := scopeOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	backup := &models.Backup{
+		Users:         []models.UserRecord{},
+		Ledgers:       []models.Ledger{},
+		LedgerInvites: []models.LedgerInvite{},
+		RefreshTokens: []models.RefreshToken{},
+		Categories:    []models.Category{},
+		Transactions:  []models.Transaction{},
+		Budgets:       []models.Budget{},
+		Settings: models.BackupSettings{
+			ImportProfiles:      []models.ImportProfile{},
+			DismissedDuplicates: [][2]int{},
+		},
+	}
+
+	// учетные записи и сессии попадают только в полную копию, копия книги - это книга и ее приглашения
+	if sc.all {
+		backup.Users = models.UserRecords(s.users)
+		backup.RefreshTokens = append(backup.RefreshTokens, s.refreshTokens...)
+	}
+	for _, ledger := range s.ledgers {
+		if sc.owns(ledger.ID) {
+			backup.Ledgers = append(backup.Ledgers, ledger.Clone())
+		}
+	}
+	for _, invite := range s.ledgerInvites {
+		if sc.owns(invite.LedgerID) {
+			backup.LedgerInvites = append(backup.LedgerInvites, invite)
+		}
+	}
+
+	for _, cat := range s.categories {
+		if sc.owns(cat.LedgerID) {
+			backup.Categories = append(backup.Categories, cat)
+		}
+	}
+	owned := make(map[int]bool)
+	for _, tx := range s.transactions {
+		if sc.owns(tx.LedgerID) {
+			backup.Transactions = append(backup.Transactions, tx)
+			owned[tx.ID] = true
+		}
+	}
+	for _, budget := range s.budgets {
+		if sc.owns(budget.LedgerID) {
+			backup.Budgets = append(backup.Budgets, budget)
+		}
+	}
+	for _, profile := range s.importProfiles {
+		if sc.owns(profile.LedgerID) {
+			backup.Settings.ImportProfiles = append(backup.Settings.ImportProfiles, profile)
+		}
+	}
+
+	for pair := range s.dismissed {
+		if owned[pair[0]] && owned[pair[1]] {
+			backup.Settings.DismissedDuplicates = append(backup.Settings.DismissedDuplicates, pair)
+		}
+	}
+	sort.Slice(backup.Settings.DismissedDuplicates, func(i, j int) bool {
+		a, b := backup.Settings.DismissedDuplicates[i], backup.Settings.DismissedDuplicates[j]
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		return a[1] < b[1]
+	})
+
+	return backup, nil
+}
+
+// Restore заменяет данные копией или вливает ее в текущие; файл пишется один раз.
+// Для книги заменяются только ее записи, а из копии берутся только записи этой книги и ничьи:
+// снимок по расписанию содержит данные всех книг. Ничьи записи (копия первой версии схемы)
+// получают автором пользователя из контекста. Учетные записи, книги, приглашения и сессии
+// восстанавливает только служебный вызов, и только если в копии есть пользователи.
+func (s *JSONStorage) Restore(ctx context.Context, backup *models.Backup, mode string) (*models.RestoreResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sc, err := scopeOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// записи без книги после служебного восстановления никому не видны
+	if sc.all && hasUnassigned(backup) {
+		return nil, invalidField("ledger_id", "backup has records without a ledger (schema version 1): restore it into a ledger")
+	}
+
+	result := &models.RestoreResult{Mode: mode}
+
+	switch {
+	case mode == models.RestoreModeReplace && !sc.all:
+		s.removeLedgerData(sc.ledgerID)
+		s.mergeBackup(sc, accountIDs{}, backup, result)
+		s.resetNextID()
+	case mode == models.RestoreModeReplace:
+		if len(backup.Users) > 0 {
+			s.users = models.UsersFromRecords(backup.Users)
+			s.ledgers = make([]models.Ledger, 0, len(backup.Ledgers))
+			for _, ledger := range backup.Ledgers {
+				s.ledgers = append(s.ledgers, ledger.Clone())
+			}
+			s.ledgerInvites = append([]models.LedgerInvite{}, backup.LedgerInvites...)
+			s.refreshTokens = append([]models.RefreshToken{}, backup.RefreshTokens...)
+			// Сессии выданы другому набору учетных записей: ID в копии могут принадлежать другим людям.
+			// Refresh-токены отзываем, а новая эпоха делает недействительными выданные access-токены.
+			now := time.Now()
+			for i := range s.refreshTokens {
+				if s.refreshTokens[i].RevokedAt == nil {
+					s.refreshTokens[i].RevokedAt = &now
+				}
+			}
+			s.sessionEpoch++
+
+			result.Users = len(s.users)
+			result.Ledgers = len(s.ledgers)
+		}
+		s.categories = append([]models.Category{}, backup.Categories...)
+		s.transactions = append([]models.Transaction{}, backup.Transactions...)
+		s.budgets = append([]models.Budget{}, backup.Budgets...)
+		s.importProfiles = append([]models.ImportProfile{}, backup.Settings.ImportProfiles...)
+		s.dismissed = make(map[[2]int]bool, len(backup.Settings.DismissedDuplicates))
+		for _, pair := range backup.Settings.DismissedDuplicates {
+			s.dismissed[pairKey(pair[0], pair[1])] = true
+		}
+		s.aggregates = buildAggregates(s.transactions)
+		s.normalizeVersions()
+
+		result.Categories = len(s.categories)
+		result.Transactions = len(s.transactions)
+		result.Budgets = len(s.budgets)
+
+		s.resetNextID()
+	case mode == models.RestoreModeMerge && !sc.all:
+		s.mergeBackup(sc, accountIDs{}, backup, result)
+	case mode == models.RestoreModeMerge:
+		s.mergeBackup(sc, s.mergeAccounts(backup, result), backup, result)
+	default:
+		return nil, invalidField("mode", fmt.Sprintf("unknown restore mode '%s'", mode))
+	}
+
+	s.notify(s.stateEvents()...)
+
+	return result, s.save()
+}
+
+// removeLedgerData удаляет все записи книги - перед заменой копией или при удалении книги
+func (s *JSONStorage) removeLedgerData(ledgerID int) {
+	categories := s.categories[:0]
+	for _, cat := range s.categories {
+		if cat.LedgerID != ledgerID {
+			categories = append(categories, cat)
+		}
+	}
+	s.categories = categories
+
+	removed := make(map[int]bool)
+	transactions := s.transactions[:0]
 	for _, tx := range s.transactions {
-		if tx.Date.Before(startDate) || tx.Date.After(endDate) {
+		if tx.LedgerID == ledgerID {
+			s.aggregates.remove(tx)
+			removed[tx.ID] = true
 			continue
 		}
+		transactions = append(transactions, tx)
+	}
+	s.transactions = transactions
+
+	for pair := range s.dismissed {
+		if removed[pair[0]] || removed[pair[1]] {
+			delete(s.dismissed, pair)
+		}
+	}
+
+	budgets := s.budgets[:0]
+	for _, budget := range s.budgets {
+		if budget.LedgerID != ledgerID {
+			budgets = append(budgets, budget)
+		}
+	}
+	s.budgets = budgets
+
+	profiles := s.importProfiles[:0]
+	for _, profile := range s.importProfiles {
+		if profile.LedgerID != ledgerID {
+			profiles = append(profiles, profile)
+		}
+	}
+	s.importProfiles = profiles
+}
+
+// hasUnassigned сообщает, есть ли в копии записи без книги
+func hasUnassigned(backup *models.Backup) bool {
+	for _, cat := range backup.Categories {
+		if cat.LedgerID == 0 {
+			return true
+		}
+	}
+	for _, tx := range backup.Transactions {
+		if tx.LedgerID == 0 {
+			return true
+		}
+	}
+	for _, budget := range backup.Budgets {
+		if budget.LedgerID == 0 {
+			return true
+		}
+	}
+	for _, profile := range backup.Settings.ImportProfiles {
+		if profile.LedgerID == 0 {
+			return true
+		}
+	}
+	return false
+}
 
+// restoreLedger решает, в какую книгу попадет запись из копии и кто станет ее автором. Служебный
+// вызов переводит книгу и автора по соответствию из mergeAccounts, в книгу из контекста попадают
+// ее собственные и ничьи записи, записи других книг пропускаются. Автора ничьей записи в этом
+// хранилище может не быть, поэтому им становится пользователь из контекста.
+func restoreLedger(sc scope, ids accountIDs, ledgerID, ownerID int) (int, int, bool) {
+	if sc.all {
+		if mapped, ok := ids.ledgers[ledgerID]; ok {
+			return mapped, ids.user(ownerID), true
+		}
+		return ledgerID, ids.user(ownerID), true
+	}
+	switch ledgerID {
+	case 0:
+		return sc.ledgerID, sc.userID, true
+	case sc.ledgerID:
+		return sc.ledgerID, ownerID, true
+	}
+	return 0, 0, false
+}
+
+// mergeBackup добавляет только отсутствующие записи, выдавая им новые ID.
+// Категории сопоставляются по имени и типу, транзакции - по внешнему ключу или полному совпадению полей.
+func (s *JSONStorage) mergeBackup(sc scope, ids accountIDs, backup *models.Backup, result *models.RestoreResult) {
+	categoryIDs := make(map[int]int, len(backup.Categories))
+	for _, cat := range backup.Categories {
+		ledger, owner, ok := restoreLedger(sc, ids, cat.LedgerID, cat.OwnerID)
+		if !ok {
+			continue
+		}
+		cat.OwnerID = owner
+		cat.LedgerID = ledger
+
+		found := false
+		for _, existing := range s.categories {
+			if existing.LedgerID == ledger && existing.Name == cat.Name && existing.Type == cat.Type {
+				categoryIDs[cat.ID] = existing.ID
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+
+		categoryIDs[cat.ID] = s.nextID["category"]
+		cat.ID = s.nextID["category"]
+		cat.Version = 1
+		s.nextID["category"]++
+		s.categories = append(s.categories, cat)
+		result.Categories++
+	}
+
+	signature := func(tx models.Transaction) string {
+		return fmt.Sprintf("%d|%s|%.2f|%s|%d|%s", tx.LedgerID, tx.Date.UTC().Format(time.RFC3339Nano), tx.Amount, tx.Type, tx.CategoryID, tx.Description)
+	}
+	externalKey := func(tx models.Transaction) string {
+		if key := tx.ExternalKey(); key != "" {
+			return fmt.Sprintf("%d|%s", tx.LedgerID, key)
+		}
+		return ""
+	}
+
+	existingKeys := make(map[string]bool)
+	existingSignatures := make(map[string]bool, len(s.transactions))
+	for _, tx := range s.transactions {
+		existingSignatures[signature(tx)] = true
+		if key := externalKey(tx); key != "" {
+			existingKeys[key] = true
+		}
+	}
+
+	transactionIDs := make(map[int]int, len(backup.Transactions))
+	for _, tx := range backup.Transactions {
+		ledger, owner, ok := restoreLedger(sc, ids, tx.LedgerID, tx.OwnerID)
+		if !ok {
+			continue
+		}
+		tx.OwnerID = owner
+		oldID := tx.ID
+		tx.LedgerID = ledger
+		tx.CategoryID = categoryIDs[tx.CategoryID]
+
+		if key := externalKey(tx); (key != "" && existingKeys[key]) || existingSignatures[signature(tx)] {
+			result.Skipped++
+			continue
+		}
+
+		tx.ID = s.nextID["transaction"]
+		tx.Version = 1
+		s.nextID["transaction"]++
+		s.transactions = append(s.transactions, tx)
+		s.aggregates.add(tx)
+		existingSignatures[signature(tx)] = true
+		if key := externalKey(tx); key != "" {
+			existingKeys[key] = true
+		}
+
+		transactionIDs[oldID] = tx.ID
+		result.Transactions++
+	}
+
+	for _, budget := range backup.Budgets {
+		ledger, owner, ok := restoreLedger(sc, ids, budget.LedgerID, budget.OwnerID)
+		if !ok {
+			continue
+		}
+		budget.OwnerID = owner
+		budget.LedgerID = ledger
+		budget.CategoryID = categoryIDs[budget.CategoryID]
+
+		duplicate := false
+		for _, existing := range s.budgets {
+			if existing.LedgerID == ledger &&
+				existing.CategoryID == budget.CategoryID &&
+				existing.Period == budget.Period &&
+				existing.Month.Year() == budget.Month.Year() &&
+				existing.Month.Month() == budget.Month.Month() {
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			result.Skipped++
+			continue
+		}
+
+		budget.ID = s.nextID["budget"]
+		budget.Version = 1
+		s.nextID["budget"]++
+		s.budgets = append(s.budgets, budget)
+		result.Budgets++
+	}
+
+	for _, profile := range backup.Settings.ImportProfiles {
+		ledger, owner, ok := restoreLedger(sc, ids, profile.LedgerID, profile.OwnerID)
+		if !ok {
+			continue
+		}
+		profile.OwnerID = owner
+		profile.LedgerID = ledger
+
+		duplicate := false
+		for _, existing := range s.importProfiles {
+			if existing.LedgerID == ledger && existing.Name == profile.Name {
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			continue
+		}
+
+		profile.ID = s.nextID["import_profile"]
+		profile.Version = 1
+		s.nextID["import_profile"]++
+		s.importProfiles = append(s.importProfiles, profile)
+	}
+
+	for _, pair := range backup.Settings.DismissedDuplicates {
+		first, okFirst := transactionIDs[pair[0]]
+		second, okSecond := transactionIDs[pair[1]]
+		if okFirst && okSecond {
+			s.dismissed[pairKey(first, second)] = true
+		}
+	}
+}
+
+func (s *JSONStorage) GetFinancialSummary(ctx context.Context, startDate, endDate time.Time) (*models.FinancialSummary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sc, err := scopeOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.financialSummary(sc, startDate, endDate), nil
+}
+
+// rangeBuckets - суммы за [startDate, endDate]: целые дни берутся из корзин, а граничный день,
+// покрытый частично, считается по транзакциям с точным сравнением моментов, как до появления корзин
+func (s *JSONStorage) rangeBuckets(sc scope, startDate, endDate time.Time) []AggregateBucket {
+	buckets, partial := s.aggregates.inRange(sc, startDate, endDate)
+	if len(partial) == 0 {
+		return buckets
+	}
+
+	exact := newAggregates()
+	for _, tx := range s.transactions {
+		if sc.owns(tx.LedgerID) && partial[aggregateDay(tx.Date)] && !tx.Date.Before(startDate) && !tx.Date.After(endDate) {
+			exact.add(tx)
+		}
+	}
+	return append(buckets, exact.list()...)
+}
+
+func (s *JSONStorage) financialSummary(sc scope, startDate, endDate time.Time) *models.FinancialSummary {
+	var totalIncome, totalExpenses float64
+
+	for _, bucket := range s.rangeBuckets(sc, startDate, endDate) {
 		// Используем switch вместо if-else (рекомендация staticcheck)
-		switch tx.Type {
+		switch bucket.Type {
 		case models.TransactionTypeIncome:
-			totalIncome += tx.Amount
+			totalIncome += bucket.Amount
 		case models.TransactionTypeExpense:
-			totalExpenses += tx.Amount
+			totalExpenses += bucket.Amount
 			// default: игнорируем неизвестные типы
 		}
 	}
@@ -489,24 +1507,34 @@ func (s *JSONStorage) GetFinancialSummary(startDate, endDate time.Time) (*models
 		Period:        period,
 		StartDate:     startDate,
 		EndDate:       endDate,
-	}, nil
+	}
 }
 
-func (s *JSONStorage) GetCategorySummary(startDate, endDate time.Time) ([]models.CategorySummary, error) {
+func (s *JSONStorage) GetCategorySummary(ctx context.Context, startDate, endDate time.Time) ([]models.CategorySummary, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	sc, err := scopeOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.categorySummary(sc, startDate, endDate, ""), nil
+}
+
+// categorySummary считает суммы по категориям; пустой txType означает все типы
+func (s *JSONStorage) categorySummary(sc scope, startDate, endDate time.Time, txType string) []models.CategorySummary {
 	categoryAmounts := make(map[int]float64)
 	categoryTypes := make(map[int]string)
 	categoryNames := make(map[int]string)
 
 	// Собираем суммы по категориям
-	for _, tx := range s.transactions {
-		if tx.Date.Before(startDate) || tx.Date.After(endDate) {
+	for _, bucket := range s.rangeBuckets(sc, startDate, endDate) {
+		if txType != "" && bucket.Type != txType {
 			continue
 		}
 
-		categoryAmounts[tx.CategoryID] += tx.Amount
+		categoryAmounts[bucket.CategoryID] += bucket.Amount
 	}
 
 	// Получаем имена и типы категорий
@@ -540,39 +1568,65 @@ func (s *JSONStorage) GetCategorySummary(startDate, endDate time.Time) ([]models
 		})
 	}
 
-	return summaries, nil
+	return summaries
 }
 
-func (s *JSONStorage) GetBudgetReport(budgetID int) (*models.BudgetReport, error) {
+func (s *JSONStorage) GetBudgetReport(ctx context.Context, budgetID int) (*models.BudgetReport, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	// Находим бюджет
-	var budget *models.Budget
-	for i := range s.budgets {
-		if s.budgets[i].ID == budgetID {
-			budget = &s.budgets[i]
-			break
-		}
+	sc, err := scopeOf(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	if budget == nil {
-		return nil, errors.New("budget not found")
+	// Находим бюджет
+	i := s.budgetIndex(sc, budgetID)
+	if i < 0 {
+		return nil, notFound("budget not found")
 	}
 
-	// Находим категорию бюджета
-	/* var categoryName string
-	for _, cat := range s.categories {
-		if cat.ID == budget.CategoryID {
-			categoryName = cat.Name
-			break
+	return s.budgetReport(s.budgets[i]), nil
+}
+
+func (s *JSONStorage) budgetIndex(sc scope, id int) int {
+	for i, budget := range s.budgets {
+		if budget.ID == id && sc.owns(budget.LedgerID) {
+			return i
 		}
-	} */
+	}
+	return -1
+}
 
+func (s *JSONStorage) budgetReport(budget models.Budget) *models.BudgetReport {
 	// Считаем потраченную сумму за период
 	var spentAmount float64
 
 	// Определяем период для фильтрации транзакций
+	startDate, endDate := budgetPeriod(budget)
+
+	for _, bucket := range s.rangeBuckets(scope{ledgerID: budget.LedgerID}, startDate, endDate) {
+		if bucket.CategoryID == budget.CategoryID {
+			spentAmount += bucket.Amount
+		}
+	}
+
+	remaining := budget.Amount - spentAmount
+	progress := 0.0
+	if budget.Amount > 0 {
+		progress = (spentAmount / budget.Amount) * 100
+	}
+
+	return &models.BudgetReport{
+		Budget:       budget,
+		SpentAmount:  spentAmount,
+		Remaining:    remaining,
+		Progress:     progress,
+		IsOverBudget: spentAmount > budget.Amount,
+	}
+}
+
+func budgetPeriod(budget models.Budget) (time.Time, time.Time) {
 	startDate := budget.Month
 	endDate := budget.Month
 
@@ -585,25 +1639,174 @@ func (s *JSONStorage) GetBudgetReport(budgetID int) (*models.BudgetReport, error
 		endDate = startDate.AddDate(1, 0, 0).Add(-time.Nanosecond)
 	}
 
-	for _, tx := range s.transactions {
-		if tx.CategoryID == budget.CategoryID &&
-			!tx.Date.Before(startDate) &&
-			!tx.Date.After(endDate) {
-			spentAmount += tx.Amount
+	return startDate, endDate
+}
+
+func (s *JSONStorage) GetPivot(ctx context.Context, query PivotQuery) (*models.PivotTable, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sc, err := scopeOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+
+	categoryNames := make(map[int]string, len(s.categories))
+	for _, cat := range s.categories {
+		if sc.owns(cat.LedgerID) {
+			categoryNames[cat.ID] = cat.Name
 		}
 	}
 
-	remaining := budget.Amount - spentAmount
-	progress := 0.0
-	if budget.Amount > 0 {
-		progress = (spentAmount / budget.Amount) * 100
+	transactions, err := s.filterTransactions(ctx, sc, query.Filters)
+	if err != nil {
+		return nil, err
 	}
 
-	return &models.BudgetReport{
-		Budget:       *budget,
-		SpentAmount:  spentAmount,
-		Remaining:    remaining,
-		Progress:     progress,
-		IsOverBudget: spentAmount > budget.Amount,
-	}, nil
+	return buildPivot(transactions, categoryNames, query), nil
+}
+
+const annualTopLimit = 5
+
+func (s *JSONStorage) GetAnnualReport(ctx context.Context, year int) (*models.AnnualReport, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sc, err := scopeOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	startDate := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	endDate := startDate.AddDate(1, 0, 0).Add(-time.Nanosecond)
+
+	summary := s.financialSummary(sc, startDate, endDate)
+	summary.Period = models.BudgetPeriodYearly
+
+	report := &models.AnnualReport{
+		Year:            year,
+		Summary:         *summary,
+		SavingsRate:     savingsRate(summary),
+		Months:          make([]models.MonthlySummary, 0, 12),
+		TopCategories:   []models.CategorySummary{},
+		TopPayees:       []models.PayeeSummary{},
+		BiggestExpenses: []models.Transaction{},
+		Budgets:         []models.BudgetReport{},
+	}
+
+	// Помесячная разбивка
+	for month := time.January; month <= time.December; month++ {
+		monthStart := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+		monthSummary := s.financialSummary(sc, monthStart, monthStart.AddDate(0, 1, 0).Add(-time.Nanosecond))
+
+		report.Months = append(report.Months, models.MonthlySummary{
+			Month:    monthStart.Format("2006-01"),
+			Income:   monthSummary.TotalIncome,
+			Expenses: monthSummary.TotalExpenses,
+			Balance:  monthSummary.Balance,
+		})
+	}
+
+	// Топ категорий расходов
+	categories := s.categorySummary(sc, startDate, endDate, models.TransactionTypeExpense)
+	sort.Slice(categories, func(i, j int) bool {
+		return categories[i].Amount > categories[j].Amount
+	})
+	if len(categories) > annualTopLimit {
+		categories = categories[:annualTopLimit]
+	}
+	report.TopCategories = append(report.TopCategories, categories...)
+
+	// Получатели и крупнейшие траты
+	payees := make(map[string]*models.PayeeSummary)
+	for i, tx := range s.transactions {
+		if err := canceled(ctx, i); err != nil {
+			return nil, err
+		}
+		if !sc.owns(tx.LedgerID) || tx.Type != models.TransactionTypeExpense || tx.Date.Before(startDate) || tx.Date.After(endDate) {
+			continue
+		}
+
+		report.BiggestExpenses = append(report.BiggestExpenses, tx)
+
+		payee := strings.TrimSpace(tx.Description)
+		if payee == "" {
+			continue
+		}
+		key := strings.ToLower(payee)
+		if _, exists := payees[key]; !exists {
+			payees[key] = &models.PayeeSummary{Payee: payee}
+		}
+		payees[key].Amount += tx.Amount
+		payees[key].Count++
+	}
+
+	for _, payee := range payees {
+		report.TopPayees = append(report.TopPayees, *payee)
+	}
+	sort.Slice(report.TopPayees, func(i, j int) bool {
+		if report.TopPayees[i].Amount != report.TopPayees[j].Amount {
+			return report.TopPayees[i].Amount > report.TopPayees[j].Amount
+		}
+		return report.TopPayees[i].Payee < report.TopPayees[j].Payee
+	})
+	if len(report.TopPayees) > annualTopLimit {
+		report.TopPayees = report.TopPayees[:annualTopLimit]
+	}
+
+	sort.Slice(report.BiggestExpenses, func(i, j int) bool {
+		return report.BiggestExpenses[i].Amount > report.BiggestExpenses[j].Amount
+	})
+	if len(report.BiggestExpenses) > annualTopLimit {
+		report.BiggestExpenses = report.BiggestExpenses[:annualTopLimit]
+	}
+
+	// Бюджеты, начинающиеся в этом году
+	for _, budget := range s.budgets {
+		if !sc.owns(budget.LedgerID) || budget.Month.Year() != year {
+			continue
+		}
+
+		budgetReport := s.budgetReport(budget)
+		if budgetReport.IsOverBudget {
+			report.BudgetsMissed++
+		} else {
+			report.BudgetsHit++
+		}
+		report.Budgets = append(report.Budgets, *budgetReport)
+	}
+
+	// Сравнение с прошлым годом
+	previous := s.financialSummary(sc, startDate.AddDate(-1, 0, 0), startDate.Add(-time.Nanosecond))
+	previous.Period = models.BudgetPeriodYearly
+	report.PreviousYear = &models.YearComparison{
+		Year:                  year - 1,
+		Summary:               *previous,
+		SavingsRate:           savingsRate(previous),
+		IncomeChange:          summary.TotalIncome - previous.TotalIncome,
+		ExpensesChange:        summary.TotalExpenses - previous.TotalExpenses,
+		BalanceChange:         summary.Balance - previous.Balance,
+		IncomeChangePercent:   percentChange(previous.TotalIncome, summary.TotalIncome),
+		ExpensesChangePercent: percentChange(previous.TotalExpenses, summary.TotalExpenses),
+	}
+
+	return report, nil
+}
+
+func savingsRate(summary *models.FinancialSummary) float64 {
+	if summary.TotalIncome <= 0 {
+		return 0
+	}
+	return (summary.Balance / summary.TotalIncome) * 100
+}
+
+func percentChange(previous, current float64) float64 {
+	if previous == 0 {
+		return 0
+	}
+	return ((current - previous) / previous) * 100
 }
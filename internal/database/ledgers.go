@@ -0,0 +1,439 @@
+package database
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/ChixXx1/expense-tracker/internal/models"
+)
+
+// addLedger выдает книге ID и добавляет ее без категорий; вызывать под блокировкой
+func (s *JSONStorage) addLedger(ledger models.Ledger) models.Ledger {
+	ledger.ID = s.nextID["ledger"]
+	ledger.Version = 1
+	s.nextID["ledger"]++
+
+	if ledger.CreatedAt.IsZero() {
+		ledger.CreatedAt = time.Now()
+	}
+
+	s.ledgers = append(s.ledgers, ledger.Clone())
+	return ledger
+}
+
+func (s *JSONStorage) ledgerIndex(id int) int {
+	for i, ledger := range s.ledgers {
+		if ledger.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// GetLedgers возвращает книги, в которых состоит пользователь
+func (s *JSONStorage) GetLedgers(ctx context.Context, userID int) ([]models.Ledger, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	ledgers := []models.Ledger{}
+	for _, ledger := range s.ledgers {
+		if ledger.Role(userID) != "" {
+			ledgers = append(ledgers, ledger.Clone())
+		}
+	}
+
+	return ledgers, nil
+}
+
+func (s *JSONStorage) GetLedgerByID(ctx context.Context, id int) (*models.Ledger, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if i := s.ledgerIndex(id); i >= 0 {
+		ledger := s.ledgers[i].Clone()
+		return &ledger, nil
+	}
+
+	return nil, notFound("ledger not found")
+}
+
+// CreateLedger создает книгу и заполняет ее категориями по умолчанию от имени первого владельца
+func (s *JSONStorage) CreateLedger(ctx context.Context, ledger *models.Ledger) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := ledger.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	// личная книга появляется только при регистрации
+	ledger.Personal = false
+	*ledger = s.addLedger(*ledger)
+
+	for _, member := range ledger.Members {
+		if member.Role == models.LedgerRoleOwner {
+			s.seedCategories(ledger.ID, member.UserID)
+			break
+		}
+	}
+
+	return s.save()
+}
+
+// UpdateLedger меняет имя книги. Состав участников не трогает: его меняют только приглашения,
+// UpdateLedgerMember и RemoveLedgerMember, иначе переименование с устаревшей копией затерло бы их
+func (s *JSONStorage) UpdateLedger(ctx context.Context, ledger *models.Ledger) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	i := s.ledgerIndex(ledger.ID)
+	if i < 0 {
+		return notFound("ledger not found")
+	}
+
+	existing := s.ledgers[i]
+	if err := checkVersion(ledger.Version, existing.Version); err != nil {
+		return err
+	}
+
+	ledger.Members = existing.Clone().Members
+	if err := ledger.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	ledger.Personal = existing.Personal
+	ledger.CreatedAt = existing.CreatedAt
+	ledger.Version = existing.Version + 1
+	s.ledgers[i] = ledger.Clone()
+
+	return s.save()
+}
+
+// UpdateLedgerMember меняет роль участника книги; последнего владельца понизить нельзя
+func (s *JSONStorage) UpdateLedgerMember(ctx context.Context, ledgerID, userID int, role string) (*models.Ledger, error) {
+	return s.changeMembers(ctx, ledgerID, userID, func(members []models.LedgerMember, i int) []models.LedgerMember {
+		members[i].Role = role
+		return members
+	})
+}
+
+// RemoveLedgerMember исключает участника из книги; последнего владельца исключить нельзя
+func (s *JSONStorage) RemoveLedgerMember(ctx context.Context, ledgerID, userID int) (*models.Ledger, error) {
+	return s.changeMembers(ctx, ledgerID, userID, func(members []models.LedgerMember, i int) []models.LedgerMember {
+		return append(members[:i], members[i+1:]...)
+	})
+}
+
+// changeMembers применяет change к копии участников книги, где i - позиция userID,
+// и сохраняет результат, если в книге остался владелец
+func (s *JSONStorage) changeMembers(ctx context.Context, ledgerID, userID int,
+	change func(members []models.LedgerMember, i int) []models.LedgerMember) (*models.Ledger, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	j := s.ledgerIndex(ledgerID)
+	if j < 0 {
+		return nil, notFound("ledger not found")
+	}
+
+	ledger := s.ledgers[j].Clone()
+	i := slices.IndexFunc(ledger.Members, func(member models.LedgerMember) bool { return member.UserID == userID })
+	if i < 0 {
+		return nil, notFound("member not found")
+	}
+
+	ledger.Members = change(ledger.Members, i)
+	if ledger.Owner() == 0 {
+		return nil, conflict("ledger must keep at least one owner")
+	}
+	if err := ledger.Validate(); err != nil {
+		return nil, invalid(err)
+	}
+
+	ledger.Version++
+	s.ledgers[j] = ledger
+
+	result := ledger.Clone()
+	return &result, s.save()
+}
+
+// DeleteLedger удаляет книгу вместе со всеми ее данными и приглашениями
+func (s *JSONStorage) DeleteLedger(ctx context.Context, id, expectedVersion int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	i := s.ledgerIndex(id)
+	if i < 0 {
+		return notFound("ledger not found")
+	}
+
+	if err := checkVersion(expectedVersion, s.ledgers[i].Version); err != nil {
+		return err
+	}
+	if s.ledgers[i].Personal {
+		return conflict("personal ledger cannot be deleted")
+	}
+
+	s.ledgers = append(s.ledgers[:i], s.ledgers[i+1:]...)
+	s.removeLedgerData(id)
+
+	invites := s.ledgerInvites[:0]
+	for _, invite := range s.ledgerInvites {
+		if invite.LedgerID != id {
+			invites = append(invites, invite)
+		}
+	}
+	s.ledgerInvites = invites
+
+	s.notify(s.stateEvents()...)
+
+	return s.save()
+}
+
+// CreateLedgerInvite сохраняет приглашение и заодно выбрасывает просроченные
+func (s *JSONStorage) CreateLedgerInvite(ctx context.Context, invite *models.LedgerInvite) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if s.ledgerIndex(invite.LedgerID) < 0 {
+		return notFound("ledger not found")
+	}
+	if !models.ValidLedgerRole(invite.Role) {
+		return invalidField("role", "role must be 'owner', 'editor' or 'viewer'")
+	}
+
+	now := time.Now()
+	invites := s.ledgerInvites[:0]
+	for _, existing := range s.ledgerInvites {
+		if existing.Code == invite.Code {
+			return conflict("invite code already exists")
+		}
+		if now.Before(existing.ExpiresAt) {
+			invites = append(invites, existing)
+		}
+	}
+
+	if invite.CreatedAt.IsZero() {
+		invite.CreatedAt = now
+	}
+	s.ledgerInvites = append(invites, *invite)
+
+	return s.save()
+}
+
+// AcceptLedgerInvite добавляет пользователя в книгу с ролью из приглашения; код одноразовый
+func (s *JSONStorage) AcceptLedgerInvite(ctx context.Context, code string, userID int) (*models.Ledger, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for i, invite := range s.ledgerInvites {
+		if invite.Code != code || !now.Before(invite.ExpiresAt) {
+			continue
+		}
+
+		j := s.ledgerIndex(invite.LedgerID)
+		if j < 0 {
+			break
+		}
+		if s.ledgers[j].Role(userID) != "" {
+			return nil, conflict("user is already a member of this ledger")
+		}
+
+		ledger := s.ledgers[j].Clone()
+		ledger.Members = append(ledger.Members, models.LedgerMember{UserID: userID, Role: invite.Role, JoinedAt: now})
+		ledger.Version++
+		s.ledgers[j] = ledger
+		s.ledgerInvites = append(s.ledgerInvites[:i], s.ledgerInvites[i+1:]...)
+
+		result := ledger.Clone()
+		return &result, s.save()
+	}
+
+	return nil, notFound("invite not found or expired")
+}
+
+// migrateLedgers переносит данные, созданные до появления книг: каждый пользователь получает
+// личную книгу, и его записи без книги попадают в нее. Вызывать под блокировкой; сообщает, было ли что переносить.
+func (s *JSONStorage) migrateLedgers() bool {
+	personal := make(map[int]int)
+	changed := false
+	for _, ledger := range s.ledgers {
+		if !ledger.Personal {
+			continue
+		}
+		for _, member := range ledger.Members {
+			if member.Role == models.LedgerRoleOwner {
+				personal[member.UserID] = ledger.ID
+			}
+		}
+	}
+
+	for _, user := range s.users {
+		if _, ok := personal[user.ID]; ok {
+			continue
+		}
+		ledger := s.addLedger(models.Ledger{
+			Name:     models.PersonalLedgerName,
+			Personal: true,
+			Members:  []models.LedgerMember{{UserID: user.ID, Role: models.LedgerRoleOwner, JoinedAt: user.CreatedAt}},
+		})
+		personal[user.ID] = ledger.ID
+		changed = true
+	}
+
+	migrated := false
+	for i := range s.categories {
+		if s.categories[i].LedgerID == 0 && personal[s.categories[i].OwnerID] != 0 {
+			s.categories[i].LedgerID = personal[s.categories[i].OwnerID]
+			changed = true
+		}
+	}
+	for i := range s.transactions {
+		if s.transactions[i].LedgerID == 0 && personal[s.transactions[i].OwnerID] != 0 {
+			s.transactions[i].LedgerID = personal[s.transactions[i].OwnerID]
+			migrated = true
+		}
+	}
+	for i := range s.budgets {
+		if s.budgets[i].LedgerID == 0 && personal[s.budgets[i].OwnerID] != 0 {
+			s.budgets[i].LedgerID = personal[s.budgets[i].OwnerID]
+			changed = true
+		}
+	}
+	for i := range s.importProfiles {
+		if s.importProfiles[i].LedgerID == 0 && personal[s.importProfiles[i].OwnerID] != 0 {
+			s.importProfiles[i].LedgerID = personal[s.importProfiles[i].OwnerID]
+			changed = true
+		}
+	}
+
+	// агрегаты раньше группировались по владельцу
+	if migrated {
+		s.aggregates = buildAggregates(s.transactions)
+	}
+
+	return changed || migrated
+}
+
+// accountIDs - во что превратились ID пользователей и книг копии после mergeAccounts;
+// пустое соответствие оставляет ID как есть
+type accountIDs struct {
+	users   map[int]int
+	ledgers map[int]int
+}
+
+func (ids accountIDs) user(id int) int {
+	if mapped, ok := ids.users[id]; ok {
+		return mapped
+	}
+	return id
+}
+
+// mergeAccounts добавляет пользователей и книги из копии, которых нет в хранилище. Пользователи
+// сопоставляются по почте, личные книги - по владельцу, общие - по имени и времени создания.
+// Приглашения и сессии из копии не вливаются: они действуют только там, где были выданы.
+func (s *JSONStorage) mergeAccounts(backup *models.Backup, result *models.RestoreResult) accountIDs {
+	ids := accountIDs{
+		users:   make(map[int]int, len(backup.Users)),
+		ledgers: make(map[int]int, len(backup.Ledgers)),
+	}
+
+	for _, record := range backup.Users {
+		user := record.User()
+		found := false
+		for _, existing := range s.users {
+			if existing.Email == user.Email {
+				ids.users[user.ID] = existing.ID
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+
+		ids.users[user.ID] = s.nextID["user"]
+		user.ID = s.nextID["user"]
+		user.Version = 1
+		s.nextID["user"]++
+		s.users = append(s.users, user)
+		result.Users++
+	}
+
+	for _, ledger := range backup.Ledgers {
+		oldID := ledger.ID
+		members := make([]models.LedgerMember, 0, len(ledger.Members))
+		for _, member := range ledger.Members {
+			if id, ok := ids.users[member.UserID]; ok {
+				member.UserID = id
+				members = append(members, member)
+			}
+		}
+		ledger.Members = members
+
+		if existing := s.matchLedger(ledger); existing != 0 {
+			ids.ledgers[oldID] = existing
+			continue
+		}
+
+		ids.ledgers[oldID] = s.addLedger(ledger).ID
+		result.Ledgers++
+	}
+
+	return ids
+}
+
+// matchLedger ищет в хранилище книгу, соответствующую книге из копии; 0 - такой нет
+func (s *JSONStorage) matchLedger(ledger models.Ledger) int {
+	for _, existing := range s.ledgers {
+		if existing.Personal != ledger.Personal {
+			continue
+		}
+		if ledger.Personal {
+			for _, member := range ledger.Members {
+				if member.Role == models.LedgerRoleOwner && existing.Role(member.UserID) == models.LedgerRoleOwner {
+					return existing.ID
+				}
+			}
+			continue
+		}
+		if existing.Name == ledger.Name && existing.CreatedAt.Equal(ledger.CreatedAt) {
+			return existing.ID
+		}
+	}
+	return 0
+}
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ChixXx1/expense-tracker/internal/models"
+)
+
+// Переименование по устаревшей копии книги не отменяет вступление, случившееся после ее чтения
+func TestUpdateLedgerKeepsMembers(t *testing.T) {
+	s := NewJSONStorage(filepath.Join(t.TempDir(), "data.json"))
+	ctx := context.Background()
+
+	ledger := &models.Ledger{Name: "Семья", Members: []models.LedgerMember{{UserID: 1, Role: models.LedgerRoleOwner}}}
+	if err := s.CreateLedger(ctx, ledger); err != nil {
+		t.Fatal(err)
+	}
+	stale, err := s.GetLedgerByID(ctx, ledger.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invite := &models.LedgerInvite{Code: "code", LedgerID: ledger.ID, Role: models.LedgerRoleViewer, ExpiresAt: time.Now().Add(time.Hour)}
+	if err := s.CreateLedgerInvite(ctx, invite); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AcceptLedgerInvite(ctx, invite.Code, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	stale.Name = "Семейный бюджет"
+	stale.Version = 0
+	stale.Members = nil
+	if err := s.UpdateLedger(ctx, stale); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.GetLedgerByID(ctx, ledger.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != stale.Name || got.Role(1) != models.LedgerRoleOwner || got.Role(2) != models.LedgerRoleViewer {
+		t.Errorf("ledger %+v, want renamed with both members", got)
+	}
+}
+
+func TestLedgerMembersKeepOwner(t *testing.T) {
+	s := NewJSONStorage(filepath.Join(t.TempDir(), "data.json"))
+	ctx := context.Background()
+
+	ledger := &models.Ledger{Name: "Семья", Members: []models.LedgerMember{
+		{UserID: 1, Role: models.LedgerRoleOwner},
+		{UserID: 2, Role: models.LedgerRoleEditor},
+	}}
+	if err := s.CreateLedger(ctx, ledger); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.UpdateLedgerMember(ctx, ledger.ID, 1, models.LedgerRoleViewer); !errors.Is(err, ErrConflict) {
+		t.Errorf("demote last owner: %v, want %v", err, ErrConflict)
+	}
+	if _, err := s.RemoveLedgerMember(ctx, ledger.ID, 1); !errors.Is(err, ErrConflict) {
+		t.Errorf("remove last owner: %v, want %v", err, ErrConflict)
+	}
+	if _, err := s.RemoveLedgerMember(ctx, ledger.ID, 3); !errors.Is(err, ErrNotFound) {
+		t.Errorf("remove stranger: %v, want %v", err, ErrNotFound)
+	}
+
+	updated, err := s.UpdateLedgerMember(ctx, ledger.ID, 2, models.LedgerRoleOwner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Version != ledger.Version+1 {
+		t.Errorf("version %d, want %d", updated.Version, ledger.Version+1)
+	}
+	updated, err = s.RemoveLedgerMember(ctx, ledger.ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updated.Members) != 1 || updated.Role(2) != models.LedgerRoleOwner {
+		t.Errorf("members %+v, want only user 2 as owner", updated.Members)
+	}
+}
@@ -0,0 +1,266 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ChixXx1/expense-tracker/internal/models"
+)
+
+const (
+	SortByDate      = "date"
+	SortByAmount    = "amount"
+	SortByCreatedAt = "created_at"
+)
+
+const (
+	SortAsc  = "asc"
+	SortDesc = "desc"
+)
+
+const (
+	cursorNext = "next"
+	cursorPrev = "prev"
+)
+
+// pageCursor - позиция в отсортированном списке. Кодируется в непрозрачную строку
+// и привязана к сортировке, с которой была выдана.
+type pageCursor struct {
+	SortBy    string `json:"s"`
+	SortOrder string `json:"o"`
+	Key       string `json:"k"`
+	ID        int    `json:"id"`
+	Direction string `json:"d"`
+}
+
+func (c pageCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(value string) (*pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, invalidField("cursor", "invalid cursor")
+	}
+
+	var cursor pageCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, invalidField("cursor", "invalid cursor")
+	}
+	if cursor.Direction != cursorNext && cursor.Direction != cursorPrev {
+		return nil, invalidField("cursor", "invalid cursor")
+	}
+
+	return &cursor, nil
+}
+
+// normalizeSort подставляет сортировку по умолчанию (сначала новые) и проверяет значения
+func normalizeSort(filters *TransactionFilters) error {
+	if filters.SortBy == "" {
+		filters.SortBy = SortByDate
+	}
+	if filters.SortOrder == "" {
+		filters.SortOrder = SortDesc
+	}
+
+	switch filters.SortBy {
+	case SortByDate, SortByAmount, SortByCreatedAt:
+	default:
+		return invalidField("sort", "sort must be 'date', 'amount' or 'created_at'")
+	}
+
+	if filters.SortOrder != SortAsc && filters.SortOrder != SortDesc {
+		return invalidField("order", "order must be 'asc' or 'desc'")
+	}
+
+	return nil
+}
+
+// sortValue - ключ сортировки в сравнимом виде: момент для дат, число для суммы. Вычисляется
+// один раз на транзакцию, а неиспользуемое поле остается нулевым и на сравнение не влияет.
+type sortValue struct {
+	time   time.Time
+	amount float64
+	id     int
+}
+
+func sortValueOf(tx models.Transaction, sortBy string) sortValue {
+	switch sortBy {
+	case SortByAmount:
+		return sortValue{amount: tx.Amount, id: tx.ID}
+	case SortByCreatedAt:
+		return sortValue{time: tx.CreatedAt, id: tx.ID}
+	default:
+		return sortValue{time: tx.Date, id: tx.ID}
+	}
+}
+
+// parseSortValue восстанавливает ключ из курсора; испорченный ключ - ошибка валидации
+func parseSortValue(sortBy, key string, id int) (sortValue, error) {
+	if sortBy == SortByAmount {
+		amount, err := strconv.ParseFloat(key, 64)
+		if err != nil {
+			return sortValue{}, invalidField("cursor", "invalid cursor")
+		}
+		return sortValue{amount: amount, id: id}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, key)
+	if err != nil {
+		return sortValue{}, invalidField("cursor", "invalid cursor")
+	}
+	return sortValue{time: t, id: id}, nil
+}
+
+// key - ключ для курсора
+func (v sortValue) key(sortBy string) string {
+	if sortBy == SortByAmount {
+		return strconv.FormatFloat(v.amount, 'g', -1, 64)
+	}
+	return v.time.UTC().Format(time.RFC3339Nano)
+}
+
+// compare сравнивает ключи по возрастанию; ID разрешает равенство ключей
+func (v sortValue) compare(o sortValue) int {
+	if result := v.time.Compare(o.time); result != 0 {
+		return result
+	}
+	switch {
+	case v.amount < o.amount:
+		return -1
+	case v.amount > o.amount:
+		return 1
+	case v.id < o.id:
+		return -1
+	case v.id > o.id:
+		return 1
+	}
+	return 0
+}
+
+// byValue сортирует транзакции вместе с их ключами
+type byValue struct {
+	transactions []models.Transaction
+	values       []sortValue
+	desc         bool
+}
+
+func (b byValue) Len() int { return len(b.transactions) }
+
+func (b byValue) Less(i, j int) bool {
+	if b.desc {
+		return b.values[i].compare(b.values[j]) > 0
+	}
+	return b.values[i].compare(b.values[j]) < 0
+}
+
+func (b byValue) Swap(i, j int) {
+	b.transactions[i], b.transactions[j] = b.transactions[j], b.transactions[i]
+	b.values[i], b.values[j] = b.values[j], b.values[i]
+}
+
+// sortTransactions сортирует транзакции на месте в порядке filters и возвращает их ключи;
+// сортировка уже проверена normalizeSort
+func sortTransactions(transactions []models.Transaction, filters TransactionFilters) []sortValue {
+	values := make([]sortValue, len(transactions))
+	for i, tx := range transactions {
+		values[i] = sortValueOf(tx, filters.SortBy)
+	}
+	sort.Sort(byValue{transactions: transactions, values: values, desc: filters.SortOrder == SortDesc})
+	return values
+}
+
+// paginate сортирует отфильтрованные транзакции и вырезает страницу по курсору или offset
+func paginate(transactions []models.Transaction, filters TransactionFilters) (*models.TransactionPage, error) {
+	if err := normalizeSort(&filters); err != nil {
+		return nil, err
+	}
+
+	page := &models.TransactionPage{
+		Total:        len(transactions),
+		Transactions: []models.Transaction{},
+	}
+	for _, tx := range transactions {
+		page.Sum += tx.Amount
+		if tx.Type == models.TransactionTypeIncome {
+			page.Income += tx.Amount
+		} else {
+			page.Expense += tx.Amount
+		}
+	}
+
+	sorted := append([]models.Transaction{}, transactions...)
+	keys := sortTransactions(sorted, filters)
+
+	// position > 0, если элемент i идет после позиции курсора в выбранном порядке
+	position := func(i int, at sortValue) int {
+		cmp := keys[i].compare(at)
+		if filters.SortOrder == SortDesc {
+			return -cmp
+		}
+		return cmp
+	}
+
+	limit := len(sorted)
+	if filters.Limit != nil && *filters.Limit > 0 {
+		limit = *filters.Limit
+	}
+
+	start := 0
+	if filters.Offset != nil && *filters.Offset > 0 {
+		start = *filters.Offset
+	}
+
+	if filters.Cursor != "" {
+		cursor, err := decodeCursor(filters.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.SortBy != filters.SortBy || cursor.SortOrder != filters.SortOrder {
+			return nil, invalidField("cursor", "cursor was issued for a different sort order")
+		}
+		at, err := parseSortValue(cursor.SortBy, cursor.Key, cursor.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		if cursor.Direction == cursorNext {
+			start = sort.Search(len(sorted), func(i int) bool { return position(i, at) > 0 })
+		} else {
+			end := sort.Search(len(sorted), func(i int) bool { return position(i, at) >= 0 })
+			start = end - limit
+			if start < 0 {
+				start = 0
+			}
+		}
+	}
+
+	if start > len(sorted) {
+		start = len(sorted)
+	}
+	end := start + limit
+	if end > len(sorted) {
+		end = len(sorted)
+	}
+
+	page.Transactions = append(page.Transactions, sorted[start:end]...)
+
+	if end < len(sorted) && end > start {
+		page.NextCursor = pageCursor{
+			SortBy: filters.SortBy, SortOrder: filters.SortOrder,
+			Key: keys[end-1].key(filters.SortBy), ID: sorted[end-1].ID, Direction: cursorNext,
+		}.encode()
+	}
+	if start > 0 && start < len(sorted) {
+		page.PrevCursor = pageCursor{
+			SortBy: filters.SortBy, SortOrder: filters.SortOrder,
+			Key: keys[start].key(filters.SortBy), ID: sorted[start].ID, Direction: cursorPrev,
+		}.encode()
+	}
+
+	return page, nil
+}
@@ -0,0 +1,94 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ChixXx1/expense-tracker/internal/models"
+)
+
+func paginationFixture() []models.Transaction {
+	base := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	msk := time.FixedZone("MSK", 3*60*60)
+	var transactions []models.Transaction
+	for i := 1; i <= 9; i++ {
+		transactions = append(transactions, models.Transaction{
+			ID: i,
+			// одинаковые моменты в разных поясах и одинаковые суммы проверяют разрешение равенства по ID
+			Date:      base.Add(time.Duration(i%3) * time.Hour).In(msk),
+			CreatedAt: base.Add(time.Duration(10-i) * time.Minute),
+			Amount:    float64(i%4) * 2.5,
+			Type:      models.TransactionTypeExpense,
+		})
+	}
+	return transactions
+}
+
+// Проход по курсорам вперед и назад дает тот же порядок, что и полная сортировка
+func TestCursorWalkMatchesSort(t *testing.T) {
+	transactions := paginationFixture()
+
+	for _, sortBy := range []string{SortByDate, SortByAmount, SortByCreatedAt} {
+		for _, order := range []string{SortAsc, SortDesc} {
+			filters := TransactionFilters{SortBy: sortBy, SortOrder: order}
+			all, err := paginate(transactions, filters)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			limit := 2
+			filters.Limit = &limit
+			var forward []int
+			var cursors []string
+			for cursor := ""; ; {
+				filters.Cursor = cursor
+				page, err := paginate(transactions, filters)
+				if err != nil {
+					t.Fatal(err)
+				}
+				cursors = append(cursors, page.PrevCursor)
+				for _, tx := range page.Transactions {
+					forward = append(forward, tx.ID)
+				}
+				if cursor = page.NextCursor; cursor == "" {
+					break
+				}
+			}
+
+			for i, tx := range all.Transactions {
+				if i >= len(forward) || forward[i] != tx.ID {
+					t.Fatalf("%s %s: cursor walk %v differs from sort", sortBy, order, forward)
+				}
+			}
+			if len(forward) != len(all.Transactions) {
+				t.Fatalf("%s %s: cursor walk returned %d of %d", sortBy, order, len(forward), len(all.Transactions))
+			}
+
+			// prev-курсор последней страницы возвращает предыдущую
+			filters.Cursor = cursors[len(cursors)-1]
+			page, err := paginate(transactions, filters)
+			if err != nil {
+				t.Fatal(err)
+			}
+			n := len(all.Transactions)
+			if last := n - 1 - (n-1)%limit; len(page.Transactions) != limit || page.Transactions[0].ID != all.Transactions[last-limit].ID {
+				t.Errorf("%s %s: prev page %+v", sortBy, order, page.Transactions)
+			}
+		}
+	}
+}
+
+// Курсор с испорченным ключом - ошибка валидации, а не молчаливое сравнение строк
+func TestMalformedCursorKey(t *testing.T) {
+	transactions := paginationFixture()
+
+	for _, sortBy := range []string{SortByDate, SortByAmount} {
+		cursor := pageCursor{SortBy: sortBy, SortOrder: SortDesc, Key: "not a key", ID: 1, Direction: cursorNext}.encode()
+		_, err := paginate(transactions, TransactionFilters{SortBy: sortBy, Cursor: cursor})
+		var validation *ValidationError
+		if !errors.As(err, &validation) || validation.Field != "cursor" {
+			t.Errorf("%s: cursor with a malformed key: %v, want a cursor validation error", sortBy, err)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ChixXx1/expense-tracker/internal/models"
+)
+
+type PivotQuery struct {
+	Rows     []string
+	Columns  []string
+	Measures []string
+	Filters  TransactionFilters
+}
+
+var pivotDimensions = []string{
+	models.PivotDimensionCategory,
+	models.PivotDimensionType,
+	models.PivotDimensionPaymentMethod,
+	models.PivotDimensionMonth,
+	models.PivotDimensionWeekday,
+	models.PivotDimensionAccount,
+}
+
+var pivotMeasures = []string{
+	models.PivotMeasureSum,
+	models.PivotMeasureCount,
+	models.PivotMeasureAvg,
+	models.PivotMeasureMin,
+	models.PivotMeasureMax,
+}
+
+func (q *PivotQuery) Validate() error {
+	if len(q.Rows) == 0 && len(q.Columns) == 0 {
+		return invalidField("rows", fmt.Sprintf("at least one dimension is required, use %s", strings.Join(pivotDimensions, ", ")))
+	}
+
+	seen := make(map[string]bool)
+	for _, dim := range append(append([]string{}, q.Rows...), q.Columns...) {
+		if !contains(pivotDimensions, dim) {
+			return invalidField("rows", fmt.Sprintf("unsupported dimension '%s', use %s", dim, strings.Join(pivotDimensions, ", ")))
+		}
+		if seen[dim] {
+			return invalidField("rows", fmt.Sprintf("dimension '%s' is used more than once", dim))
+		}
+		seen[dim] = true
+	}
+
+	if len(q.Measures) == 0 {
+		return invalidField("measures", fmt.Sprintf("at least one measure is required, use %s", strings.Join(pivotMeasures, ", ")))
+	}
+
+	for _, measure := range q.Measures {
+		if !contains(pivotMeasures, measure) {
+			return invalidField("measures", fmt.Sprintf("unsupported measure '%s', use %s", measure, strings.Join(pivotMeasures, ", ")))
+		}
+	}
+
+	return nil
+}
+
+type pivotCell struct {
+	sum   float64
+	count int
+	min   float64
+	max   float64
+}
+
+func (c *pivotCell) add(amount float64) {
+	if c.count == 0 || amount < c.min {
+		c.min = amount
+	}
+	if c.count == 0 || amount > c.max {
+		c.max = amount
+	}
+	c.sum += amount
+	c.count++
+}
+
+func (c *pivotCell) values(measures []string) map[string]float64 {
+	if c == nil || c.count == 0 {
+		return nil
+	}
+
+	values := make(map[string]float64, len(measures))
+	for _, measure := range measures {
+		switch measure {
+		case models.PivotMeasureSum:
+			values[measure] = c.sum
+		case models.PivotMeasureCount:
+			values[measure] = float64(c.count)
+		case models.PivotMeasureAvg:
+			values[measure] = c.sum / float64(c.count)
+		case models.PivotMeasureMin:
+			values[measure] = c.min
+		case models.PivotMeasureMax:
+			values[measure] = c.max
+		}
+	}
+
+	return values
+}
+
+// pivotKey хранит подписи значений измерений и ключ для сортировки
+type pivotKey struct {
+	labels []string
+	order  []string
+}
+
+func (k pivotKey) id() string {
+	return strings.Join(k.labels, "\x00")
+}
+
+func buildPivot(transactions []models.Transaction, categoryNames map[int]string, query PivotQuery) *models.PivotTable {
+	rowKeys := make(map[string]pivotKey)
+	columnKeys := make(map[string]pivotKey)
+	cells := make(map[string]map[string]*pivotCell)
+	rowTotals := make(map[string]*pivotCell)
+	total := &pivotCell{}
+
+	for _, tx := range transactions {
+		row := dimensionKey(tx, categoryNames, query.Rows)
+		column := dimensionKey(tx, categoryNames, query.Columns)
+		rowID, columnID := row.id(), column.id()
+
+		rowKeys[rowID] = row
+		columnKeys[columnID] = column
+
+		if cells[rowID] == nil {
+			cells[rowID] = make(map[string]*pivotCell)
+			rowTotals[rowID] = &pivotCell{}
+		}
+		if cells[rowID][columnID] == nil {
+			cells[rowID][columnID] = &pivotCell{}
+		}
+
+		cells[rowID][columnID].add(tx.Amount)
+		rowTotals[rowID].add(tx.Amount)
+		total.add(tx.Amount)
+	}
+
+	sortedRows := sortPivotKeys(rowKeys)
+	sortedColumns := sortPivotKeys(columnKeys)
+
+	table := &models.PivotTable{
+		Rows:       nonNil(query.Rows),
+		Columns:    nonNil(query.Columns),
+		Measures:   query.Measures,
+		ColumnKeys: make([][]string, 0, len(sortedColumns)),
+		Data:       make([]models.PivotRow, 0, len(sortedRows)),
+		Totals:     total.values(query.Measures),
+	}
+
+	for _, column := range sortedColumns {
+		table.ColumnKeys = append(table.ColumnKeys, column.labels)
+	}
+
+	for _, row := range sortedRows {
+		pivotRow := models.PivotRow{
+			Keys:   row.labels,
+			Cells:  make([]map[string]float64, 0, len(sortedColumns)),
+			Totals: rowTotals[row.id()].values(query.Measures),
+		}
+		for _, column := range sortedColumns {
+			pivotRow.Cells = append(pivotRow.Cells, cells[row.id()][column.id()].values(query.Measures))
+		}
+		table.Data = append(table.Data, pivotRow)
+	}
+
+	return table
+}
+
+func dimensionKey(tx models.Transaction, categoryNames map[int]string, dimensions []string) pivotKey {
+	key := pivotKey{
+		labels: make([]string, 0, len(dimensions)),
+		order:  make([]string, 0, len(dimensions)),
+	}
+
+	for _, dim := range dimensions {
+		var label, order string
+
+		switch dim {
+		case models.PivotDimensionCategory:
+			label = categoryNames[tx.CategoryID]
+			if label == "" {
+				label = fmt.Sprintf("#%d", tx.CategoryID)
+			}
+			order = strings.ToLower(label)
+		case models.PivotDimensionType:
+			label, order = tx.Type, tx.Type
+		case models.PivotDimensionPaymentMethod:
+			label, order = tx.PaymentMethod, tx.PaymentMethod
+		case models.PivotDimensionAccount:
+			label, order = tx.Account, tx.Account
+		case models.PivotDimensionMonth:
+			label = tx.Date.Format("2006-01")
+			order = label
+		case models.PivotDimensionWeekday:
+			label = strings.ToLower(tx.Date.Weekday().String())
+			// Неделя начинается с понедельника
+			order = fmt.Sprint((int(tx.Date.Weekday()) + 6) % 7)
+		}
+
+		key.labels = append(key.labels, label)
+		key.order = append(key.order, order)
+	}
+
+	return key
+}
+
+func sortPivotKeys(keys map[string]pivotKey) []pivotKey {
+	sorted := make([]pivotKey, 0, len(keys))
+	for _, key := range keys {
+		sorted = append(sorted, key)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		for k := range sorted[i].order {
+			if sorted[i].order[k] != sorted[j].order[k] {
+				return sorted[i].order[k] < sorted[j].order[k]
+			}
+		}
+		return false
+	})
+
+	return sorted
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
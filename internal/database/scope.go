@@ -0,0 +1,57 @@
+package database
+
+import (
+	"context"
+	"errors"
+)
+
+// Книга учета передается через контекст: каждый метод Storage, кроме учетных записей и книг,
+// видит и меняет только записи книги из ctx. Вызов без книги - ошибка, а не доступ ко всему,
+// поэтому забытый WithLedger не открывает чужие данные. Права участника проверяют обработчики.
+
+// errNoScope - программная ошибка: хранилище вызвано без WithLedger или AllLedgers
+var errNoScope = errors.New("storage called without a ledger in context")
+
+type scopeKey struct{}
+
+type scope struct {
+	ledgerID int
+	// userID становится автором (OwnerID) новых записей
+	userID int
+	all    bool
+}
+
+// WithLedger ограничивает вызовы хранилища одной книгой; userID - кто выполняет запрос
+func WithLedger(ctx context.Context, ledgerID, userID int) context.Context {
+	return context.WithValue(ctx, scopeKey{}, scope{ledgerID: ledgerID, userID: userID})
+}
+
+// AllLedgers снимает ограничение - для служебных вызовов: админ-утилиты, снимков по расписанию, индексов
+func AllLedgers(ctx context.Context) context.Context {
+	return context.WithValue(ctx, scopeKey{}, scope{all: true})
+}
+
+// scopeOf заодно проверяет отмену контекста, поэтому заменяет ctx.Err() в начале методов
+func scopeOf(ctx context.Context) (scope, error) {
+	if err := ctx.Err(); err != nil {
+		return scope{}, err
+	}
+
+	sc, ok := ctx.Value(scopeKey{}).(scope)
+	if !ok {
+		return scope{}, errNoScope
+	}
+	return sc, nil
+}
+
+func (sc scope) owns(ledgerID int) bool {
+	return sc.all || ledgerID == sc.ledgerID
+}
+
+// assign проставляет книгу и автора новой записи; служебные вызовы сохраняют переданные
+func (sc scope) assign(ledgerID, ownerID *int) {
+	if !sc.all {
+		*ledgerID = sc.ledgerID
+		*ownerID = sc.userID
+	}
+}
@@ -0,0 +1,197 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ChixXx1/expense-tracker/internal/models"
+)
+
+// scopedRecords - по записи каждого вида в одной книге
+type scopedRecords struct {
+	ctx         context.Context
+	category    models.Category
+	transaction models.Transaction
+	budget      models.Budget
+	profile     models.ImportProfile
+}
+
+var scopeDate = time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+
+func newScopedRecords(t *testing.T, s *JSONStorage, ledgerID, userID int, description string) scopedRecords {
+	t.Helper()
+
+	r := scopedRecords{ctx: WithLedger(context.Background(), ledgerID, userID)}
+
+	r.category = models.Category{Name: description, Type: models.TransactionTypeExpense}
+	if err := s.CreateCategory(r.ctx, &r.category); err != nil {
+		t.Fatal(err)
+	}
+	r.transaction = models.Transaction{Amount: 1000, Type: models.TransactionTypeExpense, CategoryID: r.category.ID,
+		Date: scopeDate, Description: description, PaymentMethod: models.PaymentMethodCard}
+	if err := s.CreateTransaction(r.ctx, &r.transaction); err != nil {
+		t.Fatal(err)
+	}
+	// копия с тем же описанием и суммой - пара дубликатов внутри книги
+	duplicate := r.transaction
+	duplicate.ID = 0
+	if err := s.CreateTransaction(r.ctx, &duplicate); err != nil {
+		t.Fatal(err)
+	}
+	r.budget = models.Budget{CategoryID: r.category.ID, Amount: 5000, Period: models.BudgetPeriodMonthly, Month: scopeDate}
+	if err := s.CreateBudget(r.ctx, &r.budget); err != nil {
+		t.Fatal(err)
+	}
+	r.profile = models.ImportProfile{Name: description, Delimiter: ";", DateColumn: "Дата", DateFormat: "DD.MM.YYYY",
+		AmountColumn: "Сумма", AmountConvention: models.AmountConventionSigned, DefaultCategoryID: r.category.ID}
+	if err := s.CreateImportProfile(r.ctx, &r.profile); err != nil {
+		t.Fatal(err)
+	}
+
+	if r.transaction.LedgerID != ledgerID || r.transaction.OwnerID != userID {
+		t.Fatalf("transaction assigned to ledger %d, owner %d; want %d, %d",
+			r.transaction.LedgerID, r.transaction.OwnerID, ledgerID, userID)
+	}
+
+	return r
+}
+
+// Записи другой книги недоступны ни на чтение, ни на изменение, ни на удаление
+func TestScopeHidesOtherLedgers(t *testing.T) {
+	s := NewJSONStorage(filepath.Join(t.TempDir(), "data.json"))
+
+	first := newScopedRecords(t, s, 1, 1, "Аренда")
+	// вторая книга того же пользователя: доступ определяет книга, а не автор записей
+	second := newScopedRecords(t, s, 2, 1, "Кофе")
+	ctx := second.ctx
+
+	category, transaction, budget, profile := first.category, first.transaction, first.budget, first.profile
+	category.Name = "Чужая"
+	transaction.CategoryID = second.category.ID
+	budget.CategoryID = second.category.ID
+	profile.DefaultCategoryID = second.category.ID
+
+	calls := map[string]error{
+		"GetCategoryByID":      errOf(s.GetCategoryByID(ctx, category.ID)),
+		"UpdateCategory":       s.UpdateCategory(ctx, &category),
+		"DeleteCategory":       s.DeleteCategory(ctx, category.ID, 0),
+		"GetTransactionByID":   errOf(s.GetTransactionByID(ctx, transaction.ID)),
+		"UpdateTransaction":    s.UpdateTransaction(ctx, &transaction),
+		"DeleteTransaction":    s.DeleteTransaction(ctx, transaction.ID, 0),
+		"GetBudgetByID":        errOf(s.GetBudgetByID(ctx, budget.ID)),
+		"UpdateBudget":         s.UpdateBudget(ctx, &budget),
+		"DeleteBudget":         s.DeleteBudget(ctx, budget.ID, 0),
+		"GetImportProfileByID": errOf(s.GetImportProfileByID(ctx, profile.ID)),
+		"UpdateImportProfile":  s.UpdateImportProfile(ctx, &profile),
+		"DeleteImportProfile":  s.DeleteImportProfile(ctx, profile.ID, 0),
+		"GetBudgetReport":      errOf(s.GetBudgetReport(ctx, budget.ID)),
+	}
+	for name, err := range calls {
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s on another ledger: %v, want %v", name, err, ErrNotFound)
+		}
+	}
+
+	// записи первой книги на месте и не изменились
+	stored, err := s.GetTransactionByID(first.ctx, first.transaction.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Version != first.transaction.Version || stored.CategoryID != first.category.ID {
+		t.Errorf("transaction changed from another ledger: %+v", stored)
+	}
+	if _, err := s.GetCategoryByID(WithLedger(context.Background(), 1, 2), first.category.ID); err != nil {
+		t.Errorf("another member of the ledger: %v", err)
+	}
+}
+
+// Списки, отчеты и дубликаты строятся только по записям своей книги
+func TestScopeFiltersListsAndReports(t *testing.T) {
+	s := NewJSONStorage(filepath.Join(t.TempDir(), "data.json"))
+
+	first := newScopedRecords(t, s, 1, 1, "Аренда")
+	second := newScopedRecords(t, s, 2, 2, "Кофе")
+	ctx := second.ctx
+
+	page, err := s.GetTransactions(ctx, TransactionFilters{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tr := range page.Transactions {
+		if tr.LedgerID != 2 {
+			t.Errorf("GetTransactions: transaction %d from ledger %d", tr.ID, tr.LedgerID)
+		}
+	}
+	if len(page.Transactions) != 2 {
+		t.Errorf("GetTransactions: %d transactions, want 2", len(page.Transactions))
+	}
+
+	categories, err := s.GetCategories(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	budgets, err := s.GetBudgets(ctx, BudgetFilters{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	profiles, err := s.GetImportProfiles(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(categories) != 1 || len(budgets) != 1 || len(profiles) != 1 {
+		t.Errorf("lists: %d categories, %d budgets, %d profiles; want 1 of each", len(categories), len(budgets), len(profiles))
+	}
+
+	pairs, err := s.GetDuplicatePairs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pairs) != 1 || pairs[0].First.LedgerID != 2 || pairs[0].Second.LedgerID != 2 {
+		t.Errorf("GetDuplicatePairs: %+v, want one pair from ledger 2", pairs)
+	}
+	matches, err := s.FindDuplicates(ctx, first.transaction)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 0 {
+		t.Errorf("FindDuplicates: matched transactions of another ledger: %+v", matches)
+	}
+
+	summary, err := s.GetFinancialSummary(ctx, scopeDate.AddDate(0, 0, -1), scopeDate.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.TotalExpenses != 2000 {
+		t.Errorf("GetFinancialSummary: expenses %.2f, want 2000", summary.TotalExpenses)
+	}
+
+	all, err := s.GetTransactions(AllLedgers(context.Background()), TransactionFilters{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all.Transactions) != 4 {
+		t.Errorf("AllLedgers: %d transactions, want 4", len(all.Transactions))
+	}
+}
+
+// Забытый WithLedger - ошибка, а не доступ ко всем книгам
+func TestScopeRequired(t *testing.T) {
+	s := NewJSONStorage(filepath.Join(t.TempDir(), "data.json"))
+	newScopedRecords(t, s, 1, 1, "Аренда")
+
+	if _, err := s.GetTransactions(context.Background(), TransactionFilters{}); !errors.Is(err, errNoScope) {
+		t.Errorf("GetTransactions without scope: %v, want %v", err, errNoScope)
+	}
+	category := models.Category{Name: "Без книги", Type: models.TransactionTypeExpense}
+	if err := s.CreateCategory(context.Background(), &category); !errors.Is(err, errNoScope) {
+		t.Errorf("CreateCategory without scope: %v, want %v", err, errNoScope)
+	}
+}
+
+// errOf отбрасывает результат, оставляя ошибку
+func errOf[T any](_ T, err error) error {
+	return err
+}
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"time"
 
 	"github.com/ChixXx1/expense-tracker/internal/models"
@@ -15,33 +16,99 @@ type BudgetFilters struct {
 type TransactionFilters struct {
 	StartDate     *time.Time
 	EndDate       *time.Time
-	CategoryID    *int
-	Type          *string
-	PaymentMethod *string
+	CategoryID    ValueFilter[int]
+	Type          ValueFilter[string]
+	PaymentMethod ValueFilter[string]
+	MinAmount     *float64
+	MaxAmount     *float64
+	// Search - слова, которые должны встретиться в описании (без учета регистра)
+	Search string
+	// CreatedAfter - только записи, добавленные позже этого момента
+	CreatedAfter *time.Time
+	// Uncategorized - только транзакции, чья категория была удалена
+	Uncategorized bool
 	Limit         *int
 	Offset        *int
+	// SortBy - date, amount или created_at; SortOrder - asc или desc. По умолчанию сначала новые
+	SortBy    string
+	SortOrder string
+	// Cursor - непрозрачный курсор из предыдущей страницы, заменяет Offset
+	Cursor string
 }
 
+// Storage - интерфейс хранилища.
+// Update* проверяют поле Version переданной записи, Delete* - expectedVersion:
+// при расхождении с текущей версией возвращается ErrPreconditionFailed, 0 отключает проверку.
 type Storage interface {
-	GetCategories() ([]models.Category, error)
-	GetCategoryByID(id int) (*models.Category, error)
-	CreateCategory(category *models.Category) error
-	UpdateCategory(category *models.Category) error
-	DeleteCategory(id int) error
-
-	GetTransactions(filters TransactionFilters) ([]models.Transaction, error)
-	GetTransactionByID(id int) (*models.Transaction, error)
-	CreateTransaction(transaction *models.Transaction) error
-	UpdateTransaction(transaction *models.Transaction) error
-	DeleteTransaction(id int) error
-
-	GetBudgets(filters BudgetFilters) ([]models.Budget, error)
-	GetBudgetByID(id int) (*models.Budget, error)
-	CreateBudget(budget *models.Budget) error
-	UpdateBudget(budget *models.Budget) error
-	DeleteBudget(id int) error
-
-	GetFinancialSummary(startDate, endDate time.Time) (*models.FinancialSummary, error)
-	GetCategorySummary(startDate, endDate time.Time) ([]models.CategorySummary, error)
-	GetBudgetReport(budgetID int) (*models.BudgetReport, error)
+	GetCategories(ctx context.Context) ([]models.Category, error)
+	GetCategoryByID(ctx context.Context, id int) (*models.Category, error)
+	CreateCategory(ctx context.Context, category *models.Category) error
+	UpdateCategory(ctx context.Context, category *models.Category) error
+	DeleteCategory(ctx context.Context, id, expectedVersion int) error
+
+	GetTransactions(ctx context.Context, filters TransactionFilters) (*models.TransactionPage, error)
+	// StreamTransactions - все подходящие под фильтры транзакции для выгрузки, без страниц
+	StreamTransactions(ctx context.Context, filters TransactionFilters) (*TransactionStream, error)
+	GetTransactionByID(ctx context.Context, id int) (*models.Transaction, error)
+	CreateTransaction(ctx context.Context, transaction *models.Transaction) error
+	CreateTransactions(ctx context.Context, transactions []models.Transaction) error
+	GetImportedKeys(ctx context.Context) (map[string]bool, error)
+	UpdateTransaction(ctx context.Context, transaction *models.Transaction) error
+	DeleteTransaction(ctx context.Context, id, expectedVersion int) error
+
+	FindDuplicates(ctx context.Context, transaction models.Transaction) ([]models.Transaction, error)
+	GetDuplicatePairs(ctx context.Context) ([]models.DuplicatePair, error)
+	DismissDuplicate(ctx context.Context, firstID, secondID int) error
+	MergeDuplicates(ctx context.Context, keepID, removeID int) (*models.Transaction, error)
+
+	GetBudgets(ctx context.Context, filters BudgetFilters) ([]models.Budget, error)
+	GetBudgetByID(ctx context.Context, id int) (*models.Budget, error)
+	CreateBudget(ctx context.Context, budget *models.Budget) error
+	UpdateBudget(ctx context.Context, budget *models.Budget) error
+	DeleteBudget(ctx context.Context, id, expectedVersion int) error
+
+	GetImportProfiles(ctx context.Context) ([]models.ImportProfile, error)
+	GetImportProfileByID(ctx context.Context, id int) (*models.ImportProfile, error)
+	CreateImportProfile(ctx context.Context, profile *models.ImportProfile) error
+	UpdateImportProfile(ctx context.Context, profile *models.ImportProfile) error
+	DeleteImportProfile(ctx context.Context, id, expectedVersion int) error
+
+	CreateUser(ctx context.Context, user *models.User) error
+	GetUserByID(ctx context.Context, id int) (*models.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
+	SetUserAdmin(ctx context.Context, userID int, admin bool) error
+
+	// Refresh-токены ищутся по sha256 от самого токена
+	SaveRefreshToken(ctx context.Context, token *models.RefreshToken) error
+	GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	RevokeRefreshToken(ctx context.Context, tokenHash string) error
+	RevokeUserRefreshTokens(ctx context.Context, userID int) error
+	SessionEpoch(ctx context.Context) (int, error)
+
+	// Книги и приглашения не ограничены книгой из ctx: членство проверяют обработчики
+	GetLedgers(ctx context.Context, userID int) ([]models.Ledger, error)
+	GetLedgerByID(ctx context.Context, id int) (*models.Ledger, error)
+	CreateLedger(ctx context.Context, ledger *models.Ledger) error
+	UpdateLedger(ctx context.Context, ledger *models.Ledger) error
+	UpdateLedgerMember(ctx context.Context, ledgerID, userID int, role string) (*models.Ledger, error)
+	RemoveLedgerMember(ctx context.Context, ledgerID, userID int) (*models.Ledger, error)
+	DeleteLedger(ctx context.Context, id, expectedVersion int) error
+	CreateLedgerInvite(ctx context.Context, invite *models.LedgerInvite) error
+	AcceptLedgerInvite(ctx context.Context, code string, userID int) (*models.Ledger, error)
+
+	Snapshot(ctx context.Context) (*models.Backup, error)
+	Restore(ctx context.Context, backup *models.Backup, mode string) (*models.RestoreResult, error)
+
+	GetFinancialSummary(ctx context.Context, startDate, endDate time.Time) (*models.FinancialSummary, error)
+	GetCategorySummary(ctx context.Context, startDate, endDate time.Time) ([]models.CategorySummary, error)
+	GetBudgetReport(ctx context.Context, budgetID int) (*models.BudgetReport, error)
+	GetAnnualReport(ctx context.Context, year int) (*models.AnnualReport, error)
+	GetPivot(ctx context.Context, query PivotQuery) (*models.PivotTable, error)
+
+	// Subscribe подписывает на изменения транзакций и категорий
+	Subscribe(ctx context.Context, listener Listener) error
+
+	// WithTx выполняет несколько операций атомарно: fn получает хранилище-транзакцию,
+	// ошибка из fn отменяет все изменения, сделанные через него
+	WithTx(ctx context.Context, fn func(tx Storage) error) error
 }
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"context"
+	"iter"
+	"slices"
+
+	"github.com/ChixXx1/expense-tracker/internal/models"
+)
+
+// TransactionStream - отфильтрованные и отсортированные транзакции, снятые под одной блокировкой чтения.
+// Читается уже без блокировки, поэтому запись в хранилище во время выгрузки не сдвигает и не дублирует строки.
+type TransactionStream struct {
+	transactions []models.Transaction
+}
+
+func (s *TransactionStream) Len() int {
+	return len(s.transactions)
+}
+
+// All перебирает транзакции по порядку; перебор можно повторять
+func (s *TransactionStream) All() iter.Seq[models.Transaction] {
+	return slices.Values(s.transactions)
+}
+
+// StreamTransactions фильтрует и сортирует транзакции один раз. Страниц у потока нет,
+// поэтому limit, offset и cursor отклоняются.
+func (s *JSONStorage) StreamTransactions(ctx context.Context, filters TransactionFilters) (*TransactionStream, error) {
+	if filters.Limit != nil || filters.Offset != nil || filters.Cursor != "" {
+		return nil, invalidField("cursor", "stream does not support limit, offset or cursor")
+	}
+	if err := normalizeSort(&filters); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sc, err := scopeOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// filterTransactions возвращает новый срез, его и сортируем
+	transactions, err := s.filterTransactions(ctx, sc, filters)
+	if err != nil {
+		return nil, err
+	}
+	sortTransactions(transactions, filters)
+
+	return &TransactionStream{transactions: transactions}, nil
+}
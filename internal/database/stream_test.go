@@ -0,0 +1,56 @@
+package database
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// Поток - один снимок: запись после его получения не сдвигает и не дублирует строки выгрузки
+func TestStreamIsSnapshot(t *testing.T) {
+	s := NewJSONStorage(filepath.Join(t.TempDir(), "data.json"))
+	records := newScopedRecords(t, s, 1, 1, "Аренда")
+	newScopedRecords(t, s, 2, 1, "Кофе")
+
+	stream, err := s.StreamTransactions(records.ctx, TransactionFilters{SortBy: SortByDate, SortOrder: SortAsc})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	later := records.transaction
+	later.ID = 0
+	later.Date = scopeDate.AddDate(0, 0, -1)
+	if err := s.CreateTransaction(records.ctx, &later); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteTransaction(records.ctx, records.transaction.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []int
+	for tx := range stream.All() {
+		if tx.LedgerID != 1 {
+			t.Errorf("transaction %d from ledger %d", tx.ID, tx.LedgerID)
+		}
+		ids = append(ids, tx.ID)
+	}
+	if len(ids) != 2 || ids[0] != records.transaction.ID || !slices.IsSorted(ids) {
+		t.Errorf("stream ids %v, want the two transactions present when it was taken, in ID order", ids)
+	}
+	if stream.Len() != len(ids) {
+		t.Errorf("Len %d, iterated %d", stream.Len(), len(ids))
+	}
+}
+
+func TestStreamRejectsPaging(t *testing.T) {
+	s := NewJSONStorage(filepath.Join(t.TempDir(), "data.json"))
+	records := newScopedRecords(t, s, 1, 1, "Аренда")
+
+	limit := 1
+	for _, filters := range []TransactionFilters{{Limit: &limit}, {Offset: &limit}, {Cursor: "x"}} {
+		if _, err := s.StreamTransactions(records.ctx, filters); !errors.Is(err, ErrValidation) {
+			t.Errorf("StreamTransactions(%+v): %v, want %v", filters, err, ErrValidation)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package database
+
+import (
+	"context"
+	"maps"
+
+	"github.com/ChixXx1/expense-tracker/internal/models"
+)
+
+// WithTx выполняет fn над копией данных под блокировкой хранилища.
+// Если fn вернула nil, изменения переносятся в хранилище одной записью файла и только тогда
+// рассылаются события; при ошибке копия отбрасывается. Внутри fn обращаться можно только к tx.
+func (s *JSONStorage) WithTx(ctx context.Context, fn func(tx Storage) error) error {
+	if s.inTx {
+		// вложенная транзакция становится частью внешней
+		return fn(s)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	tx := s.clone()
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.categories = tx.categories
+	s.transactions = tx.transactions
+	s.budgets = tx.budgets
+	s.importProfiles = tx.importProfiles
+	s.users = tx.users
+	s.refreshTokens = tx.refreshTokens
+	s.ledgers = tx.ledgers
+	s.ledgerInvites = tx.ledgerInvites
+	s.dismissed = tx.dismissed
+	s.aggregates = tx.aggregates
+	s.nextID = tx.nextID
+
+	s.notify(tx.pending...)
+
+	return s.save()
+}
+
+// clone копирует данные для транзакции; записи хранятся по значению, поэтому хватает копий срезов,
+// только у книг копируется и список участников
+func (s *JSONStorage) clone() *JSONStorage {
+	return &JSONStorage{
+		categories:     append([]models.Category{}, s.categories...),
+		transactions:   append([]models.Transaction{}, s.transactions...),
+		budgets:        append([]models.Budget{}, s.budgets...),
+		importProfiles: append([]models.ImportProfile{}, s.importProfiles...),
+		users:          append([]models.User{}, s.users...),
+		refreshTokens:  append([]models.RefreshToken{}, s.refreshTokens...),
+		ledgers:        cloneLedgers(s.ledgers),
+		ledgerInvites:  append([]models.LedgerInvite{}, s.ledgerInvites...),
+		dismissed:      maps.Clone(s.dismissed),
+		aggregates:     s.aggregates.clone(),
+		filepath:       s.filepath,
+		nextID:         maps.Clone(s.nextID),
+		sessionEpoch:   s.sessionEpoch,
+		inTx:           true,
+	}
+}
+
+func cloneLedgers(ledgers []models.Ledger) []models.Ledger {
+	cloned := make([]models.Ledger, 0, len(ledgers))
+	for _, ledger := range ledgers {
+		cloned = append(cloned, ledger.Clone())
+	}
+	return cloned
+}
@@ -0,0 +1,264 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/ChixXx1/expense-tracker/internal/models"
+)
+
+func (s *JSONStorage) CreateUser(ctx context.Context, user *models.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	user.Email = models.NormalizeEmail(user.Email)
+	if err := models.ValidateEmail(user.Email); err != nil {
+		return invalid(err)
+	}
+	if user.PasswordHash == "" {
+		return invalidField("password", "password is required")
+	}
+
+	for _, existing := range s.users {
+		if existing.Email == user.Email {
+			return conflict("user with this email already exists")
+		}
+	}
+
+	user.ID = s.nextID["user"]
+	user.Version = 1
+	s.nextID["user"]++
+
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = time.Now()
+	}
+
+	s.users = append(s.users, *user)
+
+	personal := s.addLedger(models.Ledger{
+		Name:     models.PersonalLedgerName,
+		Personal: true,
+		Members:  []models.LedgerMember{{UserID: user.ID, Role: models.LedgerRoleOwner, JoinedAt: user.CreatedAt}},
+	})
+	// данные, накопленные до появления учетных записей, достаются первому пользователю
+	if len(s.users) == 1 {
+		s.adoptUnowned(personal.ID, user.ID)
+	}
+	s.seedCategories(personal.ID, user.ID)
+
+	return s.save()
+}
+
+// adoptUnowned переносит в книгу все записи без книги и автора
+func (s *JSONStorage) adoptUnowned(ledgerID, ownerID int) {
+	adopted := false
+	for i := range s.categories {
+		if s.categories[i].LedgerID == 0 && s.categories[i].OwnerID == 0 {
+			s.categories[i].LedgerID, s.categories[i].OwnerID = ledgerID, ownerID
+			adopted = true
+		}
+	}
+	for i := range s.transactions {
+		if s.transactions[i].LedgerID == 0 && s.transactions[i].OwnerID == 0 {
+			s.transactions[i].LedgerID, s.transactions[i].OwnerID = ledgerID, ownerID
+			adopted = true
+		}
+	}
+	for i := range s.budgets {
+		if s.budgets[i].LedgerID == 0 && s.budgets[i].OwnerID == 0 {
+			s.budgets[i].LedgerID, s.budgets[i].OwnerID = ledgerID, ownerID
+		}
+	}
+	for i := range s.importProfiles {
+		if s.importProfiles[i].LedgerID == 0 && s.importProfiles[i].OwnerID == 0 {
+			s.importProfiles[i].LedgerID, s.importProfiles[i].OwnerID = ledgerID, ownerID
+		}
+	}
+
+	if adopted {
+		s.aggregates = buildAggregates(s.transactions)
+		s.notify(s.stateEvents()...)
+	}
+}
+
+// seedCategories создает в книге категории по умолчанию, которых в ней еще нет
+func (s *JSONStorage) seedCategories(ledgerID, ownerID int) {
+	for _, category := range models.GetDefaultCategories() {
+		exists := false
+		for _, cat := range s.categories {
+			if cat.LedgerID == ledgerID && cat.Name == category.Name && cat.Type == category.Type {
+				exists = true
+				break
+			}
+		}
+		if exists {
+			continue
+		}
+
+		category.ID = s.nextID["category"]
+		category.LedgerID = ledgerID
+		category.OwnerID = ownerID
+		category.Version = 1
+		s.nextID["category"]++
+		s.categories = append(s.categories, category)
+		s.notify(categorySaved(category))
+	}
+}
+
+func (s *JSONStorage) GetUserByID(ctx context.Context, id int) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, user := range s.users {
+		if user.ID == id {
+			return &user, nil
+		}
+	}
+
+	return nil, notFound("user not found")
+}
+
+func (s *JSONStorage) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	email = models.NormalizeEmail(email)
+	for _, user := range s.users {
+		if user.Email == email {
+			return &user, nil
+		}
+	}
+
+	return nil, notFound("user not found")
+}
+
+// SetUserAdmin выдает или снимает права администратора
+func (s *JSONStorage) SetUserAdmin(ctx context.Context, userID int, admin bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	for i := range s.users {
+		if s.users[i].ID == userID {
+			if s.users[i].IsAdmin == admin {
+				return nil
+			}
+			s.users[i].IsAdmin = admin
+			s.users[i].Version++
+			return s.save()
+		}
+	}
+
+	return notFound("user not found")
+}
+
+// SessionEpoch - текущая эпоха сессий; access-токен другой эпохи недействителен
+func (s *JSONStorage) SessionEpoch(ctx context.Context) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return s.sessionEpoch, nil
+}
+
+// SaveRefreshToken запоминает новый refresh-токен и заодно выбрасывает истекшие
+func (s *JSONStorage) SaveRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	tokens := s.refreshTokens[:0]
+	for _, existing := range s.refreshTokens {
+		if now.Before(existing.ExpiresAt) {
+			tokens = append(tokens, existing)
+		}
+	}
+
+	if token.CreatedAt.IsZero() {
+		token.CreatedAt = now
+	}
+	s.refreshTokens = append(tokens, *token)
+
+	return s.save()
+}
+
+func (s *JSONStorage) GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, token := range s.refreshTokens {
+		if token.TokenHash == tokenHash {
+			return &token, nil
+		}
+	}
+
+	return nil, notFound("refresh token not found")
+}
+
+// RevokeRefreshToken отзывает токен; повторный отзыв не ошибка
+func (s *JSONStorage) RevokeRefreshToken(ctx context.Context, tokenHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	for i := range s.refreshTokens {
+		if s.refreshTokens[i].TokenHash != tokenHash {
+			continue
+		}
+		if s.refreshTokens[i].RevokedAt == nil {
+			now := time.Now()
+			s.refreshTokens[i].RevokedAt = &now
+		}
+		return s.save()
+	}
+
+	return notFound("refresh token not found")
+}
+
+// RevokeUserRefreshTokens отзывает все refresh-токены пользователя - выход на всех устройствах
+func (s *JSONStorage) RevokeUserRefreshTokens(ctx context.Context, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for i := range s.refreshTokens {
+		if s.refreshTokens[i].UserID == userID && s.refreshTokens[i].RevokedAt == nil {
+			s.refreshTokens[i].RevokedAt = &now
+		}
+	}
+
+	return s.save()
+}
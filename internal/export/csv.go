@@ -0,0 +1,81 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+
+	"github.com/ChixXx1/expense-tracker/internal/models"
+)
+
+type csvWriter struct {
+	writer  *csv.Writer
+	options Options
+}
+
+func newCSVWriter(w io.Writer, options Options) (*csvWriter, error) {
+	writer := csv.NewWriter(w)
+	writer.Comma = []rune(options.Delimiter)[0]
+
+	if err := writer.Write(options.Columns); err != nil {
+		return nil, err
+	}
+
+	return &csvWriter{writer: writer, options: options}, nil
+}
+
+func (w *csvWriter) Write(tx models.Transaction) error {
+	cells := w.options.cells(tx)
+	record := make([]string, len(cells))
+	for i, c := range cells {
+		if c.isNumber {
+			record[i] = w.options.formatNumber(c.number)
+		} else {
+			record[i] = c.text
+		}
+	}
+	return w.writer.Write(record)
+}
+
+func (w *csvWriter) Close() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+type jsonlWriter struct {
+	writer  io.Writer
+	options Options
+}
+
+func newJSONLWriter(w io.Writer, options Options) *jsonlWriter {
+	return &jsonlWriter{writer: w, options: options}
+}
+
+// Write сохраняет порядок колонок, поэтому объект собирается вручную
+func (w *jsonlWriter) Write(tx models.Transaction) error {
+	line := []byte{'{'}
+	for i, c := range w.options.cells(tx) {
+		if i > 0 {
+			line = append(line, ',')
+		}
+		key, _ := json.Marshal(w.options.Columns[i])
+		line = append(line, key...)
+		line = append(line, ':')
+
+		var value []byte
+		if c.isNumber {
+			value, _ = json.Marshal(c.number)
+		} else {
+			value, _ = json.Marshal(c.text)
+		}
+		line = append(line, value...)
+	}
+	line = append(line, '}', '\n')
+
+	_, err := w.writer.Write(line)
+	return err
+}
+
+func (w *jsonlWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,179 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ChixXx1/expense-tracker/internal/importer"
+	"github.com/ChixXx1/expense-tracker/internal/models"
+)
+
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+	FormatXLSX  = "xlsx"
+)
+
+const (
+	ColumnID            = "id"
+	ColumnDate          = "date"
+	ColumnType          = "type"
+	ColumnAmount        = "amount"
+	ColumnCategoryID    = "category_id"
+	ColumnCategory      = "category"
+	ColumnDescription   = "description"
+	ColumnPaymentMethod = "payment_method"
+	ColumnAccount       = "account"
+	ColumnExternalID    = "external_id"
+	ColumnCreatedAt     = "created_at"
+	ColumnNotes         = "notes"
+)
+
+var AllColumns = []string{
+	ColumnID, ColumnDate, ColumnType, ColumnAmount, ColumnCategoryID, ColumnCategory,
+	ColumnDescription, ColumnPaymentMethod, ColumnAccount, ColumnExternalID, ColumnCreatedAt, ColumnNotes,
+}
+
+var DefaultColumns = []string{
+	ColumnID, ColumnDate, ColumnType, ColumnAmount, ColumnCategory,
+	ColumnDescription, ColumnPaymentMethod, ColumnAccount,
+}
+
+type Options struct {
+	Format           string
+	Columns          []string
+	DateFormat       string
+	DecimalSeparator string
+	Delimiter        string
+	CategoryNames    map[int]string
+}
+
+func (o *Options) Validate() error {
+	switch o.Format {
+	case FormatCSV, FormatJSONL, FormatXLSX:
+	default:
+		return fmt.Errorf("format must be '%s', '%s' or '%s'", FormatCSV, FormatJSONL, FormatXLSX)
+	}
+
+	if len(o.Columns) == 0 {
+		o.Columns = DefaultColumns
+	}
+	for _, column := range o.Columns {
+		if !contains(AllColumns, column) {
+			return fmt.Errorf("unknown column '%s', use %s", column, strings.Join(AllColumns, ", "))
+		}
+	}
+
+	if o.DecimalSeparator == "" {
+		o.DecimalSeparator = "."
+	}
+	if o.DecimalSeparator != "." && o.DecimalSeparator != "," {
+		return fmt.Errorf("decimal_separator must be '.' or ','")
+	}
+
+	if o.Delimiter == "" {
+		o.Delimiter = ","
+		// Запятая в числах - значит разделитель колонок точка с запятой
+		if o.DecimalSeparator == "," {
+			o.Delimiter = ";"
+		}
+	}
+	if len([]rune(o.Delimiter)) != 1 {
+		return fmt.Errorf("delimiter must be a single character")
+	}
+
+	if o.DateFormat == "" {
+		o.DateFormat = time.RFC3339
+	}
+
+	return nil
+}
+
+func (o *Options) ContentType() string {
+	switch o.Format {
+	case FormatJSONL:
+		return "application/x-ndjson"
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Writer пишет транзакции построчно, не собирая весь файл в памяти
+type Writer interface {
+	Write(tx models.Transaction) error
+	Close() error
+}
+
+func NewWriter(w io.Writer, options Options) (Writer, error) {
+	switch options.Format {
+	case FormatJSONL:
+		return newJSONLWriter(w, options), nil
+	case FormatXLSX:
+		return newXLSXWriter(w, options)
+	}
+	return newCSVWriter(w, options)
+}
+
+// cell - значение колонки: строка или число
+type cell struct {
+	text     string
+	number   float64
+	isNumber bool
+}
+
+func (o *Options) cells(tx models.Transaction) []cell {
+	layout := importer.DateLayout(o.DateFormat)
+	cells := make([]cell, 0, len(o.Columns))
+
+	for _, column := range o.Columns {
+		switch column {
+		case ColumnID:
+			cells = append(cells, cell{number: float64(tx.ID), isNumber: true})
+		case ColumnDate:
+			cells = append(cells, cell{text: tx.Date.Format(layout)})
+		case ColumnType:
+			cells = append(cells, cell{text: tx.Type})
+		case ColumnAmount:
+			cells = append(cells, cell{number: tx.Amount, isNumber: true})
+		case ColumnCategoryID:
+			cells = append(cells, cell{number: float64(tx.CategoryID), isNumber: true})
+		case ColumnCategory:
+			cells = append(cells, cell{text: o.CategoryNames[tx.CategoryID]})
+		case ColumnDescription:
+			cells = append(cells, cell{text: tx.Description})
+		case ColumnPaymentMethod:
+			cells = append(cells, cell{text: tx.PaymentMethod})
+		case ColumnAccount:
+			cells = append(cells, cell{text: tx.Account})
+		case ColumnExternalID:
+			cells = append(cells, cell{text: tx.ExternalID})
+		case ColumnCreatedAt:
+			cells = append(cells, cell{text: tx.CreatedAt.Format(layout)})
+		case ColumnNotes:
+			cells = append(cells, cell{text: tx.Notes})
+		}
+	}
+
+	return cells
+}
+
+func (o *Options) formatNumber(value float64) string {
+	formatted := strconv.FormatFloat(value, 'f', -1, 64)
+	if o.DecimalSeparator == "," {
+		formatted = strings.Replace(formatted, ".", ",", 1)
+	}
+	return formatted
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
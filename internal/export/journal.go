@@ -0,0 +1,178 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"iter"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/ChixXx1/expense-tracker/internal/models"
+)
+
+const (
+	JournalLedger    = "ledger"
+	JournalHledger   = "hledger"
+	JournalBeancount = "beancount"
+)
+
+type JournalOptions struct {
+	Format     string
+	Currency   string
+	Categories []models.Category
+}
+
+func (o *JournalOptions) Validate() error {
+	switch o.Format {
+	case JournalLedger, JournalHledger, JournalBeancount:
+	default:
+		return fmt.Errorf("format must be '%s', '%s' or '%s'", JournalLedger, JournalHledger, JournalBeancount)
+	}
+
+	if o.Currency == "" {
+		o.Currency = "RUB"
+	}
+	for _, r := range o.Currency {
+		if !unicode.IsUpper(r) && !unicode.IsDigit(r) {
+			return fmt.Errorf("currency must contain only capital letters and digits")
+		}
+	}
+
+	return nil
+}
+
+// WriteJournal выводит транзакции в порядке даты и ID, так что повторный экспорт
+// тех же данных дает байт-в-байт одинаковый файл. Транзакции должны приходить уже в этом порядке:
+// перебор идет дважды - сначала для счетов и заголовка, затем для проводок.
+func WriteJournal(w io.Writer, transactions iter.Seq[models.Transaction], options JournalOptions) error {
+	categories := make(map[int]models.Category, len(options.Categories))
+	for _, cat := range options.Categories {
+		categories[cat.ID] = cat
+	}
+
+	out := bufio.NewWriter(w)
+	beancount := options.Format == JournalBeancount
+
+	accounts, count, openDate := journalAccounts(transactions, categories)
+
+	if beancount {
+		fmt.Fprintf(out, "option \"operating_currency\" \"%s\"\n\n", options.Currency)
+		for _, account := range accounts {
+			fmt.Fprintf(out, "%s open %s %s\n", openDate, account, options.Currency)
+		}
+	} else {
+		fmt.Fprintf(out, "; expense-tracker export, %d transactions\n\n", count)
+		fmt.Fprintf(out, "commodity %s\n", options.Currency)
+		for _, account := range accounts {
+			fmt.Fprintf(out, "account %s\n", account)
+		}
+	}
+
+	for tx := range transactions {
+		category, asset := categoryAccount(tx, categories), assetAccount(tx)
+		amount := strconv.FormatFloat(tx.Amount, 'f', 2, 64)
+
+		debit, credit := category, asset
+		if tx.Type == models.TransactionTypeIncome {
+			debit, credit = asset, category
+		}
+
+		date := tx.Date.Format("2006-01-02")
+		out.WriteString("\n")
+
+		if beancount {
+			fmt.Fprintf(out, "%s * %s\n", date, strconv.Quote(tx.Description))
+			fmt.Fprintf(out, "  id: \"%d\"\n", tx.ID)
+			fmt.Fprintf(out, "  created_at: \"%s\"\n", tx.CreatedAt.UTC().Format(time.RFC3339))
+			if tx.ExternalID != "" {
+				fmt.Fprintf(out, "  external_id: %s\n", strconv.Quote(tx.ExternalID))
+			}
+			fmt.Fprintf(out, "  %s  %s %s\n", debit, amount, options.Currency)
+			fmt.Fprintf(out, "  %s  -%s %s\n", credit, amount, options.Currency)
+			continue
+		}
+
+		description := strings.ReplaceAll(tx.Description, "\n", " ")
+		fmt.Fprintf(out, "%s * %s\n", date, description)
+		fmt.Fprintf(out, "    ; id: %d\n", tx.ID)
+		fmt.Fprintf(out, "    ; created_at: %s\n", tx.CreatedAt.UTC().Format(time.RFC3339))
+		if tx.ExternalID != "" {
+			fmt.Fprintf(out, "    ; external_id: %s\n", tx.ExternalID)
+		}
+		fmt.Fprintf(out, "    %s  %s %s\n", debit, amount, options.Currency)
+		fmt.Fprintf(out, "    %s  -%s %s\n", credit, amount, options.Currency)
+	}
+
+	return out.Flush()
+}
+
+// journalAccounts собирает счета проводок, число транзакций и дату открытия счетов - дату первой транзакции
+func journalAccounts(transactions iter.Seq[models.Transaction], categories map[int]models.Category) ([]string, int, string) {
+	seen := make(map[string]bool)
+	var accounts []string
+	count, openDate := 0, "1970-01-01"
+	for tx := range transactions {
+		if count == 0 {
+			openDate = tx.Date.Format("2006-01-02")
+		}
+		count++
+		for _, account := range []string{categoryAccount(tx, categories), assetAccount(tx)} {
+			if !seen[account] {
+				seen[account] = true
+				accounts = append(accounts, account)
+			}
+		}
+	}
+	sort.Strings(accounts)
+	return accounts, count, openDate
+}
+
+func categoryAccount(tx models.Transaction, categories map[int]models.Category) string {
+	root := "Expenses"
+	if tx.Type == models.TransactionTypeIncome {
+		root = "Income"
+	}
+
+	name := "Uncategorized"
+	if cat, exists := categories[tx.CategoryID]; exists {
+		name = cat.Name
+	}
+
+	return root + ":" + accountComponent(name)
+}
+
+func assetAccount(tx models.Transaction) string {
+	return "Assets:" + accountComponent(tx.PaymentMethod)
+}
+
+// accountComponent приводит имя к виду, допустимому во всех трех форматах:
+// буквы, цифры и дефисы, первая буква заглавная
+func accountComponent(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.TrimSpace(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+
+	component := strings.TrimSuffix(b.String(), "-")
+	if component == "" {
+		return "Unknown"
+	}
+
+	runes := []rune(component)
+	if !unicode.IsLetter(runes[0]) && !unicode.IsDigit(runes[0]) {
+		return "X" + component
+	}
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ChixXx1/expense-tracker/internal/backup"
+	"github.com/ChixXx1/expense-tracker/internal/database"
+	"github.com/ChixXx1/expense-tracker/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+type AdminHandler struct {
+	storage   database.Storage
+	scheduler *backup.Scheduler
+}
+
+func NewAdminHandler(storage database.Storage, scheduler *backup.Scheduler) *AdminHandler {
+	return &AdminHandler{
+		storage:   storage,
+		scheduler: scheduler,
+	}
+}
+
+func (h *AdminHandler) GetBackup(ctx *gin.Context) {
+	archive, err := backup.Create(ctx.Request.Context(), h.storage)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	filename := fmt.Sprintf("expense-tracker-backup-%s.json.gz", archive.CreatedAt.Format("20060102-150405"))
+	ctx.Header("Content-Type", "application/gzip")
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	ctx.Header("X-Backup-Checksum", archive.Checksum)
+	ctx.Status(http.StatusOK)
+
+	if err := backup.Write(ctx.Writer, archive); err != nil {
+		log.Printf("backup: %v", err)
+	}
+}
+
+func (h *AdminHandler) Restore(ctx *gin.Context) {
+	mode := ctx.DefaultQuery("mode", models.RestoreModeReplace)
+	if mode != models.RestoreModeReplace && mode != models.RestoreModeMerge {
+		badRequest(ctx, "mode must be `replace` or `merge`")
+		return
+	}
+
+	// Архив принимается либо файлом формы, либо телом запроса целиком
+	var source io.Reader = ctx.Request.Body
+	if ctx.ContentType() == "multipart/form-data" {
+		file, _, err := ctx.Request.FormFile("file")
+		if err != nil {
+			badRequest(ctx, "file field is required")
+			return
+		}
+		defer file.Close()
+		source = file
+	}
+
+	restoreCtx, ok := h.restoreTarget(ctx)
+	if !ok {
+		return
+	}
+
+	archive, err := backup.Read(source)
+	if err != nil {
+		badRequest(ctx, err.Error())
+		return
+	}
+
+	if err := backup.Verify(archive); err != nil {
+		respondProblem(ctx, http.StatusUnprocessableEntity, CodeValidationFailed, "backup rejected: "+err.Error(), nil, nil)
+		return
+	}
+
+	started := time.Now()
+	result, err := backup.Restore(restoreCtx, h.storage, archive, mode)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	respond(ctx, http.StatusOK, result, gin.H{
+		"message":     "backup restored successfully",
+		"duration_ms": time.Since(started).Milliseconds(),
+	})
+}
+
+func (h *AdminHandler) GetSnapshots(ctx *gin.Context) {
+	snapshots, err := h.scheduler.List()
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	respondList(ctx, http.StatusOK, snapshots, nil)
+}
+
+func (h *AdminHandler) CreateSnapshot(ctx *gin.Context) {
+	snapshot, err := h.scheduler.TakeSnapshot(ctx.Request.Context())
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	respond(ctx, http.StatusCreated, snapshot, gin.H{
+		"message": "snapshot created successfully",
+	})
+}
+
+func (h *AdminHandler) RestoreSnapshot(ctx *gin.Context) {
+	mode := ctx.DefaultQuery("mode", models.RestoreModeReplace)
+	if mode != models.RestoreModeReplace && mode != models.RestoreModeMerge {
+		badRequest(ctx, "mode must be `replace` or `merge`")
+		return
+	}
+
+	archive, err := h.scheduler.Load(ctx.Param("name"))
+	if err != nil {
+		if errors.Is(err, backup.ErrSnapshotNotFound) {
+			respondError(ctx, err)
+			return
+		}
+		respondProblem(ctx, http.StatusUnprocessableEntity, CodeValidationFailed, "snapshot rejected: "+err.Error(), nil, nil)
+		return
+	}
+
+	result, err := backup.Restore(ctx.Request.Context(), h.storage, archive, mode)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	respond(ctx, http.StatusOK, result, gin.H{
+		"message": "snapshot restored successfully",
+	})
+}
+
+// restoreTarget - контекст восстановления: все книги или одна, если задан ledger_id
+func (h *AdminHandler) restoreTarget(ctx *gin.Context) (context.Context, bool) {
+	value := ctx.Query("ledger_id")
+	if value == "" {
+		return ctx.Request.Context(), true
+	}
+
+	ledgerID, err := strconv.Atoi(value)
+	if err != nil || ledgerID <= 0 {
+		badRequest(ctx, "ledger_id must be a positive integer")
+		return nil, false
+	}
+
+	restoreCtx, err := backup.IntoLedger(ctx.Request.Context(), h.storage, ledgerID)
+	if err != nil {
+		respondError(ctx, err)
+		return nil, false
+	}
+	return restoreCtx, true
+}
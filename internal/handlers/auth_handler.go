@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ChixXx1/expense-tracker/internal/auth"
+	"github.com/ChixXx1/expense-tracker/internal/database"
+	"github.com/ChixXx1/expense-tracker/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// claimsKey - ключ gin.Context, под которым Authenticate кладет проверенный access-токен
+const claimsKey = "auth_claims"
+
+type CredentialsRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+	// All - выйти на всех устройствах
+	All bool `json:"all"`
+}
+
+// UserResponse - пользователь в ответах API, без хеша пароля
+type UserResponse struct {
+	ID        int       `json:"id"`
+	Email     string    `json:"email"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newUserResponse(user *models.User) UserResponse {
+	return UserResponse{
+		ID:        user.ID,
+		Email:     user.Email,
+		Version:   user.Version,
+		CreatedAt: user.CreatedAt,
+	}
+}
+
+type Session struct {
+	User   UserResponse `json:"user"`
+	Tokens *auth.Tokens `json:"tokens"`
+}
+
+type AuthHandler struct {
+	service *auth.Service
+}
+
+func NewAuthHandler(service *auth.Service) *AuthHandler {
+	return &AuthHandler{
+		service: service,
+	}
+}
+
+func (h *AuthHandler) Register(ctx *gin.Context) {
+	var request CredentialsRequest
+
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		invalidBody(ctx)
+		return
+	}
+
+	user, err := h.service.Register(ctx.Request.Context(), request.Email, request.Password)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	respond(ctx, http.StatusCreated, newUserResponse(user), gin.H{
+		"message": "user registered successfully",
+	})
+}
+
+func (h *AuthHandler) Login(ctx *gin.Context) {
+	var request CredentialsRequest
+
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		invalidBody(ctx)
+		return
+	}
+
+	user, tokens, err := h.service.Login(ctx.Request.Context(), request.Email, request.Password)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	respond(ctx, http.StatusOK, Session{User: newUserResponse(user), Tokens: tokens}, nil)
+}
+
+func (h *AuthHandler) Refresh(ctx *gin.Context) {
+	var request RefreshRequest
+
+	if err := ctx.ShouldBindJSON(&request); err != nil || request.RefreshToken == "" {
+		invalidBody(ctx)
+		return
+	}
+
+	tokens, err := h.service.Refresh(ctx.Request.Context(), request.RefreshToken)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	respond(ctx, http.StatusOK, tokens, nil)
+}
+
+// Logout отзывает текущий access-токен и переданный refresh-токен, с all=true - все сессии пользователя
+func (h *AuthHandler) Logout(ctx *gin.Context) {
+	var request LogoutRequest
+
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&request); err != nil {
+			invalidBody(ctx)
+			return
+		}
+	}
+
+	claims := currentClaims(ctx)
+	var err error
+	if request.All {
+		err = h.service.LogoutAll(ctx.Request.Context(), claims)
+	} else {
+		err = h.service.Logout(ctx.Request.Context(), claims, request.RefreshToken)
+	}
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	respond(ctx, http.StatusOK, nil, gin.H{
+		"message": "logged out successfully",
+	})
+}
+
+func (h *AuthHandler) Me(ctx *gin.Context) {
+	user, err := h.service.User(ctx.Request.Context(), currentClaims(ctx).Subject)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	respond(ctx, http.StatusOK, newUserResponse(user), nil)
+}
+
+// Authenticate проверяет Bearer-токен, если он передан, и запоминает его в контексте.
+// Запрос без токена проходит дальше: закрытые маршруты отсекает RequireAuth.
+func (h *AuthHandler) Authenticate(ctx *gin.Context) {
+	header := ctx.GetHeader("Authorization")
+	if header == "" {
+		ctx.Next()
+		return
+	}
+
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		unauthorized(ctx, "Authorization header must be 'Bearer <token>'")
+		ctx.Abort()
+		return
+	}
+
+	claims, err := h.service.Authenticate(ctx.Request.Context(), strings.TrimSpace(token))
+	if errors.Is(err, auth.ErrInvalidToken) {
+		unauthorized(ctx, err.Error())
+		ctx.Abort()
+		return
+	}
+	if err != nil {
+		respondError(ctx, err)
+		ctx.Abort()
+		return
+	}
+
+	ctx.Set(claimsKey, claims)
+	ctx.Next()
+}
+
+// RequireAuth пропускает только запросы с действующим access-токеном; книгу выбирает RequireLedger
+func RequireAuth(ctx *gin.Context) {
+	claims := currentClaims(ctx)
+	if claims == nil {
+		unauthorized(ctx, "authentication required")
+		ctx.Abort()
+		return
+	}
+
+	ctx.Next()
+}
+
+// RequireAdmin пропускает только администраторов и снимает ограничение книгой:
+// резервные копии и снимки охватывают данные всех пользователей. Ставится после RequireAuth.
+func (h *AuthHandler) RequireAdmin(ctx *gin.Context) {
+	admin, err := h.service.IsAdmin(ctx.Request.Context(), currentUserID(ctx))
+	if err != nil {
+		respondError(ctx, err)
+		ctx.Abort()
+		return
+	}
+	if !admin {
+		forbidden(ctx, "administrator access required")
+		ctx.Abort()
+		return
+	}
+
+	ctx.Request = ctx.Request.WithContext(database.AllLedgers(ctx.Request.Context()))
+	ctx.Next()
+}
+
+func currentClaims(ctx *gin.Context) *auth.Claims {
+	claims, _ := ctx.Value(claimsKey).(*auth.Claims)
+	return claims
+}
+
+// currentUserID - ID вошедшего пользователя, 0 для анонимного запроса
+func currentUserID(ctx *gin.Context) int {
+	if claims := currentClaims(ctx); claims != nil {
+		return claims.Subject
+	}
+	return 0
+}
+
+func unauthorized(ctx *gin.Context, detail string) {
+	ctx.Header("WWW-Authenticate", `Bearer realm="api"`)
+	respondProblem(ctx, http.StatusUnauthorized, CodeUnauthorized, detail, nil, nil)
+}
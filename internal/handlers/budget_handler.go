@@ -26,9 +26,7 @@ func (h *BudgetHandler) GetBudgets(ctx *gin.Context) {
 	if categoryIDStr := ctx.Query("category_id"); categoryIDStr != "" {
 		categoryID, err := strconv.Atoi(categoryIDStr)
 		if err != nil || categoryID <= 0 {
-			ctx.JSON(http.StatusBadRequest, gin.H{
-				"error": "category_id must be a positive integer",
-			})
+			badRequest(ctx, "category_id must be a positive integer")
 			return
 		}
 		filters.CategoryID = &categoryID
@@ -41,9 +39,7 @@ func (h *BudgetHandler) GetBudgets(ctx *gin.Context) {
 			models.BudgetPeriodYearly:  true,
 		}
 		if !validPeriods[period] {
-			ctx.JSON(http.StatusBadRequest, gin.H{
-				"error": "period must be 'monthly', 'weekly' or 'yearly'",
-			})
+			badRequest(ctx, "period must be 'monthly', 'weekly' or 'yearly'")
 			return
 		}
 		filters.Period = &period
@@ -52,78 +48,64 @@ func (h *BudgetHandler) GetBudgets(ctx *gin.Context) {
 	if monthStr := ctx.Query("month"); monthStr != "" {
 		month, err := time.Parse("2006-01", monthStr)
 		if err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{
-				"error": "invalid month format, use YYYY-MM",
-			})
+			badRequest(ctx, "invalid month format, use YYYY-MM")
 			return
 		}
 		filters.Month = &month
 	}
 
-	budgets, err := h.storage.GetBudgets(filters)
+	budgets, err := h.storage.GetBudgets(ctx.Request.Context(), filters)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to get budgets",
-		})
+		respondError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{
-		"budgets": budgets,
-		"count":   len(budgets),
-	})
+	respondList(ctx, http.StatusOK, budgets, nil)
 }
 
 func (h *BudgetHandler) GetBudgetByID(ctx *gin.Context) {
 	idParam := ctx.Param("id")
 	id, err := strconv.Atoi(idParam)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid budget ID",
-		})
+		badRequest(ctx, "invalid budget ID")
 		return
 	}
 
-	budget, err := h.storage.GetBudgetByID(id)
+	budget, err := h.storage.GetBudgetByID(ctx.Request.Context(), id)
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{
-			"error": "budget not found",
-		})
+		respondError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{
-		"budget": budget,
-	})
+	if notModified(ctx, budget.Version) {
+		return
+	}
+
+	setETag(ctx, budget.Version)
+	respond(ctx, http.StatusOK, budget, nil)
 }
 
 func (h *BudgetHandler) CreateBudget(ctx *gin.Context) {
 	var budget models.Budget
 
 	if err := ctx.ShouldBindJSON(&budget); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid request body",
-		})
+		invalidBody(ctx)
 		return
 	}
 
 	if err := budget.Validate(); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		respondError(ctx, err)
 		return
 	}
 
-	if err := h.storage.CreateBudget(&budget); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to create budget: " + err.Error(),
-		})
+	if err := h.storage.CreateBudget(ctx.Request.Context(), &budget); err != nil {
+		respondError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusCreated, gin.H{
+	setETag(ctx, budget.Version)
+	respond(ctx, http.StatusCreated, budget, gin.H{
 		"message": "budget created successfully",
-		"budget":  budget,
 	})
 }
 
@@ -131,39 +113,93 @@ func (h *BudgetHandler) UpdateBudget(ctx *gin.Context) {
 	idParam := ctx.Param("id")
 	id, err := strconv.Atoi(idParam)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid budget ID",
-		})
+		badRequest(ctx, "invalid budget ID")
+		return
+	}
+
+	existing, err := h.storage.GetBudgetByID(ctx.Request.Context(), id)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	version, ok := expectedVersion(ctx, existing.Version)
+	if !ok {
 		return
 	}
 
 	var budget models.Budget
 	if err := ctx.ShouldBindJSON(&budget); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid request body",
-		})
+		invalidBody(ctx)
 		return
 	}
 
 	budget.ID = id
+	budget.Version = version
+
+	if err := budget.Validate(); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	if err := h.storage.UpdateBudget(ctx.Request.Context(), &budget); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, budget.Version)
+	respond(ctx, http.StatusOK, budget, gin.H{
+		"message": "budget updated successfully",
+	})
+}
+
+func (h *BudgetHandler) PatchBudget(ctx *gin.Context) {
+	idParam := ctx.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		badRequest(ctx, "invalid budget ID")
+		return
+	}
+
+	existing, err := h.storage.GetBudgetByID(ctx.Request.Context(), id)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	version, ok := expectedVersion(ctx, existing.Version)
+	if !ok {
+		return
+	}
+
+	patch, ok := readMergePatch(ctx)
+	if !ok {
+		return
+	}
+
+	budget := *existing
+	if err := applyMergePatch(&budget, patch); err != nil {
+		badRequest(ctx, err.Error())
+		return
+	}
+
+	budget.ID = existing.ID
+	budget.Version = version
+	budget.CreatedAt = existing.CreatedAt
 
 	if err := budget.Validate(); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		respondError(ctx, err)
 		return
 	}
 
-	if err := h.storage.UpdateBudget(&budget); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to update budget: " + err.Error(),
-		})
+	if err := h.storage.UpdateBudget(ctx.Request.Context(), &budget); err != nil {
+		respondError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{
+	setETag(ctx, budget.Version)
+	respond(ctx, http.StatusOK, budget, gin.H{
 		"message": "budget updated successfully",
-		"budget":  budget,
 	})
 }
 
@@ -171,20 +207,27 @@ func (h *BudgetHandler) DeleteBudget(ctx *gin.Context) {
 	idParam := ctx.Param("id")
 	id, err := strconv.Atoi(idParam)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid budget ID",
-		})
+		badRequest(ctx, "invalid budget ID")
+		return
+	}
+
+	existing, err := h.storage.GetBudgetByID(ctx.Request.Context(), id)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	version, ok := expectedVersion(ctx, existing.Version)
+	if !ok {
 		return
 	}
 
-	if err := h.storage.DeleteBudget(id); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to delete budget: " + err.Error(),
-		})
+	if err := h.storage.DeleteBudget(ctx.Request.Context(), id, version); err != nil {
+		respondError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{
+	respond(ctx, http.StatusOK, nil, gin.H{
 		"message": "budget deleted successfully",
 	})
 }
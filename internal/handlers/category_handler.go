@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -20,70 +22,58 @@ func NewCategoryHandler(storage database.Storage) *CategoryHandler {
 }
 
 func (h *CategoryHandler) GetCategories(ctx *gin.Context) {
-	categories, err := h.storage.GetCategories()
+	categories, err := h.storage.GetCategories(ctx.Request.Context())
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to get categories",
-		})
+		respondError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{
-		"categories": categories,
-	})
+	respondList(ctx, http.StatusOK, categories, nil)
 }
 
 func (h *CategoryHandler) GetCategoryByID(ctx *gin.Context) {
 	idParam := ctx.Param("id")
 	id, err := strconv.Atoi(idParam)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid category ID",
-		})
+		badRequest(ctx, "invalid category ID")
 		return
 	}
 
-	category, err := h.storage.GetCategoryByID(id)
+	category, err := h.storage.GetCategoryByID(ctx.Request.Context(), id)
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{
-			"error": "failed to get category by ID",
-		})
+		respondError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{
-		"category": category,
-	})
+	if notModified(ctx, category.Version) {
+		return
+	}
 
+	setETag(ctx, category.Version)
+	respond(ctx, http.StatusOK, category, nil)
 }
 
 func (h *CategoryHandler) CreateCategory(ctx *gin.Context) {
 	var category models.Category
 
 	if err := ctx.ShouldBindJSON(&category); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid request body",
-		})
+		invalidBody(ctx)
 		return
 	}
 
 	if err := category.Validate(); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		respondError(ctx, err)
 		return
 	}
 
-	if err := h.storage.CreateCategory(&category); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to create category: " + err.Error(),
-		})
+	if err := h.storage.CreateCategory(ctx.Request.Context(), &category); err != nil {
+		respondError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusCreated, gin.H{
-		"message":  "category created successfully",
-		"category": category,
+	setETag(ctx, category.Version)
+	respond(ctx, http.StatusCreated, category, gin.H{
+		"message": "category created successfully",
 	})
 }
 
@@ -91,39 +81,92 @@ func (h *CategoryHandler) UpdateCategory(ctx *gin.Context) {
 	idParam := ctx.Param("id")
 	id, err := strconv.Atoi(idParam)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid category ID",
-		})
+		badRequest(ctx, "invalid category ID")
+		return
+	}
+
+	existing, err := h.storage.GetCategoryByID(ctx.Request.Context(), id)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	version, ok := expectedVersion(ctx, existing.Version)
+	if !ok {
 		return
 	}
 
 	var category models.Category
 	if err := ctx.ShouldBindJSON(&category); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid request body",
-		})
+		invalidBody(ctx)
 		return
 	}
 
 	category.ID = id
+	category.Version = version
+
+	if err := category.Validate(); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	if err := h.storage.UpdateCategory(ctx.Request.Context(), &category); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, category.Version)
+	respond(ctx, http.StatusOK, category, gin.H{
+		"message": "category updated successfully",
+	})
+}
+
+func (h *CategoryHandler) PatchCategory(ctx *gin.Context) {
+	idParam := ctx.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		badRequest(ctx, "invalid category ID")
+		return
+	}
+
+	existing, err := h.storage.GetCategoryByID(ctx.Request.Context(), id)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	version, ok := expectedVersion(ctx, existing.Version)
+	if !ok {
+		return
+	}
+
+	patch, ok := readMergePatch(ctx)
+	if !ok {
+		return
+	}
+
+	category := *existing
+	if err := applyMergePatch(&category, patch); err != nil {
+		badRequest(ctx, err.Error())
+		return
+	}
+
+	category.ID = existing.ID
+	category.Version = version
 
 	if err := category.Validate(); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		respondError(ctx, err)
 		return
 	}
 
-	if err := h.storage.UpdateCategory(&category); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to update category: " + err.Error(),
-		})
+	if err := h.storage.UpdateCategory(ctx.Request.Context(), &category); err != nil {
+		respondError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{
-		"message":  "category updated successfully",
-		"category": category,
+	setETag(ctx, category.Version)
+	respond(ctx, http.StatusOK, category, gin.H{
+		"message": "category updated successfully",
 	})
 }
 
@@ -131,20 +174,87 @@ func (h *CategoryHandler) DeleteCategory(ctx *gin.Context) {
 	idParam := ctx.Param("id")
 	id, err := strconv.Atoi(idParam)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid category ID",
-		})
+		badRequest(ctx, "invalid category ID")
 		return
 	}
 
-	if err := h.storage.DeleteCategory(id); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to delete category: " + err.Error(),
-		})
+	existing, err := h.storage.GetCategoryByID(ctx.Request.Context(), id)
+	if err != nil {
+		respondError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{
+	version, ok := expectedVersion(ctx, existing.Version)
+	if !ok {
+		return
+	}
+
+	reassignTo := 0
+	if reassignStr := ctx.Query("reassign_to"); reassignStr != "" {
+		reassignTo, err = strconv.Atoi(reassignStr)
+		if err != nil || reassignTo <= 0 || reassignTo == id {
+			badRequest(ctx, "reassign_to must be the ID of another category")
+			return
+		}
+	}
+
+	var transactions, budgets int
+	err = h.storage.WithTx(ctx.Request.Context(), func(tx database.Storage) error {
+		if reassignTo != 0 {
+			var err error
+			if transactions, budgets, err = reassignCategory(ctx.Request.Context(), tx, id, reassignTo); err != nil {
+				return err
+			}
+		}
+		return tx.DeleteCategory(ctx.Request.Context(), id, version)
+	})
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	meta := gin.H{
 		"message": "category deleted successfully",
+	}
+	if reassignTo != 0 {
+		meta["reassigned_transactions"] = transactions
+		meta["reassigned_budgets"] = budgets
+	}
+	respond(ctx, http.StatusOK, nil, meta)
+}
+
+// reassignCategory переносит транзакции и бюджеты категории from в категорию to
+func reassignCategory(ctx context.Context, storage database.Storage, from, to int) (int, int, error) {
+	if _, err := storage.GetCategoryByID(ctx, to); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return 0, 0, &database.ValidationError{Field: "reassign_to", Message: "category to reassign to does not exist"}
+		}
+		return 0, 0, err
+	}
+
+	page, err := storage.GetTransactions(ctx, database.TransactionFilters{
+		CategoryID: database.ValueFilter[int]{Values: []int{from}},
 	})
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, transaction := range page.Transactions {
+		transaction.CategoryID = to
+		if err := storage.UpdateTransaction(ctx, &transaction); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	budgets, err := storage.GetBudgets(ctx, database.BudgetFilters{CategoryID: &from})
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, budget := range budgets {
+		budget.CategoryID = to
+		if err := storage.UpdateBudget(ctx, &budget); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return len(page.Transactions), len(budgets), nil
 }
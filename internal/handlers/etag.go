@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// etag строится из версии записи; версия растет при каждом изменении
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+func setETag(ctx *gin.Context, version int) {
+	ctx.Header("ETag", etag(version))
+}
+
+// etagMatches проверяет список из If-Match / If-None-Match. При weak слабые теги (W/"...") сравниваются
+// как сильные - так требует If-None-Match; для If-Match сравнение строгое и слабый тег не совпадает никогда.
+func etagMatches(header string, version int, weak bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if weak {
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		if tag == "*" || tag == etag(version) {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified отвечает 304, если клиент уже видел текущую версию
+func notModified(ctx *gin.Context, version int) bool {
+	header := ctx.GetHeader("If-None-Match")
+	if header == "" || !etagMatches(header, version, true) {
+		return false
+	}
+
+	setETag(ctx, version)
+	ctx.Status(http.StatusNotModified)
+	return true
+}
+
+// expectedVersion разбирает If-Match относительно текущей версии записи.
+// Возвращает версию для передачи в хранилище (0 - заголовка нет) или отвечает 412.
+// Сама проверка повторяется в хранилище под блокировкой, здесь только ранний отказ.
+func expectedVersion(ctx *gin.Context, current int) (int, bool) {
+	header := ctx.GetHeader("If-Match")
+	if header == "" {
+		return 0, true
+	}
+
+	if !etagMatches(header, current, false) {
+		respondProblem(ctx, http.StatusPreconditionFailed, CodePreconditionFailed,
+			"resource has been modified, current ETag is "+etag(current), nil, nil)
+		return 0, false
+	}
+
+	return current, true
+}
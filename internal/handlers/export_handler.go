@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/ChixXx1/expense-tracker/internal/database"
+	"github.com/ChixXx1/expense-tracker/internal/export"
+	"github.com/gin-gonic/gin"
+)
+
+// exportBatchSize - через сколько строк ответ сбрасывается клиенту
+const exportBatchSize = 500
+
+type ExportHandler struct {
+	storage database.Storage
+}
+
+func NewExportHandler(storage database.Storage) *ExportHandler {
+	return &ExportHandler{
+		storage: storage,
+	}
+}
+
+func (h *ExportHandler) ExportTransactions(ctx *gin.Context) {
+	filters, ok := exportFilters(ctx)
+	if !ok {
+		return
+	}
+
+	options := export.Options{
+		Format:           ctx.DefaultQuery("format", export.FormatCSV),
+		Columns:          splitQueryList(ctx.Query("columns")),
+		DateFormat:       ctx.Query("date_format"),
+		DecimalSeparator: ctx.Query("decimal_separator"),
+		Delimiter:        ctx.Query("delimiter"),
+	}
+
+	if err := options.Validate(); err != nil {
+		badRequest(ctx, err.Error())
+		return
+	}
+
+	categories, err := h.storage.GetCategories(ctx.Request.Context())
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	options.CategoryNames = make(map[int]string, len(categories))
+	for _, cat := range categories {
+		options.CategoryNames[cat.ID] = cat.Name
+	}
+
+	// снимок читаем до заголовков, чтобы ошибку фильтров еще можно было вернуть статусом
+	stream, err := h.storage.StreamTransactions(ctx.Request.Context(), filters)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.Header("Content-Type", options.ContentType())
+	ctx.Header("Content-Disposition", `attachment; filename="transactions.`+options.Format+`"`)
+	ctx.Status(http.StatusOK)
+
+	writer, err := export.NewWriter(ctx.Writer, options)
+	if err != nil {
+		log.Printf("export: %v", err)
+		return
+	}
+
+	// После начала ответа статус уже не поменять, ошибки только логируем
+	written := 0
+	for tx := range stream.All() {
+		if err := writer.Write(tx); err != nil {
+			log.Printf("export: %v", err)
+			return
+		}
+		if written++; written%exportBatchSize == 0 {
+			ctx.Writer.Flush()
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		log.Printf("export: %v", err)
+	}
+}
+
+func (h *ExportHandler) ExportJournal(ctx *gin.Context) {
+	filters, ok := exportFilters(ctx)
+	if !ok {
+		return
+	}
+
+	options := export.JournalOptions{
+		Format:   ctx.DefaultQuery("format", export.JournalLedger),
+		Currency: ctx.Query("currency"),
+	}
+
+	if err := options.Validate(); err != nil {
+		badRequest(ctx, err.Error())
+		return
+	}
+
+	categories, err := h.storage.GetCategories(ctx.Request.Context())
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	options.Categories = categories
+
+	// журнал пишется в порядке даты и ID, независимо от сортировки в запросе
+	filters.SortBy, filters.SortOrder = database.SortByDate, database.SortAsc
+	stream, err := h.storage.StreamTransactions(ctx.Request.Context(), filters)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	extension := "journal"
+	if options.Format == export.JournalBeancount {
+		extension = "beancount"
+	}
+
+	ctx.Header("Content-Type", "text/plain; charset=utf-8")
+	ctx.Header("Content-Disposition", `attachment; filename="transactions.`+extension+`"`)
+	ctx.Status(http.StatusOK)
+
+	if err := export.WriteJournal(ctx.Writer, stream.All(), options); err != nil {
+		log.Printf("export: %v", err)
+	}
+}
+
+// exportFilters разбирает фильтры выгрузки; выгрузка всегда полная, поэтому параметры страниц отклоняются
+func exportFilters(ctx *gin.Context) (database.TransactionFilters, bool) {
+	filters, err := parseTransactionFilters(ctx)
+	if err != nil {
+		badRequest(ctx, err.Error())
+		return filters, false
+	}
+
+	if filters.Limit != nil || filters.Offset != nil || filters.Cursor != "" {
+		badRequest(ctx, "export does not support limit, offset or cursor")
+		return filters, false
+	}
+
+	return filters, true
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// idempotentReplayedHeader помечает ответ, повторенный из сохраненного
+	idempotentReplayedHeader = "Idempotent-Replayed"
+
+	defaultIdempotencyTTL = 24 * time.Hour
+	maxIdempotencyKeyLen  = 255
+)
+
+// IdempotencyTTLFromEnv читает IDEMPOTENCY_TTL, по умолчанию ответы хранятся сутки
+func IdempotencyTTLFromEnv() (time.Duration, error) {
+	value := os.Getenv("IDEMPOTENCY_TTL")
+	if value == "" {
+		return defaultIdempotencyTTL, nil
+	}
+
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		return 0, fmt.Errorf("IDEMPOTENCY_TTL must be a positive duration like 12h, got '%s'", value)
+	}
+	return ttl, nil
+}
+
+type idempotentResponse struct {
+	fingerprint [sha256.Size]byte
+	done        bool
+	status      int
+	header      http.Header
+	body        []byte
+	expires     time.Time
+}
+
+// IdempotencyStore хранит ответы на POST-запросы с заголовком Idempotency-Key.
+// Повтор с тем же ключом и телом получает сохраненный ответ, с другим телом - 422.
+type IdempotencyStore struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	responses map[string]*idempotentResponse
+	lastSweep time.Time
+}
+
+func NewIdempotencyStore(ttl time.Duration) *IdempotencyStore {
+	return &IdempotencyStore{
+		ttl:       ttl,
+		responses: make(map[string]*idempotentResponse),
+	}
+}
+
+// Middleware работает только для POST с заголовком Idempotency-Key от вошедшего пользователя,
+// остальные запросы пропускает как есть. Ставится после AuthHandler.Authenticate, чтобы ключи разных
+// пользователей не пересекались; у анонимных запросов общего владельца нет, и их ответы не сохраняются.
+func (s *IdempotencyStore) Middleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		key := ctx.GetHeader(IdempotencyKeyHeader)
+		if ctx.Request.Method != http.MethodPost || key == "" || currentUserID(ctx) == 0 {
+			ctx.Next()
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLen {
+			badRequest(ctx, "Idempotency-Key must be at most 255 characters")
+			ctx.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			invalidBody(ctx)
+			ctx.Abort()
+			return
+		}
+		ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		// Ключ привязан к запросу целиком: тот же ключ на другом маршруте или в другой книге - тоже конфликт
+		fingerprint := sha256.Sum256(append([]byte(ctx.Request.URL.RequestURI()+"\n"+ctx.GetHeader(LedgerHeader)+"\n"), body...))
+
+		// у каждого пользователя свое пространство ключей
+		key = strconv.Itoa(currentUserID(ctx)) + ":" + key
+
+		saved, ok := s.begin(key, fingerprint)
+		if !ok {
+			switch {
+			case saved.fingerprint != fingerprint:
+				respondProblem(ctx, http.StatusUnprocessableEntity, CodeIdempotencyKeyReused,
+					"Idempotency-Key was already used with a different request", nil, nil)
+			case !saved.done:
+				respondProblem(ctx, http.StatusConflict, CodeIdempotencyKeyInProgress,
+					"a request with this Idempotency-Key is still being processed", nil, nil)
+			default:
+				replay(ctx, saved)
+			}
+			ctx.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: ctx.Writer}
+		ctx.Writer = recorder
+		ctx.Next()
+
+		s.finish(key, recorder)
+	}
+}
+
+// begin резервирует ключ; если он уже есть, возвращает копию сохраненной записи и false
+func (s *IdempotencyStore) begin(key string, fingerprint [sha256.Size]byte) (idempotentResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	if saved, ok := s.responses[key]; ok && now.Before(saved.expires) {
+		return *saved, false
+	}
+
+	s.responses[key] = &idempotentResponse{fingerprint: fingerprint, expires: now.Add(s.ttl)}
+	return idempotentResponse{}, true
+}
+
+// finish сохраняет ответ; ответы 5xx не сохраняются, чтобы клиент мог повторить запрос
+func (s *IdempotencyStore) finish(key string, recorder *responseRecorder) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := recorder.Status()
+	if status >= http.StatusInternalServerError {
+		delete(s.responses, key)
+		return
+	}
+
+	saved, ok := s.responses[key]
+	if !ok {
+		return
+	}
+	saved.done = true
+	saved.status = status
+	saved.header = recorder.Header().Clone()
+	saved.body = recorder.body.Bytes()
+}
+
+// sweep удаляет просроченные ответы не чаще раза в минуту
+func (s *IdempotencyStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+
+	for key, saved := range s.responses {
+		if !now.Before(saved.expires) {
+			delete(s.responses, key)
+		}
+	}
+}
+
+func replay(ctx *gin.Context, saved idempotentResponse) {
+	for name, values := range saved.header {
+		for _, value := range values {
+			ctx.Writer.Header().Add(name, value)
+		}
+	}
+	ctx.Header(idempotentReplayedHeader, "true")
+	ctx.Data(saved.status, saved.header.Get("Content-Type"), saved.body)
+}
+
+// responseRecorder копирует тело ответа, продолжая писать его клиенту
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *responseRecorder) WriteString(data string) (int, error) {
+	r.body.WriteString(data)
+	return r.ResponseWriter.WriteString(data)
+}
@@ -0,0 +1,363 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/ChixXx1/expense-tracker/internal/database"
+	"github.com/ChixXx1/expense-tracker/internal/importer"
+	"github.com/ChixXx1/expense-tracker/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+type ImportHandler struct {
+	storage database.Storage
+}
+
+func NewImportHandler(storage database.Storage) *ImportHandler {
+	return &ImportHandler{
+		storage: storage,
+	}
+}
+
+func (h *ImportHandler) Import(ctx *gin.Context) {
+	// маршрут помечен ReadOnly ради предпросмотра, сам импорт доступен от editor
+	if ctx.Query("commit") == "true" && !requireEditor(ctx) {
+		return
+	}
+
+	format := ctx.Param("format")
+	imp, err := importer.Get(format)
+	if err != nil {
+		badRequest(ctx, err.Error())
+		return
+	}
+
+	fileHeader, err := ctx.FormFile("file")
+	if err != nil {
+		badRequest(ctx, "file is required")
+		return
+	}
+
+	categories, err := h.storage.GetCategories(ctx.Request.Context())
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	options := importer.Options{
+		Account:       ctx.PostForm("account"),
+		PaymentMethod: ctx.PostForm("payment_method"),
+		DateFormat:    ctx.PostForm("date_format"),
+		Categories:    categories,
+	}
+
+	for field, target := range map[string]*int{
+		"expense_category_id": &options.ExpenseCategoryID,
+		"income_category_id":  &options.IncomeCategoryID,
+	} {
+		valueStr := ctx.PostForm(field)
+		if valueStr == "" {
+			continue
+		}
+		value, err := strconv.Atoi(valueStr)
+		if err != nil || value <= 0 {
+			badRequest(ctx, field+" must be a positive integer")
+			return
+		}
+		*target = value
+	}
+
+	if format == "csv" {
+		profile, ok := h.resolveProfile(ctx)
+		if !ok {
+			return
+		}
+
+		if err := profile.Validate(); err != nil {
+			respondError(ctx, err)
+			return
+		}
+		options.Profile = profile
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		badRequest(ctx, "failed to open file")
+		return
+	}
+	defer file.Close()
+
+	rows, err := imp.Parse(file, options)
+	if err != nil {
+		badRequest(ctx, err.Error())
+		return
+	}
+
+	importer.CheckCategories(rows, categories)
+	h.commitRows(ctx, rows)
+}
+
+// importPlan - разбор строк выписки: что будет создано и что пропущено
+type importPlan struct {
+	valid              []models.Transaction
+	invalid            int
+	alreadyImported    int
+	possibleDuplicates int
+}
+
+// errInvalidRows прерывает транзакцию импорта, если есть невалидные строки и skip_invalid не задан
+var errInvalidRows = errors.New("some rows are invalid, fix them or use skip_invalid=true")
+
+// planImport помечает уже импортированные строки и вероятные дубли и отбирает строки для создания
+func planImport(ctx context.Context, storage database.Storage, rows []importer.ParsedRow, force bool) (*importPlan, error) {
+	imported, err := storage.GetImportedKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	importer.MarkImported(rows, imported)
+
+	plan := &importPlan{}
+	for i, row := range rows {
+		if row.AlreadyImported {
+			plan.alreadyImported++
+			continue
+		}
+		if !row.Valid() {
+			plan.invalid++
+			continue
+		}
+
+		duplicates, err := storage.FindDuplicates(ctx, row.Transaction)
+		if err != nil {
+			return nil, err
+		}
+		rows[i].DuplicateOf = nil
+		for _, duplicate := range duplicates {
+			rows[i].DuplicateOf = append(rows[i].DuplicateOf, duplicate.ID)
+		}
+
+		if len(duplicates) > 0 && !force {
+			plan.possibleDuplicates++
+			continue
+		}
+		plan.valid = append(plan.valid, row.Transaction)
+	}
+
+	return plan, nil
+}
+
+// commitRows отдает предпросмотр или, при commit=true, создает транзакции одной пачкой.
+// Проверка дублей и создание идут в одной транзакции хранилища, чтобы параллельный импорт
+// той же выписки не проскочил между ними.
+func (h *ImportHandler) commitRows(ctx *gin.Context, rows []importer.ParsedRow) {
+	force := ctx.Query("force") == "true"
+
+	if ctx.Query("commit") != "true" {
+		plan, err := planImport(ctx.Request.Context(), h.storage, rows, force)
+		if err != nil {
+			respondError(ctx, err)
+			return
+		}
+
+		respond(ctx, http.StatusOK, rows, gin.H{
+			"total":            len(rows),
+			"valid":            len(plan.valid),
+			"invalid":          plan.invalid,
+			"already_imported": plan.alreadyImported,
+			"duplicates":       plan.possibleDuplicates,
+		})
+		return
+	}
+
+	skipInvalid := ctx.Query("skip_invalid") == "true"
+
+	var plan *importPlan
+	err := h.storage.WithTx(ctx.Request.Context(), func(tx database.Storage) error {
+		var err error
+		if plan, err = planImport(ctx.Request.Context(), tx, rows, force); err != nil {
+			return err
+		}
+		if plan.invalid > 0 && !skipInvalid {
+			return errInvalidRows
+		}
+		if len(plan.valid) == 0 {
+			return nil
+		}
+		return tx.CreateTransactions(ctx.Request.Context(), plan.valid)
+	})
+
+	switch {
+	case errors.Is(err, errInvalidRows):
+		respondProblem(ctx, http.StatusUnprocessableEntity, CodeValidationFailed, err.Error(), nil, gin.H{
+			"rows":    rows,
+			"invalid": plan.invalid,
+		})
+		return
+	case err != nil:
+		respondError(ctx, err)
+		return
+	}
+
+	if len(plan.valid) == 0 {
+		respond(ctx, http.StatusOK, []models.Transaction{}, gin.H{
+			"message":          "nothing to import",
+			"imported":         0,
+			"skipped":          plan.invalid,
+			"already_imported": plan.alreadyImported,
+			"duplicates":       plan.possibleDuplicates,
+		})
+		return
+	}
+
+	respond(ctx, http.StatusCreated, plan.valid, gin.H{
+		"message":          "transactions imported successfully",
+		"imported":         len(plan.valid),
+		"skipped":          plan.invalid,
+		"already_imported": plan.alreadyImported,
+		"duplicates":       plan.possibleDuplicates,
+	})
+}
+
+func (h *ImportHandler) resolveProfile(ctx *gin.Context) (*models.ImportProfile, bool) {
+	if profileIDStr := ctx.PostForm("profile_id"); profileIDStr != "" {
+		profileID, err := strconv.Atoi(profileIDStr)
+		if err != nil {
+			badRequest(ctx, "invalid profile ID")
+			return nil, false
+		}
+
+		profile, err := h.storage.GetImportProfileByID(ctx.Request.Context(), profileID)
+		if err != nil {
+			respondError(ctx, err)
+			return nil, false
+		}
+		return profile, true
+	}
+
+	profileJSON := ctx.PostForm("profile")
+	if profileJSON == "" {
+		badRequest(ctx, "profile_id or profile is required")
+		return nil, false
+	}
+
+	var profile models.ImportProfile
+	if err := json.Unmarshal([]byte(profileJSON), &profile); err != nil {
+		badRequest(ctx, "invalid profile")
+		return nil, false
+	}
+	if profile.Name == "" {
+		profile.Name = "inline"
+	}
+
+	return &profile, true
+}
+
+func (h *ImportHandler) GetProfiles(ctx *gin.Context) {
+	profiles, err := h.storage.GetImportProfiles(ctx.Request.Context())
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	respondList(ctx, http.StatusOK, profiles, nil)
+}
+
+func (h *ImportHandler) CreateProfile(ctx *gin.Context) {
+	var profile models.ImportProfile
+
+	if err := ctx.ShouldBindJSON(&profile); err != nil {
+		invalidBody(ctx)
+		return
+	}
+
+	if err := profile.Validate(); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	if err := h.storage.CreateImportProfile(ctx.Request.Context(), &profile); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	respond(ctx, http.StatusCreated, profile, gin.H{
+		"message": "import profile created successfully",
+	})
+}
+
+func (h *ImportHandler) UpdateProfile(ctx *gin.Context) {
+	idParam := ctx.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		badRequest(ctx, "invalid profile ID")
+		return
+	}
+
+	existing, err := h.storage.GetImportProfileByID(ctx.Request.Context(), id)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	version, ok := expectedVersion(ctx, existing.Version)
+	if !ok {
+		return
+	}
+
+	var profile models.ImportProfile
+	if err := ctx.ShouldBindJSON(&profile); err != nil {
+		invalidBody(ctx)
+		return
+	}
+
+	profile.ID = id
+	profile.Version = version
+
+	if err := profile.Validate(); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	if err := h.storage.UpdateImportProfile(ctx.Request.Context(), &profile); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, profile.Version)
+	respond(ctx, http.StatusOK, profile, gin.H{
+		"message": "import profile updated successfully",
+	})
+}
+
+func (h *ImportHandler) DeleteProfile(ctx *gin.Context) {
+	idParam := ctx.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		badRequest(ctx, "invalid profile ID")
+		return
+	}
+
+	existing, err := h.storage.GetImportProfileByID(ctx.Request.Context(), id)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	version, ok := expectedVersion(ctx, existing.Version)
+	if !ok {
+		return
+	}
+
+	if err := h.storage.DeleteImportProfile(ctx.Request.Context(), id, version); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	respond(ctx, http.StatusOK, nil, gin.H{
+		"message": "import profile deleted successfully",
+	})
+}
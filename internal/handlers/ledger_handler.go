@@ -0,0 +1,419 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ChixXx1/expense-tracker/internal/database"
+	"github.com/ChixXx1/expense-tracker/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// LedgerHeader выбирает книгу запроса; без него используется личная книга пользователя
+const LedgerHeader = "X-Ledger-ID"
+
+// inviteTTL - сколько действует код приглашения
+const inviteTTL = 7 * 24 * time.Hour
+
+// ledgerIDKey - ключ gin.Context, под которым RequireLedger кладет выбранную книгу
+const ledgerIDKey = "ledger_id"
+
+// ledgerRoleKey - роль пользователя в выбранной книге; readOnlyKey - метка ReadOnly
+const (
+	ledgerRoleKey = "ledger_role"
+	readOnlyKey   = "read_only"
+)
+
+type LedgerRequest struct {
+	Name string `json:"name"`
+}
+
+type InviteRequest struct {
+	Role string `json:"role"`
+}
+
+type JoinRequest struct {
+	Code string `json:"code"`
+}
+
+type MemberRequest struct {
+	Role string `json:"role"`
+}
+
+type LedgerHandler struct {
+	storage database.Storage
+}
+
+func NewLedgerHandler(storage database.Storage) *LedgerHandler {
+	return &LedgerHandler{
+		storage: storage,
+	}
+}
+
+func (h *LedgerHandler) GetLedgers(ctx *gin.Context) {
+	ledgers, err := h.storage.GetLedgers(ctx.Request.Context(), currentUserID(ctx))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	respondList(ctx, http.StatusOK, ledgers, nil)
+}
+
+func (h *LedgerHandler) GetLedgerByID(ctx *gin.Context) {
+	ledger, ok := h.memberLedger(ctx, models.LedgerRoleViewer)
+	if !ok {
+		return
+	}
+
+	if notModified(ctx, ledger.Version) {
+		return
+	}
+
+	setETag(ctx, ledger.Version)
+	respond(ctx, http.StatusOK, ledger, nil)
+}
+
+// CreateLedger создает общую книгу; создатель становится ее владельцем
+func (h *LedgerHandler) CreateLedger(ctx *gin.Context) {
+	var request LedgerRequest
+
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		invalidBody(ctx)
+		return
+	}
+
+	ledger := models.Ledger{
+		Name:    request.Name,
+		Members: []models.LedgerMember{{UserID: currentUserID(ctx), Role: models.LedgerRoleOwner, JoinedAt: time.Now()}},
+	}
+
+	if err := h.storage.CreateLedger(ctx.Request.Context(), &ledger); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, ledger.Version)
+	respond(ctx, http.StatusCreated, ledger, gin.H{
+		"message": "ledger created successfully",
+	})
+}
+
+func (h *LedgerHandler) UpdateLedger(ctx *gin.Context) {
+	ledger, ok := h.memberLedger(ctx, models.LedgerRoleOwner)
+	if !ok {
+		return
+	}
+
+	version, ok := expectedVersion(ctx, ledger.Version)
+	if !ok {
+		return
+	}
+
+	var request LedgerRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		invalidBody(ctx)
+		return
+	}
+
+	ledger.Name = request.Name
+	ledger.Version = version
+	h.saveLedger(ctx, ledger, "ledger updated successfully")
+}
+
+// DeleteLedger удаляет общую книгу вместе со всеми ее данными; личную удалить нельзя
+func (h *LedgerHandler) DeleteLedger(ctx *gin.Context) {
+	ledger, ok := h.memberLedger(ctx, models.LedgerRoleOwner)
+	if !ok {
+		return
+	}
+
+	version, ok := expectedVersion(ctx, ledger.Version)
+	if !ok {
+		return
+	}
+
+	if err := h.storage.DeleteLedger(ctx.Request.Context(), ledger.ID, version); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	respond(ctx, http.StatusOK, nil, gin.H{
+		"message": "ledger deleted successfully",
+	})
+}
+
+// CreateInvite выдает одноразовый код, по которому другой пользователь вступит в книгу с указанной ролью
+func (h *LedgerHandler) CreateInvite(ctx *gin.Context) {
+	ledger, ok := h.memberLedger(ctx, models.LedgerRoleOwner)
+	if !ok {
+		return
+	}
+
+	var request InviteRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		invalidBody(ctx)
+		return
+	}
+
+	// владельцы назначаются только явно, через смену роли участника
+	if request.Role != models.LedgerRoleEditor && request.Role != models.LedgerRoleViewer {
+		respondError(ctx, &database.ValidationError{Field: "role", Message: "role must be 'editor' or 'viewer'"})
+		return
+	}
+
+	code, err := inviteCode()
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	now := time.Now()
+	invite := models.LedgerInvite{
+		Code:      code,
+		LedgerID:  ledger.ID,
+		Role:      request.Role,
+		CreatedBy: currentUserID(ctx),
+		CreatedAt: now,
+		ExpiresAt: now.Add(inviteTTL),
+	}
+
+	if err := h.storage.CreateLedgerInvite(ctx.Request.Context(), &invite); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	respond(ctx, http.StatusCreated, invite, gin.H{
+		"message": "invite created successfully",
+	})
+}
+
+func (h *LedgerHandler) JoinLedger(ctx *gin.Context) {
+	var request JoinRequest
+
+	if err := ctx.ShouldBindJSON(&request); err != nil || request.Code == "" {
+		invalidBody(ctx)
+		return
+	}
+
+	ledger, err := h.storage.AcceptLedgerInvite(ctx.Request.Context(), request.Code, currentUserID(ctx))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, ledger.Version)
+	respond(ctx, http.StatusOK, ledger, gin.H{
+		"message": "joined ledger successfully",
+	})
+}
+
+// UpdateMember меняет роль участника; последнего владельца понизить нельзя
+func (h *LedgerHandler) UpdateMember(ctx *gin.Context) {
+	ledger, ok := h.memberLedger(ctx, models.LedgerRoleOwner)
+	if !ok {
+		return
+	}
+
+	userID, ok := memberParam(ctx, ledger)
+	if !ok {
+		return
+	}
+
+	var request MemberRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		invalidBody(ctx)
+		return
+	}
+
+	if !models.ValidLedgerRole(request.Role) {
+		respondError(ctx, &database.ValidationError{Field: "role", Message: "role must be 'owner', 'editor' or 'viewer'"})
+		return
+	}
+	// у личной книги всегда один владелец - тот, кому она выдана при регистрации
+	if ledger.Personal && request.Role == models.LedgerRoleOwner {
+		respondProblem(ctx, http.StatusConflict, CodeConflict, "personal ledger has a single owner", nil, nil)
+		return
+	}
+
+	ledger, err := h.storage.UpdateLedgerMember(ctx.Request.Context(), ledger.ID, userID, request.Role)
+	h.respondMembers(ctx, ledger, err, "member updated successfully")
+}
+
+// RemoveMember исключает участника; участник может и сам выйти из книги, кроме последнего владельца
+func (h *LedgerHandler) RemoveMember(ctx *gin.Context) {
+	ledger, ok := h.memberLedger(ctx, models.LedgerRoleViewer)
+	if !ok {
+		return
+	}
+
+	userID, ok := memberParam(ctx, ledger)
+	if !ok {
+		return
+	}
+
+	if userID != currentUserID(ctx) && ledger.Role(currentUserID(ctx)) != models.LedgerRoleOwner {
+		forbidden(ctx, "only ledger owners can remove other members")
+		return
+	}
+
+	ledger, err := h.storage.RemoveLedgerMember(ctx.Request.Context(), ledger.ID, userID)
+	h.respondMembers(ctx, ledger, err, "member removed successfully")
+}
+
+// RequireLedger выбирает книгу запроса по заголовку X-Ledger-ID (по умолчанию - личную),
+// проверяет членство и ограничивает ею хранилище. Изменять данные могут owner и editor, viewer только читает.
+func (h *LedgerHandler) RequireLedger(ctx *gin.Context) {
+	userID := currentUserID(ctx)
+
+	var ledger *models.Ledger
+	if header := ctx.GetHeader(LedgerHeader); header != "" {
+		id, err := strconv.Atoi(header)
+		if err != nil || id <= 0 {
+			badRequest(ctx, LedgerHeader+" must be a positive integer")
+			ctx.Abort()
+			return
+		}
+
+		ledger, err = h.storage.GetLedgerByID(ctx.Request.Context(), id)
+		if err != nil && !errors.Is(err, database.ErrNotFound) {
+			respondError(ctx, err)
+			ctx.Abort()
+			return
+		}
+	} else {
+		ledgers, err := h.storage.GetLedgers(ctx.Request.Context(), userID)
+		if err != nil {
+			respondError(ctx, err)
+			ctx.Abort()
+			return
+		}
+		for i := range ledgers {
+			if ledgers[i].Personal && ledgers[i].Role(userID) == models.LedgerRoleOwner {
+				ledger = &ledgers[i]
+				break
+			}
+		}
+	}
+
+	// чужая книга неотличима от несуществующей
+	if ledger == nil || ledger.Role(userID) == "" {
+		respondProblem(ctx, http.StatusNotFound, CodeNotFound, "ledger not found", nil, nil)
+		ctx.Abort()
+		return
+	}
+
+	role := ledger.Role(userID)
+	readOnly := ctx.Request.Method == http.MethodGet || ctx.Request.Method == http.MethodHead || ctx.GetBool(readOnlyKey)
+	if !readOnly && !models.RoleAtLeast(role, models.LedgerRoleEditor) {
+		forbidden(ctx, "ledger role '"+role+"' does not allow changes")
+		ctx.Abort()
+		return
+	}
+
+	ctx.Set(ledgerIDKey, ledger.ID)
+	ctx.Set(ledgerRoleKey, role)
+	ctx.Request = ctx.Request.WithContext(database.WithLedger(ctx.Request.Context(), ledger.ID, userID))
+	ctx.Next()
+}
+
+// ReadOnly помечает маршрут, который ничего не меняет, хотя и не GET: RequireLedger пустит на него и viewer
+func ReadOnly(ctx *gin.Context) {
+	ctx.Set(readOnlyKey, true)
+	ctx.Next()
+}
+
+// requireEditor для ReadOnly-маршрута, который меняет данные по параметру запроса,
+// проверяет роль сам и отвечает 403, если она ниже editor
+func requireEditor(ctx *gin.Context) bool {
+	role := ctx.GetString(ledgerRoleKey)
+	if !models.RoleAtLeast(role, models.LedgerRoleEditor) {
+		forbidden(ctx, "ledger role '"+role+"' does not allow changes")
+		return false
+	}
+	return true
+}
+
+// memberLedger загружает книгу из пути и проверяет, что у пользователя в ней роль не ниже required
+func (h *LedgerHandler) memberLedger(ctx *gin.Context, required string) (*models.Ledger, bool) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		badRequest(ctx, "invalid ledger ID")
+		return nil, false
+	}
+
+	ledger, err := h.storage.GetLedgerByID(ctx.Request.Context(), id)
+	if err != nil {
+		respondError(ctx, err)
+		return nil, false
+	}
+
+	role := ledger.Role(currentUserID(ctx))
+	if role == "" {
+		respondProblem(ctx, http.StatusNotFound, CodeNotFound, "ledger not found", nil, nil)
+		return nil, false
+	}
+	if !models.RoleAtLeast(role, required) {
+		forbidden(ctx, "ledger role '"+role+"' does not allow this action")
+		return nil, false
+	}
+
+	return ledger, true
+}
+
+func (h *LedgerHandler) saveLedger(ctx *gin.Context, ledger *models.Ledger, message string) {
+	if err := h.storage.UpdateLedger(ctx.Request.Context(), ledger); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, ledger.Version)
+	respond(ctx, http.StatusOK, ledger, gin.H{
+		"message": message,
+	})
+}
+
+// respondMembers отвечает книгой после смены состава участников
+func (h *LedgerHandler) respondMembers(ctx *gin.Context, ledger *models.Ledger, err error, message string) {
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, ledger.Version)
+	respond(ctx, http.StatusOK, ledger, gin.H{
+		"message": message,
+	})
+}
+
+func memberParam(ctx *gin.Context, ledger *models.Ledger) (int, bool) {
+	userID, err := strconv.Atoi(ctx.Param("user_id"))
+	if err != nil {
+		badRequest(ctx, "invalid user ID")
+		return 0, false
+	}
+
+	if ledger.Role(userID) == "" {
+		respondProblem(ctx, http.StatusNotFound, CodeNotFound, "member not found", nil, nil)
+		return 0, false
+	}
+
+	return userID, true
+}
+
+func inviteCode() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// currentLedgerID - книга, выбранная RequireLedger
+func currentLedgerID(ctx *gin.Context) int {
+	return ctx.GetInt(ledgerIDKey)
+}
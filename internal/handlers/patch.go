@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+)
+
+const mergePatchContentType = "application/merge-patch+json"
+
+// readMergePatch читает тело PATCH-запроса; кроме merge-patch+json принимается обычный application/json
+func readMergePatch(ctx *gin.Context) ([]byte, bool) {
+	if contentType := ctx.ContentType(); contentType != mergePatchContentType && contentType != "application/json" {
+		respondProblem(ctx, http.StatusUnsupportedMediaType, CodeUnsupportedMedia, "use Content-Type: "+mergePatchContentType, nil, nil)
+		return nil, false
+	}
+
+	patch, err := io.ReadAll(ctx.Request.Body)
+	if err != nil || len(bytes.TrimSpace(patch)) == 0 {
+		invalidBody(ctx)
+		return nil, false
+	}
+
+	return patch, true
+}
+
+// applyMergePatch применяет JSON Merge Patch (RFC 7396) к target: поля со значением null
+// сбрасываются в нулевое значение, вложенные объекты сливаются рекурсивно.
+// Неизвестные поля считаются ошибкой, чтобы опечатка в имени не терялась молча.
+func applyMergePatch(target any, patch []byte) error {
+	var patchDoc any
+	if err := json.Unmarshal(patch, &patchDoc); err != nil {
+		return errors.New("invalid JSON in patch")
+	}
+	if _, ok := patchDoc.(map[string]any); !ok {
+		return errors.New("patch must be a JSON object")
+	}
+
+	original, err := json.Marshal(target)
+	if err != nil {
+		return err
+	}
+
+	var doc any
+	if err := json.Unmarshal(original, &doc); err != nil {
+		return err
+	}
+
+	merged, err := json.Marshal(mergeValue(doc, patchDoc))
+	if err != nil {
+		return err
+	}
+
+	value := reflect.ValueOf(target).Elem()
+	value.Set(reflect.Zero(value.Type()))
+
+	decoder := json.NewDecoder(bytes.NewReader(merged))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(target)
+}
+
+func mergeValue(target, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = map[string]any{}
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = mergeValue(targetObject[key], value)
+	}
+
+	return targetObject
+}
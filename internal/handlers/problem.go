@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/ChixXx1/expense-tracker/internal/auth"
+	"github.com/ChixXx1/expense-tracker/internal/database"
+	"github.com/ChixXx1/expense-tracker/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+const problemContentType = "application/problem+json"
+
+// statusClientClosedRequest - нестандартный статус nginx для запросов, брошенных клиентом; попадает только в логи
+const statusClientClosedRequest = 499
+
+// Машиночитаемые коды ошибок, поле code в problem+json
+const (
+	CodeBadRequest         = "bad_request"
+	CodeUnauthorized       = "unauthorized"
+	CodeForbidden          = "forbidden"
+	CodeInvalidBody        = "invalid_body"
+	CodeUnsupportedMedia   = "unsupported_media_type"
+	CodeValidationFailed   = "validation_failed"
+	CodeNotFound           = "not_found"
+	CodeConflict           = "conflict"
+	CodePreconditionFailed = "precondition_failed"
+	CodePossibleDuplicate  = "possible_duplicate"
+	CodeInternal           = "internal_error"
+	CodeTimeout            = "timeout"
+
+	CodeIdempotencyKeyReused     = "idempotency_key_reused"
+	CodeIdempotencyKeyInProgress = "idempotency_key_in_progress"
+)
+
+type FieldProblem struct {
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// respondProblem пишет тело ошибки по RFC 7807; extra добавляется как поля-расширения
+func respondProblem(ctx *gin.Context, status int, code, detail string, fields []FieldProblem, extra gin.H) {
+	body := gin.H{
+		"type":     "about:blank",
+		"title":    http.StatusText(status),
+		"status":   status,
+		"code":     code,
+		"instance": ctx.Request.URL.Path,
+	}
+	if detail != "" {
+		body["detail"] = detail
+	}
+	if len(fields) > 0 {
+		body["errors"] = fields
+	}
+	for key, value := range extra {
+		body[key] = value
+	}
+
+	// gin не перезаписывает уже выставленный Content-Type
+	ctx.Header("Content-Type", problemContentType)
+	ctx.JSON(status, body)
+}
+
+func badRequest(ctx *gin.Context, detail string) {
+	respondProblem(ctx, http.StatusBadRequest, CodeBadRequest, detail, nil, nil)
+}
+
+func forbidden(ctx *gin.Context, detail string) {
+	respondProblem(ctx, http.StatusForbidden, CodeForbidden, detail, nil, nil)
+}
+
+func invalidBody(ctx *gin.Context) {
+	respondProblem(ctx, http.StatusBadRequest, CodeInvalidBody, "invalid request body", nil, nil)
+}
+
+// respondError - единое место, где ошибки хранилища и моделей превращаются в HTTP-статусы
+func respondError(ctx *gin.Context, err error) {
+	var validationErr *database.ValidationError
+	var fieldErr *models.FieldError
+
+	switch {
+	case errors.As(err, &validationErr):
+		respondProblem(ctx, http.StatusUnprocessableEntity, CodeValidationFailed, err.Error(),
+			[]FieldProblem{{Field: validationErr.Field, Message: validationErr.Message}}, nil)
+	case errors.As(err, &fieldErr):
+		respondProblem(ctx, http.StatusUnprocessableEntity, CodeValidationFailed, err.Error(),
+			[]FieldProblem{{Field: fieldErr.Field, Message: fieldErr.Message}}, nil)
+	case errors.Is(err, database.ErrValidation):
+		respondProblem(ctx, http.StatusUnprocessableEntity, CodeValidationFailed, err.Error(), nil, nil)
+	case errors.Is(err, database.ErrNotFound):
+		respondProblem(ctx, http.StatusNotFound, CodeNotFound, err.Error(), nil, nil)
+	case errors.Is(err, database.ErrPreconditionFailed):
+		respondProblem(ctx, http.StatusPreconditionFailed, CodePreconditionFailed, err.Error(), nil, nil)
+	case errors.Is(err, database.ErrConflict):
+		respondProblem(ctx, http.StatusConflict, CodeConflict, err.Error(), nil, nil)
+	case errors.Is(err, auth.ErrInvalidCredentials), errors.Is(err, auth.ErrInvalidToken):
+		unauthorized(ctx, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		respondProblem(ctx, http.StatusServiceUnavailable, CodeTimeout, "request timed out", nil, nil)
+	case errors.Is(err, context.Canceled):
+		// клиент уже отключился, отвечать некому
+		ctx.AbortWithStatus(statusClientClosedRequest)
+	default:
+		log.Printf("%s %s: %v", ctx.Request.Method, ctx.Request.URL.Path, err)
+		respondProblem(ctx, http.StatusInternalServerError, CodeInternal, "internal server error", nil, nil)
+	}
+}
+
+func NoRoute(ctx *gin.Context) {
+	respondProblem(ctx, http.StatusNotFound, CodeNotFound, "route not found", nil, nil)
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ChixXx1/expense-tracker/internal/database"
@@ -24,46 +25,34 @@ func (h *ReportHandler) GetFinancialSummary(ctx *gin.Context) {
 	endDateStr := ctx.Query("end_date")
 
 	if startDateStr == "" || endDateStr == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": "start_date and end_date are required",
-		})
+		badRequest(ctx, "start_date and end_date are required")
 		return
 	}
 
 	startDate, err := time.Parse("2006-01-02", startDateStr)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid start_date format, use YYYY-MM-DD",
-		})
+		badRequest(ctx, "invalid start_date format, use YYYY-MM-DD")
 		return
 	}
 
 	endDate, err := time.Parse("2006-01-02", endDateStr)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid end_date format, use YYYY-MM-DD",
-		})
+		badRequest(ctx, "invalid end_date format, use YYYY-MM-DD")
 		return
 	}
 
 	if startDate.After(endDate) {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": "start_date must be before end_date",
-		})
+		badRequest(ctx, "start_date must be before end_date")
 		return
 	}
 
-	summary, err := h.storage.GetFinancialSummary(startDate, endDate)
+	summary, err := h.storage.GetFinancialSummary(ctx.Request.Context(), startDate, endDate)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to get financial summary",
-		})
+		respondError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{
-		"summary": summary,
-	})
+	respond(ctx, http.StatusOK, summary, nil)
 }
 
 func (h *ReportHandler) GetCategorySummary(ctx *gin.Context) {
@@ -71,68 +60,108 @@ func (h *ReportHandler) GetCategorySummary(ctx *gin.Context) {
 	endDateStr := ctx.Query("end_date")
 
 	if startDateStr == "" || endDateStr == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": "start_date and end_date are required",
-		})
+		badRequest(ctx, "start_date and end_date are required")
 		return
 	}
 
 	startDate, err := time.Parse("2006-01-02", startDateStr)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid start_date format, use YYYY-MM-DD",
-		})
+		badRequest(ctx, "invalid start_date format, use YYYY-MM-DD")
 		return
 	}
 
 	endDate, err := time.Parse("2006-01-02", endDateStr)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid end_date format, use YYYY-MM-DD",
-		})
+		badRequest(ctx, "invalid end_date format, use YYYY-MM-DD")
 		return
 	}
 
 	if startDate.After(endDate) {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": "start_date must be before end_date",
-		})
+		badRequest(ctx, "start_date must be before end_date")
 		return
 	}
 
-	summaries, err := h.storage.GetCategorySummary(startDate, endDate)
+	summaries, err := h.storage.GetCategorySummary(ctx.Request.Context(), startDate, endDate)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to get category summary",
-		})
+		respondError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{
-		"category_summary": summaries,
-		"count":            len(summaries),
-	})
+	respondList(ctx, http.StatusOK, summaries, nil)
 }
 
 func (h *ReportHandler) GetBudgetReport(ctx *gin.Context) {
 	idParam := ctx.Param("id")
 	id, err := strconv.Atoi(idParam)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid budget ID",
-		})
+		badRequest(ctx, "invalid budget ID")
 		return
 	}
 
-	report, err := h.storage.GetBudgetReport(id)
+	report, err := h.storage.GetBudgetReport(ctx.Request.Context(), id)
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{
-			"error": "budget report not found",
-		})
+		respondError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{
-		"budget_report": report,
-	})
+	respond(ctx, http.StatusOK, report, nil)
+}
+
+func (h *ReportHandler) GetAnnualReport(ctx *gin.Context) {
+	yearParam := ctx.Param("year")
+	year, err := strconv.Atoi(yearParam)
+	if err != nil || year < 1900 || year > 9999 {
+		badRequest(ctx, "invalid year, use YYYY")
+		return
+	}
+
+	report, err := h.storage.GetAnnualReport(ctx.Request.Context(), year)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	respond(ctx, http.StatusOK, report, nil)
+}
+
+func (h *ReportHandler) GetPivot(ctx *gin.Context) {
+	filters, err := parseTransactionFilters(ctx)
+	if err != nil {
+		badRequest(ctx, err.Error())
+		return
+	}
+
+	// Пагинация к сводной таблице не применяется
+	filters.Limit = nil
+	filters.Offset = nil
+
+	query := database.PivotQuery{
+		Rows:     splitQueryList(ctx.Query("rows")),
+		Columns:  splitQueryList(ctx.Query("columns")),
+		Measures: splitQueryList(ctx.DefaultQuery("measures", "sum")),
+		Filters:  filters,
+	}
+
+	if err := query.Validate(); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	pivot, err := h.storage.GetPivot(ctx.Request.Context(), query)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	respond(ctx, http.StatusOK, pivot, nil)
+}
+
+func splitQueryList(value string) []string {
+	var result []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			result = append(result, part)
+		}
+	}
+	return result
 }
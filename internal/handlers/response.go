@@ -0,0 +1,30 @@
+package handlers
+
+import "github.com/gin-gonic/gin"
+
+// Response - общий конверт успешных ответов API:
+// полезная нагрузка всегда в data, служебные поля (count, message и т.п.) в meta
+type Response struct {
+	Data any   `json:"data"`
+	Meta gin.H `json:"meta,omitempty"`
+}
+
+func respond(ctx *gin.Context, status int, data any, meta gin.H) {
+	ctx.JSON(status, Response{
+		Data: data,
+		Meta: meta,
+	})
+}
+
+// respondList добавляет в meta количество элементов
+func respondList[T any](ctx *gin.Context, status int, items []T, meta gin.H) {
+	if items == nil {
+		items = []T{}
+	}
+	if meta == nil {
+		meta = gin.H{}
+	}
+	meta["count"] = len(items)
+
+	respond(ctx, status, items, meta)
+}
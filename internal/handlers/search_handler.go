@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ChixXx1/expense-tracker/internal/search"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+type SearchHandler struct {
+	index *search.Index
+}
+
+func NewSearchHandler(index *search.Index) *SearchHandler {
+	return &SearchHandler{
+		index: index,
+	}
+}
+
+func (h *SearchHandler) Search(ctx *gin.Context) {
+	query := strings.TrimSpace(ctx.Query("q"))
+	if query == "" {
+		badRequest(ctx, "q is required")
+		return
+	}
+
+	limit := defaultSearchLimit
+	if limitStr := ctx.Query("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 || limit > maxSearchLimit {
+			badRequest(ctx, "limit must be between 1 and 100")
+			return
+		}
+	}
+
+	hits, total := h.index.Search(currentLedgerID(ctx), query, limit)
+
+	respondList(ctx, http.StatusOK, hits, gin.H{
+		"query": query,
+		"total": total,
+	})
+}
@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ChixXx1/expense-tracker/internal/database"
@@ -10,6 +13,33 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+type MergeDuplicatesRequest struct {
+	KeepID   int `json:"keep_id"`
+	RemoveID int `json:"remove_id"`
+}
+
+type DismissDuplicateRequest struct {
+	FirstID  int `json:"first_id"`
+	SecondID int `json:"second_id"`
+}
+
+// BulkRequest - пакет операций над транзакциями, выполняется целиком или не выполняется вовсе
+type BulkRequest struct {
+	Operations []BulkOperationRequest `json:"operations"`
+}
+
+// BulkOperationRequest - операция пакета; filter задается в том же формате, что и query-параметры GET /transactions
+type BulkOperationRequest struct {
+	Op          string              `json:"op"`
+	ID          int                 `json:"id,omitempty"`
+	Version     int                 `json:"version,omitempty"`
+	Filter      map[string]string   `json:"filter,omitempty"`
+	Transaction *models.Transaction `json:"transaction,omitempty"`
+	Set         *database.BulkSet   `json:"set,omitempty"`
+}
+
+const maxBulkOperations = 1000
+
 type TransactionHandler struct {
 	storage database.Storage
 }
@@ -21,113 +51,32 @@ func NewTransactionHandler(storage database.Storage) *TransactionHandler {
 }
 
 func (h *TransactionHandler) GetTransactions(ctx *gin.Context) {
-	filters := database.TransactionFilters{}
-
-	if startDateStr := ctx.Query("start_date"); startDateStr != "" {
-		startDate, err := time.Parse("2006-01-02", startDateStr)
-		if err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{
-				"error": "invalid start_date format, use YYYY-MM-DD",
-			})
-			return
-		}
-		filters.StartDate = &startDate
-	}
-
-	if endDateStr := ctx.Query("end_date"); endDateStr != "" {
-		endDate, err := time.Parse("2006-01-02", endDateStr)
-		if err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{
-				"error": "invalid end_date format, use YYYY-MM-DD",
-			})
-			return
-		}
-		filters.EndDate = &endDate
-	}
-
-	if filters.StartDate != nil && filters.EndDate != nil {
-		if filters.StartDate.After(*filters.EndDate) {
-			ctx.JSON(http.StatusBadRequest, gin.H{
-				"error": "start_date must be before end_date",
-			})
-			return
-		}
-	}
-
-	if categoryIDStr := ctx.Query("category_id"); categoryIDStr != "" {
-		categoryID, err := strconv.Atoi(categoryIDStr)
-		if err != nil || categoryID <= 0 {
-			ctx.JSON(http.StatusBadRequest, gin.H{
-				"error": "category_id must be a positive integer",
-			})
-			return
-		}
-		filters.CategoryID = &categoryID
-	}
-
-	if txTypeStr := ctx.Query("type"); txTypeStr != "" {
-		if txTypeStr != models.TransactionTypeIncome && txTypeStr != models.TransactionTypeExpense {
-			ctx.JSON(http.StatusBadRequest, gin.H{
-				"error": "type must be `income` or `expense`",
-			})
-			return
-		}
-		filters.Type = &txTypeStr
-	}
-
-	if paymentMethod := ctx.Query("payment_method"); paymentMethod != "" {
-		validMetods := map[string]bool{
-			models.PaymentMethodCash:     true,
-			models.PaymentMethodCard:     true,
-			models.PaymentMethodTransfer: true,
-		}
-		if !validMetods[paymentMethod] {
-			ctx.JSON(http.StatusBadRequest, gin.H{
-				"error": "payment_method must be a `cash`, `card` or `transfer`",
-			})
-			return
-		}
-		filters.PaymentMethod = &paymentMethod
-	}
-
-	if limitStr := ctx.Query("limit"); limitStr != "" {
-		limit, err := strconv.Atoi(limitStr)
-		if err != nil || limit <= 0 {
-			ctx.JSON(http.StatusBadRequest, gin.H{
-				"error": "limit must be a positive integer",
-			})
-			return
-		}
-		filters.Limit = &limit
-	}
-
-	if offsetStr := ctx.Query("offset"); offsetStr != "" {
-		offset, err := strconv.Atoi(offsetStr)
-		if err != nil || offset < 0 {
-			ctx.JSON(http.StatusBadRequest, gin.H{
-				"error": "offset must be a positive",
-			})
-			return
-		}
-		filters.Offset = &offset
+	filters, err := parseTransactionFilters(ctx)
+	if err != nil {
+		badRequest(ctx, err.Error())
+		return
 	}
 
-	transactions, err := h.storage.GetTransactions(filters)
+	page, err := h.storage.GetTransactions(ctx.Request.Context(), filters)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to get transactions",
-		})
+		respondError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{
-		"transactions": transactions,
-		"count":        len(transactions),
+	respondList(ctx, http.StatusOK, page.Transactions, gin.H{
+		"total":       page.Total,
+		"sum":         page.Sum,
+		"income":      page.Income,
+		"expense":     page.Expense,
+		"next_cursor": page.NextCursor,
+		"prev_cursor": page.PrevCursor,
 		"filters_applied": gin.H{
 			"has_start_date": filters.StartDate != nil,
 			"has_end_date":   filters.EndDate != nil,
-			"has_category":   filters.CategoryID != nil,
-			"has_type":       filters.Type != nil,
+			"has_category":   filters.CategoryID.IsSet() || filters.Uncategorized,
+			"has_type":       filters.Type.IsSet(),
+			"has_amount":     filters.MinAmount != nil || filters.MaxAmount != nil,
+			"has_search":     filters.Search != "",
 		},
 	})
 }
@@ -137,39 +86,34 @@ func (h *TransactionHandler) GetTransactionByID(ctx *gin.Context) {
 
 	id, err := strconv.Atoi(idParam)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid transaction ID",
-		})
+		badRequest(ctx, "invalid transaction ID")
 		return
 	}
 
-	transaction, err := h.storage.GetTransactionByID(id)
+	transaction, err := h.storage.GetTransactionByID(ctx.Request.Context(), id)
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{
-			"error": "failed to get transaction by ID",
-		})
+		respondError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{
-		"transaction": transaction,
-	})
+	if notModified(ctx, transaction.Version) {
+		return
+	}
+
+	setETag(ctx, transaction.Version)
+	respond(ctx, http.StatusOK, transaction, nil)
 }
 
 func (h *TransactionHandler) CreateTransaction(ctx *gin.Context) {
 	var transaction models.Transaction
 
 	if err := ctx.ShouldBindJSON(&transaction); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid request body",
-		})
+		invalidBody(ctx)
 		return
 	}
 
 	if err := transaction.Validate(); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		respondError(ctx, err)
 		return
 	}
 
@@ -177,16 +121,30 @@ func (h *TransactionHandler) CreateTransaction(ctx *gin.Context) {
 		transaction.CreatedAt = time.Now()
 	}
 
-	if err := h.storage.CreateTransaction(&transaction); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to create transaction: " + err.Error(),
-		})
+	if ctx.Query("force") != "true" {
+		duplicates, err := h.storage.FindDuplicates(ctx.Request.Context(), transaction)
+		if err != nil {
+			respondError(ctx, err)
+			return
+		}
+
+		if len(duplicates) > 0 {
+			respondProblem(ctx, http.StatusConflict, CodePossibleDuplicate,
+				"possible duplicate transaction, use force=true to create anyway", nil, gin.H{
+					"duplicates": duplicates,
+				})
+			return
+		}
+	}
+
+	if err := h.storage.CreateTransaction(ctx.Request.Context(), &transaction); err != nil {
+		respondError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusCreated, gin.H{
-		"message":     "transaction created successfully",
-		"transaction": transaction,
+	setETag(ctx, transaction.Version)
+	respond(ctx, http.StatusCreated, transaction, gin.H{
+		"message": "transaction created successfully",
 	})
 }
 
@@ -194,39 +152,93 @@ func (h *TransactionHandler) UpdateTransaction(ctx *gin.Context) {
 	idParam := ctx.Param("id")
 	id, err := strconv.Atoi(idParam)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid transaction ID",
-		})
+		badRequest(ctx, "invalid transaction ID")
+		return
+	}
+
+	existing, err := h.storage.GetTransactionByID(ctx.Request.Context(), id)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	version, ok := expectedVersion(ctx, existing.Version)
+	if !ok {
 		return
 	}
 
 	var transaction models.Transaction
 	if err := ctx.ShouldBindJSON(&transaction); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid request body",
-		})
+		invalidBody(ctx)
 		return
 	}
 
 	transaction.ID = id
+	transaction.Version = version
 
 	if err := transaction.Validate(); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		respondError(ctx, err)
 		return
 	}
 
-	if err := h.storage.UpdateTransaction(&transaction); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to update transaction: " + err.Error(),
-		})
+	if err := h.storage.UpdateTransaction(ctx.Request.Context(), &transaction); err != nil {
+		respondError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{
-		"message":     "transaction updated successfully",
-		"transaction": transaction,
+	setETag(ctx, transaction.Version)
+	respond(ctx, http.StatusOK, transaction, gin.H{
+		"message": "transaction updated successfully",
+	})
+}
+
+func (h *TransactionHandler) PatchTransaction(ctx *gin.Context) {
+	idParam := ctx.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		badRequest(ctx, "invalid transaction ID")
+		return
+	}
+
+	existing, err := h.storage.GetTransactionByID(ctx.Request.Context(), id)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	version, ok := expectedVersion(ctx, existing.Version)
+	if !ok {
+		return
+	}
+
+	patch, ok := readMergePatch(ctx)
+	if !ok {
+		return
+	}
+
+	transaction := *existing
+	if err := applyMergePatch(&transaction, patch); err != nil {
+		badRequest(ctx, err.Error())
+		return
+	}
+
+	transaction.ID = existing.ID
+	transaction.Version = version
+	transaction.CreatedAt = existing.CreatedAt
+
+	if err := transaction.Validate(); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	if err := h.storage.UpdateTransaction(ctx.Request.Context(), &transaction); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, transaction.Version)
+	respond(ctx, http.StatusOK, transaction, gin.H{
+		"message": "transaction updated successfully",
 	})
 }
 
@@ -234,20 +246,260 @@ func (h *TransactionHandler) DeleteTransaction(ctx *gin.Context) {
 	idParam := ctx.Param("id")
 	id, err := strconv.Atoi(idParam)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid transaction ID",
-		})
+		badRequest(ctx, "invalid transaction ID")
+		return
+	}
+
+	existing, err := h.storage.GetTransactionByID(ctx.Request.Context(), id)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	version, ok := expectedVersion(ctx, existing.Version)
+	if !ok {
 		return
 	}
 
-	if err := h.storage.DeleteTransaction(id); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to delete transaction: " + err.Error(),
-		})
+	if err := h.storage.DeleteTransaction(ctx.Request.Context(), id, version); err != nil {
+		respondError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{
+	respond(ctx, http.StatusOK, nil, gin.H{
 		"message": "transaction deleted successfully",
 	})
 }
+
+func (h *TransactionHandler) GetDuplicates(ctx *gin.Context) {
+	pairs, err := h.storage.GetDuplicatePairs(ctx.Request.Context())
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	respondList(ctx, http.StatusOK, pairs, nil)
+}
+
+func (h *TransactionHandler) MergeDuplicates(ctx *gin.Context) {
+	var request MergeDuplicatesRequest
+
+	if err := ctx.ShouldBindJSON(&request); err != nil || request.KeepID <= 0 || request.RemoveID <= 0 {
+		badRequest(ctx, "keep_id and remove_id must be positive integers")
+		return
+	}
+
+	transaction, err := h.storage.MergeDuplicates(ctx.Request.Context(), request.KeepID, request.RemoveID)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, transaction.Version)
+	respond(ctx, http.StatusOK, transaction, gin.H{
+		"message": "transactions merged successfully",
+	})
+}
+
+func (h *TransactionHandler) DismissDuplicate(ctx *gin.Context) {
+	var request DismissDuplicateRequest
+
+	if err := ctx.ShouldBindJSON(&request); err != nil || request.FirstID <= 0 || request.SecondID <= 0 {
+		badRequest(ctx, "first_id and second_id must be positive integers")
+		return
+	}
+
+	if err := h.storage.DismissDuplicate(ctx.Request.Context(), request.FirstID, request.SecondID); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	respond(ctx, http.StatusOK, nil, gin.H{
+		"message": "duplicate dismissed successfully",
+	})
+}
+
+func (h *TransactionHandler) Bulk(ctx *gin.Context) {
+	var request BulkRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		invalidBody(ctx)
+		return
+	}
+
+	if len(request.Operations) == 0 || len(request.Operations) > maxBulkOperations {
+		badRequest(ctx, "operations must contain from 1 to 1000 items")
+		return
+	}
+
+	operations := make([]database.BulkOperation, len(request.Operations))
+	for i, item := range request.Operations {
+		operations[i] = database.BulkOperation{
+			Op:          item.Op,
+			ID:          item.ID,
+			Version:     item.Version,
+			Transaction: item.Transaction,
+			Set:         item.Set,
+		}
+
+		if item.Filter != nil {
+			filters, err := parseFilterValues(func(name string) string { return item.Filter[name] })
+			if err != nil {
+				badRequest(ctx, fmt.Sprintf("operation %d: %v", i+1, err))
+				return
+			}
+			operations[i].Filter = &filters
+		}
+	}
+
+	var results []models.BulkResult
+	err := h.storage.WithTx(ctx.Request.Context(), func(tx database.Storage) error {
+		var err error
+		results, err = database.ApplyBulk(ctx.Request.Context(), tx, operations)
+		return err
+	})
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	respondList(ctx, http.StatusOK, results, nil)
+}
+
+func parseTransactionFilters(ctx *gin.Context) (database.TransactionFilters, error) {
+	return parseFilterValues(ctx.Query)
+}
+
+// parseFilterValues разбирает фильтры в формате query-строки; query возвращает значение параметра или ""
+func parseFilterValues(query func(string) string) (database.TransactionFilters, error) {
+	filters := database.TransactionFilters{}
+
+	if startDateStr := query("start_date"); startDateStr != "" {
+		startDate, err := time.Parse("2006-01-02", startDateStr)
+		if err != nil {
+			return filters, errors.New("invalid start_date format, use YYYY-MM-DD")
+		}
+		filters.StartDate = &startDate
+	}
+
+	if endDateStr := query("end_date"); endDateStr != "" {
+		endDate, err := time.Parse("2006-01-02", endDateStr)
+		if err != nil {
+			return filters, errors.New("invalid end_date format, use YYYY-MM-DD")
+		}
+		filters.EndDate = &endDate
+	}
+
+	if filters.StartDate != nil && filters.EndDate != nil {
+		if filters.StartDate.After(*filters.EndDate) {
+			return filters, errors.New("start_date must be before end_date")
+		}
+	}
+
+	if values, negate := parseValueList(query("category_id")); len(values) > 0 {
+		filters.CategoryID.Negate = negate
+		for _, value := range values {
+			categoryID, err := strconv.Atoi(value)
+			if err != nil || categoryID <= 0 {
+				return filters, errors.New("category_id must be a list of positive integers")
+			}
+			filters.CategoryID.Values = append(filters.CategoryID.Values, categoryID)
+		}
+	}
+
+	if values, negate := parseValueList(query("type")); len(values) > 0 {
+		for _, txType := range values {
+			if txType != models.TransactionTypeIncome && txType != models.TransactionTypeExpense {
+				return filters, errors.New("type must be `income` or `expense`")
+			}
+		}
+		filters.Type = database.ValueFilter[string]{Values: values, Negate: negate}
+	}
+
+	if values, negate := parseValueList(query("payment_method")); len(values) > 0 {
+		validMetods := map[string]bool{
+			models.PaymentMethodCash:     true,
+			models.PaymentMethodCard:     true,
+			models.PaymentMethodTransfer: true,
+		}
+		for _, paymentMethod := range values {
+			if !validMetods[paymentMethod] {
+				return filters, errors.New("payment_method must be a `cash`, `card` or `transfer`")
+			}
+		}
+		filters.PaymentMethod = database.ValueFilter[string]{Values: values, Negate: negate}
+	}
+
+	if minAmountStr := query("min_amount"); minAmountStr != "" {
+		minAmount, err := strconv.ParseFloat(minAmountStr, 64)
+		if err != nil || minAmount < 0 {
+			return filters, errors.New("min_amount must be a non-negative number")
+		}
+		filters.MinAmount = &minAmount
+	}
+
+	if maxAmountStr := query("max_amount"); maxAmountStr != "" {
+		maxAmount, err := strconv.ParseFloat(maxAmountStr, 64)
+		if err != nil || maxAmount < 0 {
+			return filters, errors.New("max_amount must be a non-negative number")
+		}
+		filters.MaxAmount = &maxAmount
+	}
+
+	if filters.MinAmount != nil && filters.MaxAmount != nil && *filters.MinAmount > *filters.MaxAmount {
+		return filters, errors.New("min_amount must not exceed max_amount")
+	}
+
+	filters.Search = strings.TrimSpace(query("q"))
+
+	if createdAfterStr := query("created_after"); createdAfterStr != "" {
+		createdAfter, err := time.Parse(time.RFC3339, createdAfterStr)
+		if err != nil {
+			createdAfter, err = time.Parse("2006-01-02", createdAfterStr)
+		}
+		if err != nil {
+			return filters, errors.New("invalid created_after format, use RFC 3339 or YYYY-MM-DD")
+		}
+		filters.CreatedAfter = &createdAfter
+	}
+
+	if uncategorizedStr := query("uncategorized"); uncategorizedStr != "" {
+		uncategorized, err := strconv.ParseBool(uncategorizedStr)
+		if err != nil {
+			return filters, errors.New("uncategorized must be `true` or `false`")
+		}
+		filters.Uncategorized = uncategorized
+	}
+
+	if limitStr := query("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			return filters, errors.New("limit must be a positive integer")
+		}
+		filters.Limit = &limit
+	}
+
+	if offsetStr := query("offset"); offsetStr != "" {
+		offset, err := strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			return filters, errors.New("offset must be a positive")
+		}
+		filters.Offset = &offset
+	}
+
+	filters.SortBy = query("sort")
+	filters.SortOrder = query("order")
+	filters.Cursor = query("cursor")
+
+	if filters.Cursor != "" && filters.Offset != nil {
+		return filters, errors.New("cursor and offset cannot be used together")
+	}
+
+	return filters, nil
+}
+
+// parseValueList разбирает список значений через запятую; префикс "!" инвертирует фильтр
+func parseValueList(value string) ([]string, bool) {
+	value = strings.TrimSpace(value)
+	negate := strings.HasPrefix(value, "!")
+	return splitQueryList(strings.TrimPrefix(value, "!")), negate
+}
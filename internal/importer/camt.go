@@ -0,0 +1,192 @@
+package importer
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/ChixXx1/expense-tracker/internal/models"
+)
+
+type camtDocument struct {
+	Statements []camtStatement `xml:"BkToCstmrStmt>Stmt"`
+}
+
+type camtStatement struct {
+	IBAN    string      `xml:"Acct>Id>IBAN"`
+	OtherID string      `xml:"Acct>Id>Othr>Id"`
+	Entries []camtEntry `xml:"Ntry"`
+}
+
+type camtDate struct {
+	Date     string `xml:"Dt"`
+	DateTime string `xml:"DtTm"`
+}
+
+// camtStatus: в версиях до .08 статус - текст, позже - <Cd>
+type camtStatus struct {
+	Text string `xml:",chardata"`
+	Code string `xml:"Cd"`
+}
+
+type camtParty struct {
+	Name      string `xml:"Nm"`
+	PartyName string `xml:"Pty>Nm"`
+}
+
+func (p camtParty) name() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return p.PartyName
+}
+
+type camtTxDetails struct {
+	EndToEndID   string    `xml:"Refs>EndToEndId"`
+	TxID         string    `xml:"Refs>TxId"`
+	AcctSvcrRef  string    `xml:"Refs>AcctSvcrRef"`
+	Creditor     camtParty `xml:"RltdPties>Cdtr"`
+	Debtor       camtParty `xml:"RltdPties>Dbtr"`
+	Unstructured []string  `xml:"RmtInf>Ustrd"`
+}
+
+type camtEntry struct {
+	Amount      string          `xml:"Amt"`
+	CreditDebit string          `xml:"CdtDbtInd"`
+	Status      camtStatus      `xml:"Sts"`
+	BookingDate camtDate        `xml:"BookgDt"`
+	ValueDate   camtDate        `xml:"ValDt"`
+	AcctSvcrRef string          `xml:"AcctSvcrRef"`
+	EntryRef    string          `xml:"NtryRef"`
+	Info        string          `xml:"AddtlNtryInf"`
+	Details     []camtTxDetails `xml:"NtryDtls>TxDtls"`
+}
+
+type camtImporter struct{}
+
+// Parse читает ISO 20022 camt.053; пространство имен версии не важно
+func (camtImporter) Parse(r io.Reader, options Options) ([]ParsedRow, error) {
+	var doc camtDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to read camt.053: %w", err)
+	}
+
+	paymentMethod := options.PaymentMethod
+	if paymentMethod == "" {
+		paymentMethod = models.PaymentMethodTransfer
+	}
+
+	rows := []ParsedRow{}
+	occurrences := map[string]int{}
+
+	for _, stmt := range doc.Statements {
+		account := options.Account
+		if account == "" {
+			account = stmt.IBAN
+		}
+		if account == "" {
+			account = stmt.OtherID
+		}
+
+		for _, entry := range stmt.Entries {
+			row := ParsedRow{Line: len(rows) + 1}
+			tx := &row.Transaction
+			tx.Account = account
+			tx.PaymentMethod = paymentMethod
+
+			status := strings.TrimSpace(entry.Status.Code)
+			if status == "" {
+				status = strings.TrimSpace(entry.Status.Text)
+			}
+			if status != "" && status != "BOOK" {
+				row.Errors = append(row.Errors, fmt.Sprintf("entry status %s is not booked", status))
+			}
+
+			date, err := entry.date()
+			if err != nil {
+				row.Errors = append(row.Errors, err.Error())
+			}
+			tx.Date = date
+
+			amount, err := ParseAmount(entry.Amount, ".")
+			if err != nil {
+				row.Errors = append(row.Errors, err.Error())
+			}
+			if entry.CreditDebit == "DBIT" {
+				amount = -amount
+			}
+			fillTransaction(tx, amount, options)
+
+			tx.Description = entry.description()
+			tx.ExternalID = entry.reference()
+			if tx.ExternalID == "" {
+				base := syntheticID(tx.Date, amount, tx.Description, 0)
+				tx.ExternalID = syntheticID(tx.Date, amount, tx.Description, occurrences[base])
+				occurrences[base]++
+			}
+
+			validateRow(&row)
+			rows = append(rows, row)
+		}
+	}
+
+	return rows, nil
+}
+
+func (e camtEntry) date() (time.Time, error) {
+	for _, d := range []camtDate{e.BookingDate, e.ValueDate} {
+		if d.Date != "" {
+			return time.Parse("2006-01-02", strings.TrimSpace(d.Date))
+		}
+		if d.DateTime != "" {
+			value := strings.TrimSpace(d.DateTime)
+			if date, err := time.Parse(time.RFC3339, value); err == nil {
+				return date, nil
+			}
+			return time.Parse("2006-01-02T15:04:05", value)
+		}
+	}
+	return time.Time{}, fmt.Errorf("booking date is missing")
+}
+
+func (e camtEntry) reference() string {
+	if e.AcctSvcrRef != "" {
+		return e.AcctSvcrRef
+	}
+	if e.EntryRef != "" {
+		return e.EntryRef
+	}
+	for _, d := range e.Details {
+		if d.AcctSvcrRef != "" {
+			return d.AcctSvcrRef
+		}
+		if d.TxID != "" {
+			return d.TxID
+		}
+		if d.EndToEndID != "" && d.EndToEndID != "NOTPROVIDED" {
+			return d.EndToEndID
+		}
+	}
+	return ""
+}
+
+func (e camtEntry) description() string {
+	var parts []string
+	if len(e.Details) > 0 {
+		d := e.Details[0]
+		party := d.Creditor.name()
+		if e.CreditDebit == "CRDT" {
+			party = d.Debtor.name()
+		}
+		if party != "" {
+			parts = append(parts, party)
+		}
+		parts = append(parts, d.Unstructured...)
+	}
+	if len(parts) == 0 && e.Info != "" {
+		parts = append(parts, e.Info)
+	}
+	return strings.Join(parts, " ")
+}
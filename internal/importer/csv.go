@@ -0,0 +1,305 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/ChixXx1/expense-tracker/internal/models"
+)
+
+// ParsedRow - одна строка выписки после разбора
+type ParsedRow struct {
+	Line            int                `json:"line"`
+	Transaction     models.Transaction `json:"transaction"`
+	AlreadyImported bool               `json:"already_imported,omitempty"`
+	DuplicateOf     []int              `json:"duplicate_of,omitempty"`
+	Errors          []string           `json:"errors,omitempty"`
+}
+
+func (r *ParsedRow) Valid() bool {
+	return len(r.Errors) == 0
+}
+
+// CheckCategories повторяет проверку CreateTransaction: категория должна существовать
+func CheckCategories(rows []ParsedRow, categories []models.Category) {
+	exists := make(map[int]bool, len(categories))
+	for _, cat := range categories {
+		exists[cat.ID] = true
+	}
+
+	for i := range rows {
+		if rows[i].Valid() && !exists[rows[i].Transaction.CategoryID] {
+			rows[i].Errors = append(rows[i].Errors, "category does not exist")
+		}
+	}
+}
+
+// MarkImported помечает строки, чьи внешние идентификаторы уже есть в хранилище
+func MarkImported(rows []ParsedRow, imported map[string]bool) {
+	seen := make(map[string]bool)
+	for i := range rows {
+		key := rows[i].Transaction.ExternalKey()
+		if key == "" {
+			continue
+		}
+		if imported[key] || seen[key] {
+			rows[i].AlreadyImported = true
+		}
+		seen[key] = true
+	}
+}
+
+type csvColumns struct {
+	date, amount, debit, credit, description, category int
+}
+
+func ParseCSV(r io.Reader, profile models.ImportProfile, categories []models.Category) ([]ParsedRow, error) {
+	if err := profile.Validate(); err != nil {
+		return nil, err
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	if profile.Delimiter != "" {
+		reader.Comma = []rune(profile.Delimiter)[0]
+	}
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv: %w", err)
+	}
+
+	if profile.SkipRows >= len(records) {
+		return []ParsedRow{}, nil
+	}
+	line := profile.SkipRows
+	records = records[profile.SkipRows:]
+
+	var header []string
+	if profile.HasHeader {
+		if len(records) == 0 {
+			return []ParsedRow{}, nil
+		}
+		header = records[0]
+		records = records[1:]
+		line++
+	}
+
+	columns, err := resolveColumns(profile, header)
+	if err != nil {
+		return nil, err
+	}
+
+	layout := DateLayout(profile.DateFormat)
+	rows := make([]ParsedRow, 0, len(records))
+
+	for _, record := range records {
+		line++
+		if isBlank(record) {
+			continue
+		}
+
+		row := ParsedRow{Line: line}
+		tx := &row.Transaction
+		tx.PaymentMethod = profile.PaymentMethod
+		if tx.PaymentMethod == "" {
+			tx.PaymentMethod = models.PaymentMethodCard
+		}
+
+		date, err := time.Parse(layout, field(record, columns.date))
+		if err != nil {
+			row.Errors = append(row.Errors, fmt.Sprintf("invalid date %q, expected format %s", field(record, columns.date), profile.DateFormat))
+		}
+		tx.Date = date
+
+		amount, txType, err := parseSignedAmount(record, columns, profile)
+		if err != nil {
+			row.Errors = append(row.Errors, err.Error())
+		}
+		tx.Amount = amount
+		tx.Type = txType
+
+		tx.Description = field(record, columns.description)
+
+		categoryID, err := resolveCategory(field(record, columns.category), txType, profile, categories)
+		if err != nil {
+			row.Errors = append(row.Errors, err.Error())
+		}
+		tx.CategoryID = categoryID
+
+		validateRow(&row)
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func resolveColumns(profile models.ImportProfile, header []string) (csvColumns, error) {
+	columns := csvColumns{date: -1, amount: -1, debit: -1, credit: -1, description: -1, category: -1}
+
+	refs := []struct {
+		ref    string
+		target *int
+	}{
+		{profile.DateColumn, &columns.date},
+		{profile.AmountColumn, &columns.amount},
+		{profile.DebitColumn, &columns.debit},
+		{profile.CreditColumn, &columns.credit},
+		{profile.DescriptionColumn, &columns.description},
+		{profile.CategoryColumn, &columns.category},
+	}
+
+	for _, ref := range refs {
+		if ref.ref == "" {
+			continue
+		}
+		index, err := columnIndex(ref.ref, header)
+		if err != nil {
+			return columns, err
+		}
+		*ref.target = index
+	}
+
+	return columns, nil
+}
+
+func columnIndex(ref string, header []string) (int, error) {
+	for i, name := range header {
+		if strings.EqualFold(strings.TrimSpace(name), strings.TrimSpace(ref)) {
+			return i, nil
+		}
+	}
+
+	if n, err := strconv.Atoi(ref); err == nil && n > 0 {
+		return n - 1, nil
+	}
+
+	return -1, fmt.Errorf("column %q not found", ref)
+}
+
+func field(record []string, index int) string {
+	if index < 0 || index >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[index])
+}
+
+func isBlank(record []string) bool {
+	for _, value := range record {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func parseSignedAmount(record []string, columns csvColumns, profile models.ImportProfile) (float64, string, error) {
+	if profile.AmountConvention == models.AmountConventionSplit {
+		debit, credit := field(record, columns.debit), field(record, columns.credit)
+		if debit != "" {
+			amount, err := ParseAmount(debit, profile.DecimalSeparator)
+			if err != nil {
+				return 0, models.TransactionTypeExpense, err
+			}
+			if amount != 0 {
+				return math.Abs(amount), models.TransactionTypeExpense, nil
+			}
+		}
+		amount, err := ParseAmount(credit, profile.DecimalSeparator)
+		return math.Abs(amount), models.TransactionTypeIncome, err
+	}
+
+	amount, err := ParseAmount(field(record, columns.amount), profile.DecimalSeparator)
+	if err != nil {
+		return 0, "", err
+	}
+
+	txType := models.TransactionTypeExpense
+	switch profile.AmountConvention {
+	case models.AmountConventionSigned:
+		if amount > 0 {
+			txType = models.TransactionTypeIncome
+		}
+	case models.AmountConventionInverted:
+		if amount < 0 {
+			txType = models.TransactionTypeIncome
+		}
+	}
+
+	return math.Abs(amount), txType, nil
+}
+
+// ParseAmount понимает пробелы между разрядами, символы валют и десятичную запятую
+func ParseAmount(value, decimalSeparator string) (float64, error) {
+	var b strings.Builder
+	for _, r := range value {
+		switch {
+		case unicode.IsDigit(r), r == '-', r == '+', r == '.', r == ',':
+			b.WriteRune(r)
+		case r == '(' || r == ')':
+			// (100.00) - отрицательная сумма в бухгалтерской записи
+			if r == '(' {
+				b.WriteRune('-')
+			}
+		}
+	}
+
+	cleaned := b.String()
+	if cleaned == "" {
+		return 0, errors.New("amount is empty")
+	}
+
+	if decimalSeparator == "," {
+		cleaned = strings.ReplaceAll(cleaned, ".", "")
+		cleaned = strings.ReplaceAll(cleaned, ",", ".")
+	} else {
+		cleaned = strings.ReplaceAll(cleaned, ",", "")
+	}
+
+	amount, err := strconv.ParseFloat(cleaned, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+
+	return amount, nil
+}
+
+func resolveCategory(name, txType string, profile models.ImportProfile, categories []models.Category) (int, error) {
+	if name != "" {
+		if id := categoryByName(name, txType, categories); id > 0 {
+			return id, nil
+		}
+	}
+
+	if profile.DefaultCategoryID > 0 {
+		return profile.DefaultCategoryID, nil
+	}
+
+	return 0, fmt.Errorf("category %q not found", name)
+}
+
+var dateTokens = strings.NewReplacer(
+	"YYYY", "2006",
+	"YY", "06",
+	"MM", "01",
+	"DD", "02",
+	"HH", "15",
+	"mm", "04",
+	"ss", "05",
+)
+
+// DateLayout переводит формат вида DD.MM.YYYY в layout Go; готовый layout возвращается как есть
+func DateLayout(format string) string {
+	if strings.Contains(format, "2006") {
+		return format
+	}
+	return dateTokens.Replace(format)
+}
@@ -0,0 +1,113 @@
+package importer
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ChixXx1/expense-tracker/internal/models"
+)
+
+// Options - общие настройки импорта; каждый формат использует нужные ему поля
+type Options struct {
+	Account           string
+	ExpenseCategoryID int
+	IncomeCategoryID  int
+	PaymentMethod     string
+	DateFormat        string
+	Profile           *models.ImportProfile
+	Categories        []models.Category
+}
+
+// Importer превращает выписку в строки с транзакциями и ключами дедупликации
+type Importer interface {
+	Parse(r io.Reader, options Options) ([]ParsedRow, error)
+}
+
+var importers = map[string]Importer{}
+
+func Register(format string, importer Importer) {
+	importers[format] = importer
+}
+
+func Get(format string) (Importer, error) {
+	importer, exists := importers[strings.ToLower(format)]
+	if !exists {
+		return nil, fmt.Errorf("unsupported import format '%s', use %s", format, strings.Join(Formats(), ", "))
+	}
+	return importer, nil
+}
+
+func Formats() []string {
+	formats := make([]string, 0, len(importers))
+	for format := range importers {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+type csvImporter struct{}
+
+func (csvImporter) Parse(r io.Reader, options Options) ([]ParsedRow, error) {
+	if options.Profile == nil {
+		return nil, errors.New("csv import requires a mapping profile")
+	}
+	return ParseCSV(r, *options.Profile, options.Categories)
+}
+
+type ofxImporter struct{}
+
+func (ofxImporter) Parse(r io.Reader, options Options) ([]ParsedRow, error) {
+	return ParseOFX(r, options)
+}
+
+func init() {
+	Register("csv", csvImporter{})
+	Register("ofx", ofxImporter{})
+	Register("qfx", ofxImporter{})
+	Register("qif", qifImporter{})
+	Register("camt053", camtImporter{})
+}
+
+// fillTransaction раскладывает сумму со знаком по типу и категории по умолчанию
+func fillTransaction(tx *models.Transaction, amount float64, options Options) {
+	if amount < 0 {
+		tx.Type = models.TransactionTypeExpense
+		tx.Amount = -amount
+		tx.CategoryID = options.ExpenseCategoryID
+	} else {
+		tx.Type = models.TransactionTypeIncome
+		tx.Amount = amount
+		tx.CategoryID = options.IncomeCategoryID
+	}
+}
+
+func categoryByName(name, txType string, categories []models.Category) int {
+	for _, cat := range categories {
+		if strings.EqualFold(cat.Name, name) && cat.Type == txType {
+			return cat.ID
+		}
+	}
+	return 0
+}
+
+// syntheticID строит ключ для форматов без идентификаторов операций.
+// occurrence различает одинаковые операции внутри одного файла.
+func syntheticID(date time.Time, amount float64, description string, occurrence int) string {
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s|%.2f|%s|%d", date.Format("2006-01-02"), amount, strings.ToLower(description), occurrence)))
+	return "h:" + hex.EncodeToString(sum[:8])
+}
+
+func validateRow(row *ParsedRow) {
+	if len(row.Errors) == 0 {
+		if err := row.Transaction.Validate(); err != nil {
+			row.Errors = append(row.Errors, err.Error())
+		}
+	}
+}
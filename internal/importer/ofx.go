@@ -0,0 +1,202 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ChixXx1/expense-tracker/internal/models"
+)
+
+var ofxTransactionAggregates = map[string]bool{
+	"PAYEE":        true,
+	"CURRENCY":     true,
+	"ORIGCURRENCY": true,
+	"BANKACCTTO":   true,
+	"CCACCTTO":     true,
+}
+
+type ofxElement struct {
+	name  string
+	value string
+	close bool
+}
+
+// ParseOFX разбирает OFX 1.x (SGML) и 2.x (XML): в обоих случаях
+// достаточно пройти по тегам, значения листовых элементов идут до следующего '<'
+func ParseOFX(r io.Reader, options Options) ([]ParsedRow, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ofx: %w", err)
+	}
+
+	content := string(data)
+	start := strings.Index(strings.ToUpper(content), "<OFX>")
+	if start < 0 {
+		return nil, errors.New("not an OFX document")
+	}
+
+	elements := tokenizeOFX(content[start:])
+
+	account := options.Account
+	paymentMethod := options.PaymentMethod
+	if paymentMethod == "" {
+		paymentMethod = models.PaymentMethodCard
+	}
+
+	rows := []ParsedRow{}
+	var current map[string]string
+	// вложенный агрегат внутри STMTTRN (PAYEE, CURRENCY, BANKACCTTO...)
+	nested := ""
+
+	for _, el := range elements {
+		switch {
+		case el.name == "STMTTRN" && !el.close:
+			current = make(map[string]string)
+			nested = ""
+		case el.name == "STMTTRN" && el.close:
+			if current != nil {
+				rows = append(rows, ofxRow(len(rows)+1, current, account, paymentMethod, options))
+			}
+			current = nil
+		case current != nil && ofxTransactionAggregates[el.name]:
+			if el.close {
+				nested = ""
+			} else {
+				nested = el.name
+			}
+		case el.close || el.value == "":
+			continue
+		case current != nil && nested == "":
+			current[el.name] = el.value
+		case current != nil && nested == "PAYEE" && el.name == "NAME":
+			current["PAYEE"] = el.value
+		case current == nil && el.name == "ACCTID" && account == "":
+			account = el.value
+		}
+	}
+
+	return rows, nil
+}
+
+func tokenizeOFX(content string) []ofxElement {
+	var elements []ofxElement
+
+	for {
+		open := strings.IndexByte(content, '<')
+		if open < 0 {
+			break
+		}
+		end := strings.IndexByte(content[open:], '>')
+		if end < 0 {
+			break
+		}
+
+		tag := strings.TrimSpace(content[open+1 : open+end])
+		content = content[open+end+1:]
+
+		if tag == "" || strings.HasPrefix(tag, "?") || strings.HasPrefix(tag, "!") {
+			continue
+		}
+
+		if strings.HasPrefix(tag, "/") {
+			elements = append(elements, ofxElement{name: strings.ToUpper(tag[1:]), close: true})
+			continue
+		}
+
+		value := content
+		if next := strings.IndexByte(content, '<'); next >= 0 {
+			value = content[:next]
+		}
+
+		elements = append(elements, ofxElement{
+			name:  strings.ToUpper(strings.Fields(tag)[0]),
+			value: html.UnescapeString(strings.TrimSpace(value)),
+		})
+	}
+
+	return elements
+}
+
+func ofxRow(line int, fields map[string]string, account, paymentMethod string, options Options) ParsedRow {
+	row := ParsedRow{Line: line}
+	tx := &row.Transaction
+	tx.Account = account
+	tx.ExternalID = fields["FITID"]
+	tx.PaymentMethod = paymentMethod
+
+	if tx.ExternalID == "" {
+		row.Errors = append(row.Errors, "FITID is missing")
+	}
+
+	date, err := parseOFXDate(fields["DTPOSTED"])
+	if err != nil {
+		row.Errors = append(row.Errors, err.Error())
+	}
+	tx.Date = date
+
+	amount, err := ParseAmount(fields["TRNAMT"], ".")
+	if err != nil {
+		row.Errors = append(row.Errors, err.Error())
+	}
+
+	fillTransaction(tx, amount, options)
+
+	tx.Description = fields["NAME"]
+	if tx.Description == "" {
+		tx.Description = fields["PAYEE"]
+	}
+	if memo := fields["MEMO"]; memo != "" && memo != tx.Description {
+		if tx.Description == "" {
+			tx.Description = memo
+		} else {
+			tx.Description += " " + memo
+		}
+	}
+
+	validateRow(&row)
+
+	return row
+}
+
+// parseOFXDate разбирает YYYYMMDD[HHMMSS[.XXX]][[+-]H[:TZ]]
+func parseOFXDate(value string) (time.Time, error) {
+	if len(value) < 8 {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+
+	location := time.UTC
+	if open := strings.IndexByte(value, '['); open >= 0 {
+		zone := strings.TrimSuffix(value[open+1:], "]")
+		value = value[:open]
+		offset := zone
+		if colon := strings.IndexByte(zone, ':'); colon >= 0 {
+			offset = zone[:colon]
+		}
+		hours, err := strconv.ParseFloat(offset, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date timezone %q", zone)
+		}
+		location = time.FixedZone(zone, int(hours*3600))
+	}
+
+	if dot := strings.IndexByte(value, '.'); dot >= 0 {
+		value = value[:dot]
+	}
+
+	layout := "20060102150405"
+	if len(value) < len(layout) {
+		layout = layout[:len(value)]
+	}
+
+	date, err := time.ParseInLocation(layout, value, location)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+
+	return date, nil
+}
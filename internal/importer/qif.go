@@ -0,0 +1,201 @@
+package importer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/ChixXx1/expense-tracker/internal/models"
+)
+
+const defaultQIFDateFormat = "MM/DD/YYYY"
+
+type qifImporter struct{}
+
+// Parse читает QIF, в том числе файлы с несколькими счетами (!Account)
+func (qifImporter) Parse(r io.Reader, options Options) ([]ParsedRow, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	dateFormat := options.DateFormat
+	if dateFormat == "" {
+		dateFormat = defaultQIFDateFormat
+	}
+	layout := qifDateLayout(dateFormat)
+
+	rows := []ParsedRow{}
+	account := options.Account
+	section := ""
+	inAccountBlock := false
+	fields := map[string]string{}
+	startLine, line := 0, 0
+	occurrences := map[string]int{}
+
+	for scanner.Scan() {
+		line++
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		if strings.HasPrefix(text, "!") {
+			header := strings.ToLower(strings.TrimSpace(text))
+			switch {
+			case header == "!account":
+				inAccountBlock = true
+			case strings.HasPrefix(header, "!type:"):
+				section = strings.TrimPrefix(header, "!type:")
+				inAccountBlock = false
+			}
+			continue
+		}
+
+		if len(fields) == 0 {
+			startLine = line
+		}
+
+		code, value := text[:1], strings.TrimSpace(text[1:])
+
+		if code != "^" {
+			// Разделенные операции (S/E/$) и адрес (A) повторяются, храним первое значение
+			if _, exists := fields[code]; !exists {
+				fields[code] = value
+			}
+			continue
+		}
+
+		if inAccountBlock {
+			if name := fields["N"]; name != "" {
+				account = name
+			}
+			inAccountBlock = false
+		} else if isQIFTransactionSection(section) && len(fields) > 0 {
+			rows = append(rows, qifRow(startLine, fields, account, section, layout, options, occurrences))
+		}
+		fields = map[string]string{}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read qif: %w", err)
+	}
+
+	return rows, nil
+}
+
+func isQIFTransactionSection(section string) bool {
+	switch section {
+	case "bank", "cash", "ccard", "oth a", "oth l":
+		return true
+	}
+	return false
+}
+
+func qifRow(line int, fields map[string]string, account, section, layout string, options Options, occurrences map[string]int) ParsedRow {
+	row := ParsedRow{Line: line}
+	tx := &row.Transaction
+	tx.Account = account
+
+	date, err := parseQIFDate(fields["D"], layout)
+	if err != nil {
+		row.Errors = append(row.Errors, err.Error())
+	}
+	tx.Date = date
+
+	amountStr := fields["T"]
+	if amountStr == "" {
+		amountStr = fields["U"]
+	}
+	amount, err := ParseAmount(amountStr, ".")
+	if err != nil {
+		row.Errors = append(row.Errors, err.Error())
+	}
+	fillTransaction(tx, amount, options)
+
+	tx.Description = fields["P"]
+	if memo := fields["M"]; memo != "" && memo != tx.Description {
+		if tx.Description == "" {
+			tx.Description = memo
+		} else {
+			tx.Description += " " + memo
+		}
+	}
+
+	switch section {
+	case "cash":
+		tx.PaymentMethod = models.PaymentMethodCash
+	case "ccard":
+		tx.PaymentMethod = models.PaymentMethodCard
+	default:
+		tx.PaymentMethod = options.PaymentMethod
+	}
+	if tx.PaymentMethod == "" {
+		tx.PaymentMethod = models.PaymentMethodCard
+	}
+
+	// L[Счет] - перевод между счетами, иначе L - имя категории
+	if category := fields["L"]; strings.HasPrefix(category, "[") {
+		tx.PaymentMethod = models.PaymentMethodTransfer
+	} else if category != "" {
+		if name, _, _ := strings.Cut(category, ":"); name != "" {
+			if id := categoryByName(name, tx.Type, options.Categories); id > 0 {
+				tx.CategoryID = id
+			}
+		}
+	}
+
+	// N - номер чека или метка вроде ATM/DEP/XFR; уникален только номер чека, и то лишь вместе с датой и суммой
+	if number := fields["N"]; isCheckNumber(number) {
+		tx.ExternalID = fmt.Sprintf("n:%s:%s:%.2f", number, tx.Date.Format("2006-01-02"), amount)
+	} else {
+		base := syntheticID(tx.Date, amount, tx.Description, 0)
+		tx.ExternalID = syntheticID(tx.Date, amount, tx.Description, occurrences[base])
+		occurrences[base]++
+	}
+
+	validateRow(&row)
+
+	return row
+}
+
+func isCheckNumber(value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+var qifDateTokens = strings.NewReplacer(
+	"YYYY", "2006",
+	"YY", "06",
+	"MM", "1",
+	"DD", "2",
+)
+
+func qifDateLayout(format string) string {
+	return qifDateTokens.Replace(strings.ReplaceAll(format, "'", "/"))
+}
+
+// parseQIFDate понимает варианты Quicken: 1/ 5'26, 01/05/2026, 1/5/26
+func parseQIFDate(value, layout string) (time.Time, error) {
+	normalized := strings.ReplaceAll(strings.ReplaceAll(value, " ", ""), "'", "/")
+
+	if date, err := time.Parse(layout, normalized); err == nil {
+		return date, nil
+	}
+
+	// Двузначный год в файле при четырехзначном в формате
+	if short := strings.Replace(layout, "2006", "06", 1); short != layout {
+		if date, err := time.Parse(short, normalized); err == nil {
+			return date, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
@@ -0,0 +1,56 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestQIFExternalID(t *testing.T) {
+	data := `!Type:Bank
+D01/05/2026
+T-40.00
+NATM
+PCash withdrawal
+^
+D01/05/2026
+T-40.00
+NATM
+PCash withdrawal
+^
+D01/06/2026
+T-15.50
+N1042
+PCheck
+^
+D01/07/2026
+T-15.50
+N1042
+PCheck
+^
+`
+	rows, err := qifImporter{}.Parse(strings.NewReader(data), Options{ExpenseCategoryID: 1, IncomeCategoryID: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 4 {
+		t.Fatalf("got %d rows, want 4", len(rows))
+	}
+
+	ids := map[string]bool{}
+	for _, row := range rows {
+		if len(row.Errors) > 0 {
+			t.Fatalf("line %d: %v", row.Line, row.Errors)
+		}
+		ids[row.Transaction.ExternalID] = true
+	}
+	if len(ids) != 4 {
+		t.Errorf("external IDs are not unique: %v", ids)
+	}
+
+	if id := rows[0].Transaction.ExternalID; !strings.HasPrefix(id, "h:") {
+		t.Errorf("label N must fall back to content hash, got %q", id)
+	}
+	if id := rows[2].Transaction.ExternalID; id != "n:1042:2026-01-06:-15.50" {
+		t.Errorf("check number ID = %q", id)
+	}
+}
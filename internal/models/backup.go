@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// BackupSchemaVersion - текущая версия формата копии. Во второй версии в копию вошли
+// пользователи, книги с участниками, приглашения и refresh-токены; копии первой версии
+// проверяются по своему формату и обновляются при восстановлении.
+const BackupSchemaVersion = 2
+
+const (
+	RestoreModeReplace = "replace"
+	RestoreModeMerge   = "merge"
+)
+
+type BackupSettings struct {
+	ImportProfiles      []ImportProfile `json:"import_profiles"`
+	DismissedDuplicates [][2]int        `json:"dismissed_duplicates"`
+}
+
+// Backup - полный самодостаточный снимок данных
+type Backup struct {
+	SchemaVersion int            `json:"schema_version"`
+	CreatedAt     time.Time      `json:"created_at"`
+	Users         []UserRecord   `json:"users"`
+	Ledgers       []Ledger       `json:"ledgers"`
+	LedgerInvites []LedgerInvite `json:"ledger_invites"`
+	RefreshTokens []RefreshToken `json:"refresh_tokens"`
+	Categories    []Category     `json:"categories"`
+	Transactions  []Transaction  `json:"transactions"`
+	Budgets       []Budget       `json:"budgets"`
+	Settings      BackupSettings `json:"settings"`
+	Checksum      string         `json:"checksum"`
+
+	// Source - JSON копии в том виде, в каком его прочитал backup.Read; nil у копий, собранных в памяти.
+	// Контрольная сумма прочитанной копии проверяется по нему, а не по текущим структурам.
+	Source []byte `json:"-"`
+}
+
+type RestoreResult struct {
+	Mode         string `json:"mode"`
+	Users        int    `json:"users"`
+	Ledgers      int    `json:"ledgers"`
+	Categories   int    `json:"categories"`
+	Transactions int    `json:"transactions"`
+	Budgets      int    `json:"budgets"`
+	Skipped      int    `json:"skipped"`
+}
@@ -1,7 +1,6 @@
 package models
 
 import (
-	"errors"
 	"time"
 )
 
@@ -13,21 +12,24 @@ const (
 
 type Budget struct {
 	ID         int       `json:"id"`
+	OwnerID    int       `json:"owner_id"`
+	LedgerID   int       `json:"ledger_id"`
 	CategoryID int       `json:"category_id"`
 	Amount     float64   `json:"amount"`
 	Period     string    `json:"period"`
 	Month      time.Time `json:"month"`
 	Spent      float64   `json:"spent"`
+	Version    int       `json:"version"`
 	CreatedAt  time.Time `json:"created_at"`
 }
 
 func (b *Budget) Validate() error {
 	if b.Amount <= 0 {
-		return errors.New("budget amount must be positive")
+		return fieldError("amount", "budget amount must be positive")
 	}
 
 	if b.CategoryID <= 0 {
-		return errors.New("category_id must be positive")
+		return fieldError("category_id", "category_id must be positive")
 	}
 
 	validPeriods := map[string]bool{
@@ -37,11 +39,11 @@ func (b *Budget) Validate() error {
 	}
 
 	if !validPeriods[b.Period] {
-		return errors.New("period must be 'monthly', 'weekly' or 'yearly'")
+		return fieldError("period", "period must be 'monthly', 'weekly' or 'yearly'")
 	}
 
 	if b.Month.IsZero() {
-		return errors.New("month is required")
+		return fieldError("month", "month is required")
 	}
 
 	return nil
@@ -1,13 +1,16 @@
 package models
 
-import "errors"
-
 type Category struct {
-	ID    int    `json:"id"`
-	Name  string `json:"name"`
-	Type  string `json:"type"`
-	Color string `json:"color"`
-	Icon  string `json:"icon"`
+	ID int `json:"id"`
+	// OwnerID - автор записи, LedgerID - книга, которой она принадлежит; оба выставляет сервер
+	OwnerID  int    `json:"owner_id"`
+	LedgerID int    `json:"ledger_id"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Color    string `json:"color"`
+	Icon     string `json:"icon"`
+	// Version растет при каждом изменении, используется для ETag и If-Match
+	Version int `json:"version"`
 	//ParentID *int   `json:"parent_id,omitempty"` //(указатель на int, так как может быть nil для корневых категорий)
 }
 
@@ -28,15 +31,15 @@ func GetDefaultCategories() []Category {
 
 func (c *Category) Validate() error {
 	if c.Name == "" {
-		return errors.New("category name is required")
+		return fieldError("name", "category name is required")
 	}
 
 	if len(c.Name) > 50 {
-		return errors.New("category name is too long (max 50 characters)")
+		return fieldError("name", "category name is too long (max 50 characters)")
 	}
 
 	if c.Type != "income" && c.Type != "expense" {
-		return errors.New("category type must be 'income' or 'expense'")
+		return fieldError("type", "category type must be 'income' or 'expense'")
 	}
 
 	return nil
@@ -0,0 +1,8 @@
+package models
+
+type DuplicatePair struct {
+	First      Transaction `json:"first"`
+	Second     Transaction `json:"second"`
+	Similarity float64     `json:"similarity"`
+	DaysApart  int         `json:"days_apart"`
+}
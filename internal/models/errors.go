@@ -0,0 +1,15 @@
+package models
+
+// FieldError - ошибка валидации конкретного поля, имя поля совпадает с json-тегом
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *FieldError) Error() string {
+	return e.Message
+}
+
+func fieldError(field, message string) error {
+	return &FieldError{Field: field, Message: message}
+}
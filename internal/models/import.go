@@ -0,0 +1,90 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	AmountConventionSigned      = "signed"
+	AmountConventionInverted    = "inverted"
+	AmountConventionSplit       = "split"
+	AmountConventionExpenseOnly = "expense_only"
+)
+
+// ImportProfile описывает, как читать CSV-выписку конкретного банка.
+// Колонки задаются именем из заголовка или номером, начиная с 1.
+type ImportProfile struct {
+	ID                int       `json:"id"`
+	OwnerID           int       `json:"owner_id"`
+	LedgerID          int       `json:"ledger_id"`
+	Name              string    `json:"name"`
+	Bank              string    `json:"bank"`
+	Delimiter         string    `json:"delimiter"`
+	HasHeader         bool      `json:"has_header"`
+	SkipRows          int       `json:"skip_rows"`
+	DateColumn        string    `json:"date_column"`
+	DateFormat        string    `json:"date_format"`
+	AmountColumn      string    `json:"amount_column"`
+	DebitColumn       string    `json:"debit_column"`
+	CreditColumn      string    `json:"credit_column"`
+	AmountConvention  string    `json:"amount_convention"`
+	DecimalSeparator  string    `json:"decimal_separator"`
+	DescriptionColumn string    `json:"description_column"`
+	CategoryColumn    string    `json:"category_column"`
+	DefaultCategoryID int       `json:"default_category_id"`
+	PaymentMethod     string    `json:"payment_method"`
+	Version           int       `json:"version"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+func (p *ImportProfile) Validate() error {
+	if p.Name == "" {
+		return fieldError("name", "profile name is required")
+	}
+
+	if len([]rune(p.Delimiter)) > 1 {
+		return fieldError("delimiter", "delimiter must be a single character")
+	}
+
+	if p.SkipRows < 0 {
+		return fieldError("skip_rows", "skip_rows must not be negative")
+	}
+
+	if p.DateColumn == "" {
+		return fieldError("date_column", "date_column is required")
+	}
+
+	if p.DateFormat == "" {
+		return fieldError("date_format", "date_format is required")
+	}
+
+	switch p.AmountConvention {
+	case AmountConventionSigned, AmountConventionInverted, AmountConventionExpenseOnly:
+		if p.AmountColumn == "" {
+			return fieldError("amount_column", "amount_column is required")
+		}
+	case AmountConventionSplit:
+		if p.DebitColumn == "" || p.CreditColumn == "" {
+			return fieldError("debit_column", "debit_column and credit_column are required for split amounts")
+		}
+	default:
+		return fieldError("amount_convention", "amount_convention must be 'signed', 'inverted', 'split' or 'expense_only'")
+	}
+
+	if p.DecimalSeparator != "" && p.DecimalSeparator != "." && p.DecimalSeparator != "," {
+		return fieldError("decimal_separator", "decimal_separator must be '.' or ','")
+	}
+
+	if p.CategoryColumn == "" && p.DefaultCategoryID <= 0 {
+		return fieldError("category_column", "category_column or default_category_id is required")
+	}
+
+	if p.PaymentMethod != "" &&
+		p.PaymentMethod != PaymentMethodCash &&
+		p.PaymentMethod != PaymentMethodCard &&
+		p.PaymentMethod != PaymentMethodTransfer {
+		return fieldError("payment_method", "payment_method must be 'cash', 'card' or 'transfer'")
+	}
+
+	return nil
+}
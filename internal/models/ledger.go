@@ -0,0 +1,109 @@
+package models
+
+import (
+	"time"
+)
+
+// Роли участников книги: owner управляет книгой и участниками, editor меняет данные, viewer только читает
+const (
+	LedgerRoleOwner  = "owner"
+	LedgerRoleEditor = "editor"
+	LedgerRoleViewer = "viewer"
+)
+
+// PersonalLedgerName - имя книги, которую каждый пользователь получает при регистрации
+const PersonalLedgerName = "Личный"
+
+var ledgerRoleRank = map[string]int{
+	LedgerRoleViewer: 1,
+	LedgerRoleEditor: 2,
+	LedgerRoleOwner:  3,
+}
+
+// RoleAtLeast сообщает, дает ли role права не ниже required
+func RoleAtLeast(role, required string) bool {
+	return ledgerRoleRank[role] >= ledgerRoleRank[required]
+}
+
+func ValidLedgerRole(role string) bool {
+	_, ok := ledgerRoleRank[role]
+	return ok
+}
+
+// Ledger - книга учета: категории, транзакции, бюджеты и профили импорта принадлежат ровно одной книге
+type Ledger struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// Personal - книга по умолчанию, созданная при регистрации; ее нельзя удалить
+	Personal  bool           `json:"personal"`
+	Members   []LedgerMember `json:"members"`
+	Version   int            `json:"version"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+type LedgerMember struct {
+	UserID   int       `json:"user_id"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// LedgerInvite - одноразовый код приглашения в книгу с заранее выбранной ролью
+type LedgerInvite struct {
+	Code      string    `json:"code"`
+	LedgerID  int       `json:"ledger_id"`
+	Role      string    `json:"role"`
+	CreatedBy int       `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (l *Ledger) Validate() error {
+	if l.Name == "" {
+		return fieldError("name", "ledger name is required")
+	}
+
+	if len([]rune(l.Name)) > 50 {
+		return fieldError("name", "ledger name is too long (max 50 characters)")
+	}
+
+	owners := 0
+	for _, member := range l.Members {
+		if !ValidLedgerRole(member.Role) {
+			return fieldError("role", "role must be 'owner', 'editor' or 'viewer'")
+		}
+		if member.Role == LedgerRoleOwner {
+			owners++
+		}
+	}
+	if owners == 0 {
+		return fieldError("members", "ledger must have at least one owner")
+	}
+
+	return nil
+}
+
+// Role возвращает роль пользователя в книге, пустую строку для постороннего
+func (l *Ledger) Role(userID int) string {
+	for _, member := range l.Members {
+		if member.UserID == userID {
+			return member.Role
+		}
+	}
+	return ""
+}
+
+// Owner возвращает ID первого владельца книги, 0 если владельца нет
+func (l *Ledger) Owner() int {
+	for _, member := range l.Members {
+		if member.Role == LedgerRoleOwner {
+			return member.UserID
+		}
+	}
+	return 0
+}
+
+// Clone копирует книгу вместе со списком участников
+func (l Ledger) Clone() Ledger {
+	l.Members = append([]LedgerMember{}, l.Members...)
+	return l
+}
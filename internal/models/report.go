@@ -3,26 +3,95 @@ package models
 import "time"
 
 type FinancialSummary struct {
-	TotalIncome   float64 	`json:"total_income"`
-	TotalExpenses float64 	`json:"total_expenses"`
-	Balance       float64 	`json:"balance"`
-	Period        string 		`json:"period"`
+	TotalIncome   float64   `json:"total_income"`
+	TotalExpenses float64   `json:"total_expenses"`
+	Balance       float64   `json:"balance"`
+	Period        string    `json:"period"`
 	StartDate     time.Time `json:"start_date"`
-	EndDate				time.Time `json:"end_date"`
+	EndDate       time.Time `json:"end_date"`
 }
 
 type CategorySummary struct {
-	CategoryID 		int 			`json:"category_id"`
-	CategoryName  string 		`json:"category_name"`
-	Amount 				float64 	`json:"amount"`
-	Persentage 		float64 	`json:"persentage"`
-	Type 					string 		`json:"type"`
+	CategoryID   int     `json:"category_id"`
+	CategoryName string  `json:"category_name"`
+	Amount       float64 `json:"amount"`
+	Persentage   float64 `json:"persentage"`
+	Type         string  `json:"type"`
 }
 
 type BudgetReport struct {
-	Budget 				Budget 		`json:"budget"`
-	SpentAmount 	float64 	`json:"spent_amount"`
-	Remaining 		float64 	`json:"remaining"`
-	Progress 			float64 	`json:"progress"`
-	IsOverBudget 	bool 			`json:"is_over_budget"`
-}
\ No newline at end of file
+	Budget       Budget  `json:"budget"`
+	SpentAmount  float64 `json:"spent_amount"`
+	Remaining    float64 `json:"remaining"`
+	Progress     float64 `json:"progress"`
+	IsOverBudget bool    `json:"is_over_budget"`
+}
+type MonthlySummary struct {
+	Month    string  `json:"month"`
+	Income   float64 `json:"income"`
+	Expenses float64 `json:"expenses"`
+	Balance  float64 `json:"balance"`
+}
+
+type PayeeSummary struct {
+	Payee  string  `json:"payee"`
+	Amount float64 `json:"amount"`
+	Count  int     `json:"count"`
+}
+
+type YearComparison struct {
+	Year                  int              `json:"year"`
+	Summary               FinancialSummary `json:"summary"`
+	SavingsRate           float64          `json:"savings_rate"`
+	IncomeChange          float64          `json:"income_change"`
+	ExpensesChange        float64          `json:"expenses_change"`
+	BalanceChange         float64          `json:"balance_change"`
+	IncomeChangePercent   float64          `json:"income_change_percent"`
+	ExpensesChangePercent float64          `json:"expenses_change_percent"`
+}
+
+type AnnualReport struct {
+	Year            int               `json:"year"`
+	Summary         FinancialSummary  `json:"summary"`
+	SavingsRate     float64           `json:"savings_rate"`
+	Months          []MonthlySummary  `json:"months"`
+	TopCategories   []CategorySummary `json:"top_categories"`
+	TopPayees       []PayeeSummary    `json:"top_payees"`
+	BiggestExpenses []Transaction     `json:"biggest_expenses"`
+	Budgets         []BudgetReport    `json:"budgets"`
+	BudgetsHit      int               `json:"budgets_hit"`
+	BudgetsMissed   int               `json:"budgets_missed"`
+	PreviousYear    *YearComparison   `json:"previous_year"`
+}
+
+const (
+	PivotDimensionCategory      = "category"
+	PivotDimensionType          = "type"
+	PivotDimensionPaymentMethod = "payment_method"
+	PivotDimensionMonth         = "month"
+	PivotDimensionWeekday       = "weekday"
+	PivotDimensionAccount       = "account"
+)
+
+const (
+	PivotMeasureSum   = "sum"
+	PivotMeasureCount = "count"
+	PivotMeasureAvg   = "avg"
+	PivotMeasureMin   = "min"
+	PivotMeasureMax   = "max"
+)
+
+type PivotRow struct {
+	Keys   []string             `json:"keys"`
+	Cells  []map[string]float64 `json:"cells"`
+	Totals map[string]float64   `json:"totals"`
+}
+
+type PivotTable struct {
+	Rows       []string           `json:"rows"`
+	Columns    []string           `json:"columns"`
+	Measures   []string           `json:"measures"`
+	ColumnKeys [][]string         `json:"column_keys"`
+	Data       []PivotRow         `json:"data"`
+	Totals     map[string]float64 `json:"totals"`
+}
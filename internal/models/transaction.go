@@ -1,7 +1,6 @@
 package models
 
 import (
-	"errors"
 	"math"
 	"time"
 )
@@ -22,44 +21,50 @@ const (
 
 type Transaction struct {
 	ID            int       `json:"id"`
+	OwnerID       int       `json:"owner_id"`
+	LedgerID      int       `json:"ledger_id"`
 	Amount        float64   `json:"amount"`
 	Type          string    `json:"type"`
 	CategoryID    int       `json:"category_id"`
 	Date          time.Time `json:"date"`
 	Description   string    `json:"description"`
+	Notes         string    `json:"notes,omitempty"`
 	PaymentMethod string    `json:"payment_method"`
+	Account       string    `json:"account,omitempty"`
+	ExternalID    string    `json:"external_id,omitempty"`
+	Version       int       `json:"version"`
 	CreatedAt     time.Time `json:"created_at"`
 }
 
 func (t *Transaction) Validate() error {
 	if t.Amount <= 0 {
-		return errors.New("transaction amount must be positive")
+		return fieldError("amount", "transaction amount must be positive")
 	}
 
 	if math.IsNaN(t.Amount) || math.IsInf(t.Amount, 0) {
-		return errors.New("transaction amount is invalid")
+		return fieldError("amount", "transaction amount is invalid")
 	}
 
 	if t.Type != TransactionTypeExpense && t.Type != TransactionTypeIncome {
-		return errors.New("transaction type must be 'income' or 'expense'")
+		return fieldError("type", "transaction type must be 'income' or 'expense'")
 	}
 
 	if t.CategoryID <= 0 {
-		return errors.New("category_id must be positive")
+		return fieldError("category_id", "category_id must be positive")
 	}
 
 	if t.PaymentMethod != PaymentMethodCash &&
 		t.PaymentMethod != PaymentMethodCard &&
 		t.PaymentMethod != PaymentMethodTransfer {
-		return errors.New("transaction method must be 'cash', 'card' or 'transfer'")
+		return fieldError("payment_method", "transaction method must be 'cash', 'card' or 'transfer'")
 	}
 
 	if t.Date.IsZero() {
-		return errors.New("transaction date is required")
+		return fieldError("date", "transaction date is required")
 	}
 
 	if t.Date.After(time.Now().Add(24 * time.Hour)) {
-		return errors.New("transaction date cannot be too far in the future")
+		return fieldError("date", "transaction date cannot be too far in the future")
 	}
 
 	return nil
@@ -68,3 +73,31 @@ func (t *Transaction) Validate() error {
 func (t *Transaction) IsValidAmount() bool {
 	return t.Amount > 0 && !math.IsNaN(t.Amount) && !math.IsInf(t.Amount, 0)
 }
+
+// ExternalKey - ключ дедупликации импортированных транзакций, пустой для созданных вручную
+func (t *Transaction) ExternalKey() string {
+	if t.ExternalID == "" {
+		return ""
+	}
+	return t.Account + "\x00" + t.ExternalID
+}
+
+// TransactionPage - страница списка транзакций. Total и суммы считаются по всему
+// отфильтрованному набору, а не только по текущей странице.
+type TransactionPage struct {
+	Transactions []Transaction
+	Total        int
+	Sum          float64
+	Income       float64
+	Expense      float64
+	NextCursor   string
+	PrevCursor   string
+}
+
+// BulkResult - итог одной операции пакетного изменения
+type BulkResult struct {
+	Op string `json:"op"`
+	// IDs - затронутые транзакции; для create - ID созданной записи
+	IDs          []int         `json:"ids"`
+	Transactions []Transaction `json:"transactions,omitempty"`
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"net/mail"
+	"strings"
+	"time"
+)
+
+const (
+	minPasswordLen = 8
+	// bcrypt учитывает только первые 72 байта пароля, длиннее не принимаем
+	maxPasswordLen = 72
+)
+
+type User struct {
+	ID    int    `json:"id"`
+	Email string `json:"email"`
+	// PasswordHash - bcrypt-хеш, наружу не отдается
+	PasswordHash string `json:"-"`
+	// IsAdmin - доступ к /admin; выдается только через cmd/admin, регистрацией не получить
+	IsAdmin   bool      `json:"is_admin"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UserRecord - пользователь в файле данных и в резервной копии. В отличие от User
+// сериализуется вместе с хешем пароля, поэтому в ответы API не попадает.
+type UserRecord struct {
+	ID           int       `json:"id"`
+	Email        string    `json:"email"`
+	PasswordHash string    `json:"password_hash"`
+	IsAdmin      bool      `json:"is_admin,omitempty"`
+	Version      int       `json:"version"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (u User) Record() UserRecord {
+	return UserRecord(u)
+}
+
+func (r UserRecord) User() User {
+	return User(r)
+}
+
+// UserRecords и UsersFromRecords переводят список пользователей в формат хранения и обратно
+func UserRecords(users []User) []UserRecord {
+	records := make([]UserRecord, len(users))
+	for i, user := range users {
+		records[i] = user.Record()
+	}
+	return records
+}
+
+func UsersFromRecords(records []UserRecord) []User {
+	users := make([]User, len(records))
+	for i, record := range records {
+		users[i] = record.User()
+	}
+	return users
+}
+
+// RefreshToken - выданный refresh-токен. Хранится только sha256 от токена,
+// поэтому утечка файла данных не дает войти от имени пользователя.
+type RefreshToken struct {
+	TokenHash string     `json:"token_hash"`
+	UserID    int        `json:"user_id"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// NormalizeEmail приводит адрес к виду, в котором он хранится и сравнивается
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func ValidateEmail(email string) error {
+	if email == "" {
+		return fieldError("email", "email is required")
+	}
+
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return fieldError("email", "email is not a valid address")
+	}
+
+	return nil
+}
+
+func ValidatePassword(password string) error {
+	if len([]rune(password)) < minPasswordLen {
+		return fieldError("password", "password must be at least 8 characters")
+	}
+
+	if len(password) > maxPasswordLen {
+		return fieldError("password", "password is too long (max 72 bytes)")
+	}
+
+	return nil
+}
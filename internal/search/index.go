@@ -0,0 +1,316 @@
+// Package search - полнотекстовый поиск по транзакциям: описанию, заметкам и названию категории.
+// Индекс живет в памяти и обновляется по событиям хранилища.
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/ChixXx1/expense-tracker/internal/database"
+	"github.com/ChixXx1/expense-tracker/internal/models"
+)
+
+// Веса полей: совпадение в описании важнее совпадения в категории, а то - в заметках
+const (
+	weightDescription = 3
+	weightCategory    = 2
+	weightNotes       = 1
+
+	// prefixFactor - множитель для слов, которые только начинаются с запроса
+	prefixFactor = 0.5
+)
+
+type Hit struct {
+	Transaction models.Transaction `json:"transaction"`
+	Category    string             `json:"category,omitempty"`
+	Score       float64            `json:"score"`
+}
+
+type Index struct {
+	mu           sync.Mutex
+	transactions map[int]models.Transaction
+	categories   map[int]string
+
+	// postings: основа слова -> транзакция -> взвешенная частота в описании и заметках
+	postings map[string]map[int]float64
+	docTerms map[int][]string
+
+	// по категориям отдельный маленький индекс, чтобы переименование не требовало переиндексации транзакций
+	categoryPostings map[string]map[int]bool
+	categoryTerms    map[int][]string
+	byCategory       map[int]map[int]bool
+
+	// ledgerSizes - число транзакций в каждой книге: IDF считается по книге, где идет поиск
+	ledgerSizes map[int]int
+
+	// terms - отсортированный словарь для поиска по префиксу, nil после изменений
+	terms []string
+}
+
+func NewIndex() *Index {
+	ix := &Index{}
+	ix.reset()
+	return ix
+}
+
+func (ix *Index) reset() {
+	ix.transactions = make(map[int]models.Transaction)
+	ix.categories = make(map[int]string)
+	ix.postings = make(map[string]map[int]float64)
+	ix.docTerms = make(map[int][]string)
+	ix.categoryPostings = make(map[string]map[int]bool)
+	ix.categoryTerms = make(map[int][]string)
+	ix.byCategory = make(map[int]map[int]bool)
+	ix.ledgerSizes = make(map[int]int)
+	ix.terms = nil
+}
+
+// Apply обновляет индекс по событию хранилища; подписывается через Storage.Subscribe
+func (ix *Index) Apply(event database.Event) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	switch event.Type {
+	case database.EventReset:
+		ix.reset()
+	case database.EventTransactionSaved:
+		ix.removeTransaction(event.ID)
+		ix.addTransaction(*event.Transaction)
+	case database.EventTransactionDeleted:
+		ix.removeTransaction(event.ID)
+	case database.EventCategorySaved:
+		ix.removeCategory(event.ID)
+		ix.addCategory(*event.Category)
+	case database.EventCategoryDeleted:
+		ix.removeCategory(event.ID)
+	}
+}
+
+func (ix *Index) addTransaction(tx models.Transaction) {
+	frequencies := make(map[string]float64)
+	for _, token := range tokenize(tx.Description) {
+		frequencies[stem(token)] += weightDescription
+	}
+	for _, token := range tokenize(tx.Notes) {
+		frequencies[stem(token)] += weightNotes
+	}
+
+	terms := make([]string, 0, len(frequencies))
+	for term, frequency := range frequencies {
+		if ix.postings[term] == nil {
+			ix.postings[term] = make(map[int]float64)
+			ix.terms = nil
+		}
+		ix.postings[term][tx.ID] = frequency
+		terms = append(terms, term)
+	}
+
+	ix.transactions[tx.ID] = tx
+	ix.docTerms[tx.ID] = terms
+	ix.ledgerSizes[tx.LedgerID]++
+	if ix.byCategory[tx.CategoryID] == nil {
+		ix.byCategory[tx.CategoryID] = make(map[int]bool)
+	}
+	ix.byCategory[tx.CategoryID][tx.ID] = true
+}
+
+func (ix *Index) removeTransaction(id int) {
+	tx, ok := ix.transactions[id]
+	if !ok {
+		return
+	}
+
+	for _, term := range ix.docTerms[id] {
+		delete(ix.postings[term], id)
+		if len(ix.postings[term]) == 0 {
+			delete(ix.postings, term)
+			ix.terms = nil
+		}
+	}
+
+	delete(ix.byCategory[tx.CategoryID], id)
+	if ix.ledgerSizes[tx.LedgerID]--; ix.ledgerSizes[tx.LedgerID] == 0 {
+		delete(ix.ledgerSizes, tx.LedgerID)
+	}
+	delete(ix.docTerms, id)
+	delete(ix.transactions, id)
+}
+
+func (ix *Index) addCategory(category models.Category) {
+	var terms []string
+	for _, token := range tokenize(category.Name) {
+		term := stem(token)
+		if ix.categoryPostings[term] == nil {
+			ix.categoryPostings[term] = make(map[int]bool)
+			ix.terms = nil
+		}
+		ix.categoryPostings[term][category.ID] = true
+		terms = append(terms, term)
+	}
+
+	ix.categories[category.ID] = category.Name
+	ix.categoryTerms[category.ID] = terms
+}
+
+func (ix *Index) removeCategory(id int) {
+	for _, term := range ix.categoryTerms[id] {
+		delete(ix.categoryPostings[term], id)
+		if len(ix.categoryPostings[term]) == 0 {
+			delete(ix.categoryPostings, term)
+			ix.terms = nil
+		}
+	}
+
+	delete(ix.categoryTerms, id)
+	delete(ix.categories, id)
+}
+
+// Search находит транзакции книги, в которых встречаются все слова запроса (целиком или как начало слова),
+// и возвращает не больше limit лучших вместе с общим числом найденных
+func (ix *Index) Search(ledgerID int, query string, limit int) ([]Hit, int) {
+	tokens := tokenize(query)
+	if len(tokens) == 0 {
+		return []Hit{}, 0
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	var scores map[int]float64
+	for _, token := range tokens {
+		tokenScores := ix.scoreToken(ledgerID, stem(token))
+		if scores == nil {
+			scores = tokenScores
+			continue
+		}
+		for id, score := range scores {
+			if tokenScore, ok := tokenScores[id]; ok {
+				scores[id] = score + tokenScore
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		tx := ix.transactions[id]
+		if tx.LedgerID != ledgerID {
+			continue
+		}
+		hits = append(hits, Hit{
+			Transaction: tx,
+			Category:    ix.categories[tx.CategoryID],
+			Score:       math.Round(score*1000) / 1000,
+		})
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		if !hits[i].Transaction.Date.Equal(hits[j].Transaction.Date) {
+			return hits[i].Transaction.Date.After(hits[j].Transaction.Date)
+		}
+		return hits[i].Transaction.ID > hits[j].Transaction.ID
+	})
+
+	total := len(hits)
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+
+	return hits, total
+}
+
+// scoreToken считает вклад одного слова запроса в оценку каждой транзакции книги (TF-IDF).
+// Число документов и частоты слов берутся только по этой книге: чужие записи не должны влиять на порядок выдачи.
+// Из нескольких совпавших слов словаря берется лучшее, чтобы короткий префикс не перевешивал точное совпадение.
+func (ix *Index) scoreToken(ledgerID int, term string) map[int]float64 {
+	scores := make(map[int]float64)
+	total := float64(ix.ledgerSizes[ledgerID])
+
+	keep := func(id int, score float64) {
+		if score > scores[id] {
+			scores[id] = score
+		}
+	}
+
+	for _, candidate := range ix.prefixed(term) {
+		factor := 1.0
+		if candidate != term {
+			factor = prefixFactor
+		}
+
+		if docs := inLedger(ix, ledgerID, ix.postings[candidate]); len(docs) > 0 {
+			idf := math.Log(1 + total/float64(len(docs)))
+			for id, frequency := range docs {
+				keep(id, frequency*idf*factor)
+			}
+		}
+
+		for categoryID := range ix.categoryPostings[candidate] {
+			docs := inLedger(ix, ledgerID, ix.byCategory[categoryID])
+			if len(docs) == 0 {
+				continue
+			}
+			idf := math.Log(1 + total/float64(len(docs)))
+			for id := range docs {
+				keep(id, weightCategory*idf*factor)
+			}
+		}
+	}
+
+	return scores
+}
+
+// inLedger оставляет из списка документов только транзакции книги
+func inLedger[V any](ix *Index, ledgerID int, docs map[int]V) map[int]V {
+	result := make(map[int]V)
+	for id, value := range docs {
+		if ix.transactions[id].LedgerID == ledgerID {
+			result[id] = value
+		}
+	}
+	return result
+}
+
+// prefixed возвращает слова словаря, начинающиеся с term (включая само term)
+func (ix *Index) prefixed(term string) []string {
+	if ix.terms == nil {
+		ix.terms = make([]string, 0, len(ix.postings)+len(ix.categoryPostings))
+		for t := range ix.postings {
+			ix.terms = append(ix.terms, t)
+		}
+		for t := range ix.categoryPostings {
+			if ix.postings[t] == nil {
+				ix.terms = append(ix.terms, t)
+			}
+		}
+		sort.Strings(ix.terms)
+	}
+
+	var result []string
+	for i := sort.SearchStrings(ix.terms, term); i < len(ix.terms) && strings.HasPrefix(ix.terms[i], term); i++ {
+		result = append(result, ix.terms[i])
+	}
+	return result
+}
+
+// tokenize разбивает текст на слова в нижнем регистре; однобуквенные слова отбрасываются
+func tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	tokens := words[:0]
+	for _, word := range words {
+		if len([]rune(word)) > 1 {
+			tokens = append(tokens, word)
+		}
+	}
+	return tokens
+}
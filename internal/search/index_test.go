@@ -0,0 +1,35 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/ChixXx1/expense-tracker/internal/database"
+	"github.com/ChixXx1/expense-tracker/internal/models"
+)
+
+func saved(ix *Index, tx models.Transaction) {
+	ix.Apply(database.Event{Type: database.EventTransactionSaved, ID: tx.ID, Transaction: &tx})
+}
+
+// Оценки в книге не зависят от записей других книг: IDF считается по книге поиска
+func TestScoresArePerLedger(t *testing.T) {
+	ix := NewIndex()
+	saved(ix, models.Transaction{ID: 1, LedgerID: 1, Description: "Кофе с собой"})
+	saved(ix, models.Transaction{ID: 2, LedgerID: 1, Description: "Аренда квартиры"})
+	saved(ix, models.Transaction{ID: 3, LedgerID: 1, Description: "Продукты"})
+
+	before, _ := ix.Search(1, "кофе", 10)
+	if len(before) != 1 {
+		t.Fatalf("hits %+v, want one", before)
+	}
+
+	// в чужой книге слово встречается часто, а документов много
+	for id := 10; id < 30; id++ {
+		saved(ix, models.Transaction{ID: id, LedgerID: 2, Description: "Кофе в офисе"})
+	}
+
+	after, total := ix.Search(1, "кофе", 10)
+	if total != 1 || after[0].Score != before[0].Score {
+		t.Errorf("ledger 1 score changed by another ledger: %.3f -> %.3f", before[0].Score, after[0].Score)
+	}
+}
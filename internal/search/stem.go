@@ -0,0 +1,168 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Стеммеры упрощенные: для русского - окончания из Snowball без шагов по R2,
+// для английского - первый шаг алгоритма Портера (множественное число, -ed, -ing, конечная y).
+// Этого хватает, чтобы «аптека», «аптеки» и «аптеке» или «pharmacy» и «pharmacies» совпадали.
+
+func stem(word string) string {
+	for _, r := range word {
+		if unicode.Is(unicode.Cyrillic, r) {
+			return stemRussian(word)
+		}
+	}
+	return stemEnglish(word)
+}
+
+var (
+	ruPerfectiveGerund1 = []string{"вшись", "вши", "в"}
+	ruPerfectiveGerund2 = []string{"ившись", "ывшись", "ивши", "ывши", "ив", "ыв"}
+	ruReflexive         = []string{"ся", "сь"}
+	ruAdjective         = []string{
+		"ими", "ыми", "его", "ого", "ему", "ому",
+		"ее", "ие", "ые", "ое", "ей", "ий", "ый", "ой", "ем", "им", "ым", "ом",
+		"их", "ых", "ую", "юю", "ая", "яя", "ою", "ею",
+	}
+	ruParticiple1 = []string{"ем", "нн", "вш", "ющ", "щ"}
+	ruParticiple2 = []string{"ивш", "ывш", "ующ"}
+	ruVerb1       = []string{"нно", "ете", "йте", "ешь", "ла", "на", "ли", "ем", "ло", "но", "ет", "ют", "ны", "ть", "й", "л", "н"}
+	ruVerb2       = []string{
+		"ейте", "уйте", "ила", "ыла", "ена", "ите", "или", "ыли", "ило", "ыло", "ено", "ует", "уют",
+		"ены", "ить", "ыть", "ишь", "ей", "уй", "ил", "ыл", "им", "ым", "ен", "ят", "ит", "ыт", "ую", "ю",
+	}
+	ruNoun = []string{
+		"иями", "ями", "ами", "иях", "ией", "иям", "ием",
+		"ев", "ов", "ие", "ье", "еи", "ии", "ей", "ой", "ий", "ям", "ем", "ам", "ом", "ах", "ях", "ию", "ью", "ия", "ья",
+		"а", "е", "и", "й", "о", "у", "ы", "ь", "ю", "я",
+	}
+)
+
+const ruVowels = "аеиоуыэюя"
+
+func stemRussian(word string) string {
+	word = strings.ReplaceAll(word, "ё", "е")
+	runes := []rune(word)
+
+	// RV - часть слова после первой гласной, окончания ищутся только в ней
+	rv := len(runes)
+	for i, r := range runes {
+		if strings.ContainsRune(ruVowels, r) {
+			rv = i + 1
+			break
+		}
+	}
+	prefix, rest := string(runes[:rv]), string(runes[rv:])
+
+	if cut, ok := cutGroup(rest, ruPerfectiveGerund1, true); ok {
+		rest = cut
+	} else if cut, ok := cutGroup(rest, ruPerfectiveGerund2, false); ok {
+		rest = cut
+	} else {
+		rest, _ = cutSuffix(rest, ruReflexive)
+		if cut, ok := cutSuffix(rest, ruAdjective); ok {
+			rest = cut
+			if cut, ok := cutGroup(rest, ruParticiple1, true); ok {
+				rest = cut
+			} else if cut, ok := cutSuffix(rest, ruParticiple2); ok {
+				rest = cut
+			}
+		} else if cut, ok := cutGroup(rest, ruVerb1, true); ok {
+			rest = cut
+		} else if cut, ok := cutSuffix(rest, ruVerb2); ok {
+			rest = cut
+		} else {
+			rest, _ = cutSuffix(rest, ruNoun)
+		}
+	}
+
+	rest = strings.TrimSuffix(rest, "и")
+	if cut, ok := cutSuffix(rest, []string{"ейше", "ейш"}); ok {
+		rest = cut
+	}
+	if strings.HasSuffix(rest, "нн") {
+		rest = strings.TrimSuffix(rest, "н")
+	} else {
+		rest = strings.TrimSuffix(rest, "ь")
+	}
+
+	return prefix + rest
+}
+
+// cutSuffix отрезает самое длинное из подходящих окончаний; списки упорядочены по убыванию длины
+func cutSuffix(word string, suffixes []string) (string, bool) {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(word, suffix) {
+			return strings.TrimSuffix(word, suffix), true
+		}
+	}
+	return word, false
+}
+
+// cutGroup - как cutSuffix; для групп Snowball «первого типа» окончанию должна предшествовать а или я
+func cutGroup(word string, suffixes []string, afterAYa bool) (string, bool) {
+	for _, suffix := range suffixes {
+		if !strings.HasSuffix(word, suffix) {
+			continue
+		}
+		cut := strings.TrimSuffix(word, suffix)
+		if afterAYa && !strings.HasSuffix(cut, "а") && !strings.HasSuffix(cut, "я") {
+			continue
+		}
+		return cut, true
+	}
+	return word, false
+}
+
+func stemEnglish(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+
+	word = strings.TrimSuffix(word, "'s")
+
+	switch {
+	case strings.HasSuffix(word, "sses"):
+		word = strings.TrimSuffix(word, "es")
+	case strings.HasSuffix(word, "ies"):
+		word = strings.TrimSuffix(word, "es")
+	case strings.HasSuffix(word, "ss"), strings.HasSuffix(word, "us"):
+	case strings.HasSuffix(word, "s"):
+		word = strings.TrimSuffix(word, "s")
+	}
+
+	switch {
+	case strings.HasSuffix(word, "eed"):
+		if hasVowel(strings.TrimSuffix(word, "eed")) {
+			word = strings.TrimSuffix(word, "d")
+		}
+	case strings.HasSuffix(word, "ed") && hasVowel(strings.TrimSuffix(word, "ed")):
+		word = restoreEnding(strings.TrimSuffix(word, "ed"))
+	case strings.HasSuffix(word, "ing") && hasVowel(strings.TrimSuffix(word, "ing")):
+		word = restoreEnding(strings.TrimSuffix(word, "ing"))
+	}
+
+	if strings.HasSuffix(word, "y") && hasVowel(strings.TrimSuffix(word, "y")) {
+		word = strings.TrimSuffix(word, "y") + "i"
+	}
+
+	return word
+}
+
+// restoreEnding выравнивает основу после отрезания -ed/-ing: stopp -> stop, creat -> create
+func restoreEnding(word string) string {
+	switch {
+	case strings.HasSuffix(word, "at"), strings.HasSuffix(word, "bl"), strings.HasSuffix(word, "iz"):
+		return word + "e"
+	case len(word) >= 2 && word[len(word)-1] == word[len(word)-2] && !strings.ContainsRune("lsz", rune(word[len(word)-1])):
+		return word[:len(word)-1]
+	}
+	return word
+}
+
+func hasVowel(word string) bool {
+	return strings.ContainsAny(word, "aeiouy")
+}